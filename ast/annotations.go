@@ -0,0 +1,17 @@
+package ast
+
+import "github.com/aziflaj/pingul/token"
+
+// the <type> in `var x: <type> = ...` and `func(n: <type>): <type>`.
+// Annotations are only read by the checker, the evaluator ignores them
+type TypeAnnotation struct {
+	Token token.Token // the name of the type, e.g. int
+	Name  string
+}
+
+func (t *TypeAnnotation) TokenLiteral() []rune {
+	return t.Token.Literal
+}
+func (t *TypeAnnotation) String() string {
+	return t.Name
+}
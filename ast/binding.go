@@ -0,0 +1,34 @@
+package ast
+
+// Binding is where the variable a name refers to lives, as found
+// out by the resolver before the program runs. The evaluator uses
+// it to get to the value without looking the name up scope by scope
+type Binding struct {
+	Kind BindingKind
+
+	// how many scopes out from where the name is used
+	// the variable is declared, 0 being the scope itself
+	Depth int
+
+	// where the variable is among the slots of that scope,
+	// see BlockStatement.Slots. Only set on Local bindings
+	Slot int
+}
+
+type BindingKind int
+
+const (
+	// names the resolver hasn't seen, or couldn't find,
+	// are looked up by name when the program runs
+	Unresolved BindingKind = iota
+
+	// the intrinsic functions, which win over any variable
+	Intrinsic
+
+	// variables declared inside a block or a function
+	Local
+
+	// variables declared at the top level, which are kept by
+	// name, since modules and the REPL look them up that way
+	Global
+)
@@ -109,10 +109,21 @@ func (i *IfExpression) String() string {
 }
 
 // func(<params>) { <block> }
+// func(<param>: <type>, ...): <type> { <block> }
 type FuncExpression struct {
 	Token  token.Token // the 'func' token
 	Params []*Identifier
 	Body   *BlockStatement
+
+	// nil unless the return type is annotated
+	ReturnType *TypeAnnotation
+
+	// functions that yield return a generator when called
+	IsGenerator bool
+
+	// the name the function is declared with, e.g. `var f = func...`
+	// or `{ f: func... }`. It's "" for anonymous functions
+	Name string
 }
 
 func (f *FuncExpression) expressionNode() {}
@@ -126,13 +137,22 @@ func (f *FuncExpression) String() string {
 
 	for i, param := range f.Params {
 		b.WriteString(param.String())
+		if param.Type != nil {
+			b.WriteString(": ")
+			b.WriteString(param.Type.String())
+		}
 
 		if i < len(f.Params)-1 {
 			b.WriteString(", ")
 		}
 	}
 
-	b.WriteString(") {")
+	b.WriteString(")")
+	if f.ReturnType != nil {
+		b.WriteString(": ")
+		b.WriteString(f.ReturnType.String())
+	}
+	b.WriteString(" {")
 	b.WriteString(f.Body.String())
 	b.WriteString("}")
 
@@ -144,6 +164,10 @@ type CallExpression struct {
 	Token     token.Token // the '(' token
 	Function  Expression  // Identifier or FuncExpression
 	Arguments []Expression
+
+	// the call is the last thing the enclosing function does,
+	// so it can be made without growing the stack
+	IsTail bool
 }
 
 func (c *CallExpression) expressionNode() {}
@@ -168,3 +192,100 @@ func (c *CallExpression) String() string {
 
 	return b.String()
 }
+
+// throw <expression>
+type ThrowExpression struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (t *ThrowExpression) expressionNode() {}
+func (t *ThrowExpression) TokenLiteral() []rune {
+	return t.Token.Literal
+}
+func (t *ThrowExpression) String() string {
+	var b strings.Builder
+
+	b.WriteString("throw ")
+	b.WriteString(t.Value.String())
+
+	return b.String()
+}
+
+// try <block> catch (<identifier>) <block> finally <block>
+// either the catch or the finally block can be left out, but not both
+type TryExpression struct {
+	Token      token.Token // the 'try' token
+	Block      *BlockStatement
+	CatchParam *Identifier // nil when the caught value is not needed
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (t *TryExpression) expressionNode() {}
+func (t *TryExpression) TokenLiteral() []rune {
+	return t.Token.Literal
+}
+func (t *TryExpression) String() string {
+	var b strings.Builder
+
+	b.WriteString("try ")
+	b.WriteString(t.Block.String())
+
+	if t.Catch != nil {
+		b.WriteString(" catch ")
+		if t.CatchParam != nil {
+			b.WriteString("(")
+			b.WriteString(t.CatchParam.String())
+			b.WriteString(") ")
+		}
+		b.WriteString(t.Catch.String())
+	}
+
+	if t.Finally != nil {
+		b.WriteString(" finally ")
+		b.WriteString(t.Finally.String())
+	}
+
+	return b.String()
+}
+
+// yield <expression>
+type YieldExpression struct {
+	Token token.Token // the 'yield' token
+	Value Expression
+}
+
+func (y *YieldExpression) expressionNode() {}
+func (y *YieldExpression) TokenLiteral() []rune {
+	return y.Token.Literal
+}
+func (y *YieldExpression) String() string {
+	var b strings.Builder
+
+	b.WriteString("yield ")
+	b.WriteString(y.Value.String())
+
+	return b.String()
+}
+
+// spawn <call expression>
+type SpawnExpression struct {
+	Token token.Token // the 'spawn' token
+	Call  *CallExpression
+}
+
+func (s *SpawnExpression) expressionNode() {}
+func (s *SpawnExpression) TokenLiteral() []rune {
+	return s.Token.Literal
+}
+func (s *SpawnExpression) String() string {
+	var b strings.Builder
+
+	b.WriteString("spawn ")
+	if s.Call != nil {
+		b.WriteString(s.Call.String())
+	}
+
+	return b.String()
+}
@@ -6,10 +6,19 @@ import (
 	"github.com/aziflaj/pingul/token"
 )
 
-// { key: value, key2: value2, ... }
+// { key: value, key2: value2, [expression]: value3, ... }
 type ObjectLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[string]Expression
+
+	// the pairs whose keys are expressions, in order
+	ComputedPairs []*ComputedPair
+}
+
+// [key]: value
+type ComputedPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (o *ObjectLiteral) expressionNode() {}
@@ -30,6 +39,16 @@ func (o *ObjectLiteral) String() string {
 		b.WriteString(": ")
 		b.WriteString(value.String())
 	}
+	for _, pair := range o.ComputedPairs {
+		if !first {
+			b.WriteString(", ")
+		}
+		first = false
+		b.WriteString("[")
+		b.WriteString(pair.Key.String())
+		b.WriteString("]: ")
+		b.WriteString(pair.Value.String())
+	}
 	b.WriteString("}")
 
 	return b.String()
@@ -9,6 +9,12 @@ import (
 type Identifier struct {
 	Token token.Token // the token.IDENTIFIER token
 	Value []rune
+
+	// set on names being declared with an annotation, e.g. `n: int`
+	Type *TypeAnnotation
+
+	// set by the resolver, see Binding
+	Binding Binding
 }
 
 func (i *Identifier) expressionNode() {}
@@ -21,10 +27,15 @@ func (i *Identifier) String() string {
 }
 
 // var <identifier> = <expression>;
+// var <identifier>: <type> = <expression>;
+// export var <identifier> = <expression>;
 type VarStatement struct {
 	Token token.Token // the token.VAR token
 	Name  *Identifier
 	Value Expression
+
+	// exported names are the only ones other modules can import
+	Exported bool
 }
 
 func (s *VarStatement) statementNode() {} // because Types and stuff
@@ -35,9 +46,16 @@ func (s *VarStatement) TokenLiteral() []rune {
 func (s *VarStatement) String() string {
 	var b strings.Builder
 
+	if s.Exported {
+		b.WriteString("export ")
+	}
 	b.WriteString(string(s.TokenLiteral()))
 	b.WriteString(" ")
 	b.WriteString(s.Name.String())
+	if s.Name.Type != nil {
+		b.WriteString(": ")
+		b.WriteString(s.Name.Type.String())
+	}
 	b.WriteString(" = ")
 
 	if s.Value != nil {
@@ -98,6 +116,12 @@ func (s *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+
+	// the names declared in the scope of the block, in the order
+	// of their slots, set by the resolver. The scope of a function
+	// body starts with self, super and the params, the one of
+	// a catch block with the caught value
+	Slots []string
 }
 
 func (b *BlockStatement) statementNode() {}
@@ -115,3 +139,113 @@ func (ss *BlockStatement) String() string {
 
 	return b.String()
 }
+
+// import "<path>" as <identifier>;
+// from "<path>" import <identifier>, <identifier>, ...;
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT or token.FROM token
+	Path  string
+	Alias *Identifier   // set by import ... as
+	Names []*Identifier // set by from ... import
+}
+
+func (s *ImportStatement) statementNode() {}
+func (s *ImportStatement) TokenLiteral() []rune {
+	return s.Token.Literal
+}
+
+func (s *ImportStatement) String() string {
+	var b strings.Builder
+
+	if s.Alias != nil {
+		b.WriteString("import \"")
+		b.WriteString(s.Path)
+		b.WriteString("\" as ")
+		b.WriteString(s.Alias.String())
+	} else {
+		b.WriteString("from \"")
+		b.WriteString(s.Path)
+		b.WriteString("\" import ")
+
+		for i, name := range s.Names {
+			b.WriteString(name.String())
+
+			if i < len(s.Names)-1 {
+				b.WriteString(", ")
+			}
+		}
+	}
+
+	b.WriteString(";")
+
+	return b.String()
+}
+
+// enum <identifier> { <variant>, <variant>(<field>, ...), ... }
+// export enum <identifier> { ... }
+type EnumStatement struct {
+	Token    token.Token // the token.ENUM token
+	Name     *Identifier
+	Variants []*EnumVariant
+
+	Exported bool
+}
+
+// a variant of an enum, with the fields it carries, if any
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (s *EnumStatement) statementNode() {}
+func (s *EnumStatement) TokenLiteral() []rune {
+	return s.Token.Literal
+}
+
+func (s *EnumStatement) String() string {
+	var b strings.Builder
+
+	if s.Exported {
+		b.WriteString("export ")
+	}
+	b.WriteString("enum ")
+	b.WriteString(s.Name.String())
+	b.WriteString(" { ")
+
+	for i, variant := range s.Variants {
+		b.WriteString(variant.String())
+
+		if i < len(s.Variants)-1 {
+			b.WriteString(", ")
+		}
+	}
+
+	b.WriteString(" }")
+
+	return b.String()
+}
+
+func (v *EnumVariant) String() string {
+	if len(v.Fields) == 0 {
+		return v.Name.String()
+	}
+
+	var b strings.Builder
+
+	b.WriteString(v.Name.String())
+	b.WriteString("(")
+	for i, field := range v.Fields {
+		b.WriteString(field.String())
+		if field.Type != nil {
+			b.WriteString(": ")
+			b.WriteString(field.Type.String())
+		}
+
+		if i < len(v.Fields)-1 {
+			b.WriteString(", ")
+		}
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
@@ -82,3 +82,58 @@ func (l *List) String() string {
 
 	return b.String()
 }
+
+// (<expression>, <expression>, ...)
+type Tuple struct {
+	Token token.Token // the '(' token
+	Items []Expression
+}
+
+func (t *Tuple) expressionNode() {}
+func (t *Tuple) TokenLiteral() []rune {
+	return t.Token.Literal
+}
+func (t *Tuple) String() string {
+	var b strings.Builder
+
+	b.WriteString("(")
+	for i, item := range t.Items {
+		b.WriteString(item.String())
+
+		if i < len(t.Items)-1 || len(t.Items) == 1 {
+			b.WriteString(",")
+		}
+		if i < len(t.Items)-1 {
+			b.WriteString(" ")
+		}
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
+
+// #{<expression>, <expression>, ...}
+type Set struct {
+	Token token.Token // the '#' token
+	Items []Expression
+}
+
+func (s *Set) expressionNode() {}
+func (s *Set) TokenLiteral() []rune {
+	return s.Token.Literal
+}
+func (s *Set) String() string {
+	var b strings.Builder
+
+	b.WriteString("#{")
+	for i, item := range s.Items {
+		b.WriteString(item.String())
+
+		if i < len(s.Items)-1 {
+			b.WriteString(", ")
+		}
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
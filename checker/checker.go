@@ -0,0 +1,821 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
+)
+
+// Checker walks the AST looking for type errors, e.g. a STRING
+// passed to `-` or to a parameter annotated as int. It only knows
+// the types of literals, annotated names and what can be inferred
+// from them; anything else is unknown and never reported, so code
+// without annotations gets checked as much as it can be, and no more
+type Checker struct {
+	// Strict makes the checker report what strict mode would
+	// reject at runtime, same as a "use strict" pragma
+	Strict bool
+
+	// innermost scope is the last one
+	scopes []map[string]*binding
+
+	// the functions being checked, innermost last
+	functions []*signature
+
+	// annotations naming types that don't exist, reported once
+	unknownTypes map[*ast.TypeAnnotation]bool
+
+	errors []string
+
+	// what might be wrong but doesn't have to be,
+	// e.g. a match that doesn't handle every variant
+	warnings []string
+}
+
+// typ is what the checker knows about a value. The zero value
+// is an unknown type, which goes along with anything
+type typ struct {
+	kind object.ObjectType
+
+	// set when the value is known to be a particular function
+	fn *signature
+
+	// set on enums and their variants
+	enum *ast.EnumStatement
+}
+
+var unknown = typ{}
+
+func known(kind object.ObjectType) typ {
+	return typ{kind: kind}
+}
+
+type signature struct {
+	params      []*ast.Identifier
+	returns     typ
+	isGenerator bool
+}
+
+type binding struct {
+	typ typ
+
+	// the type of an annotated name doesn't change, and neither
+	// does the type of a name declared more than once in a scope,
+	// which the checker gives up on
+	fixed bool
+}
+
+// the names of the types that can be used in annotations
+var annotations = map[string]object.ObjectType{
+	"int":       object.INT,
+	"bool":      object.BOOL,
+	"string":    object.STRING,
+	"list":      object.LIST,
+	"dict":      object.DICT,
+	"tuple":     object.TUPLE,
+	"set":       object.SET,
+	"func":      object.FUNC,
+	"nil":       object.NIL,
+	"generator": object.GENERATOR,
+	"iterator":  object.ITERATOR,
+	"task":      object.TASK,
+	"channel":   object.CHANNEL,
+	"module":    object.MODULE,
+	"any":       "",
+}
+
+// what the intrinsics that always return the same type return
+var intrinsicResults = map[string]object.ObjectType{
+	"print":       object.NIL,
+	"len":         object.INT,
+	"append":      object.LIST,
+	"prepend":     object.LIST,
+	"collect":     object.LIST,
+	"range":       object.ITERATOR,
+	"lazy_map":    object.ITERATOR,
+	"lazy_filter": object.ITERATOR,
+	"channel":     object.CHANNEL,
+	"bind":        object.FUNC,
+	"set":         object.SET,
+	"tuple":       object.TUPLE,
+	"has":         object.BOOL,
+	"union":       object.SET,
+	"intersect":   object.SET,
+	"difference":  object.SET,
+	"extend":      object.DICT,
+	"is_a":        object.BOOL,
+	"read_file":   object.STRING,
+	"write_file":  object.NIL,
+	"now":         object.INT,
+	"random":      object.INT,
+	"fetch":       object.STRING,
+}
+
+func New() *Checker {
+	return &Checker{
+		scopes:       []map[string]*binding{make(map[string]*binding)},
+		unknownTypes: make(map[*ast.TypeAnnotation]bool),
+		errors:       []string{},
+		warnings:     []string{},
+	}
+}
+
+// Warnings returns what Check found suspicious, but not wrong
+func (c *Checker) Warnings() []string {
+	return c.warnings
+}
+
+// Check checks the whole program, returning the diagnostics
+func (c *Checker) Check(program *ast.Program) []string {
+	if isStrictPragma(program) {
+		c.Strict = true
+	}
+
+	c.declareAll(program.Statements)
+	c.checkStatements(program.Statements)
+
+	return c.errors
+}
+
+func (c *Checker) check(node ast.Node) typ {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		c.beginScope()
+		defer c.endScope()
+
+		c.declareAll(node.Statements)
+		return c.checkStatements(node.Statements)
+
+	case *ast.ExpressionStatement:
+		return c.check(node.Expression)
+
+	case *ast.VarStatement:
+		return c.checkVarStatement(node)
+
+	case *ast.ReturnStatement:
+		c.checkReturn(node.Token, c.check(node.ReturnValue))
+
+		// whatever comes after doesn't run
+		return unknown
+
+	case *ast.ImportStatement:
+		return known(object.NIL)
+
+	case *ast.EnumStatement:
+		for _, variant := range node.Variants {
+			for _, field := range variant.Fields {
+				c.annotation(field.Type)
+			}
+		}
+		return c.lookup(node.Name.String())
+
+	case *ast.IntegerLiteral:
+		return known(object.INT)
+
+	case *ast.Boolean:
+		return known(object.BOOL)
+
+	case *ast.String:
+		return known(object.STRING)
+
+	case *ast.Nil:
+		return known(object.NIL)
+
+	case *ast.List:
+		c.checkExpressions(node.Items)
+		return known(object.LIST)
+
+	case *ast.Tuple:
+		c.checkExpressions(node.Items)
+		return known(object.TUPLE)
+
+	case *ast.Set:
+		c.checkExpressions(node.Items)
+		return known(object.SET)
+
+	case *ast.ObjectLiteral:
+		for _, value := range node.Pairs {
+			c.check(value)
+		}
+		for _, pair := range node.ComputedPairs {
+			c.check(pair.Key)
+			c.check(pair.Value)
+		}
+		return known(object.DICT)
+
+	case *ast.Identifier:
+		return c.lookup(node.String())
+
+	case *ast.PropertyAccess:
+		return c.checkPropertyAccess(node, c.check(node.Object))
+
+	case *ast.IndexExpression:
+		c.check(node.List)
+		c.check(node.Index)
+		return unknown
+
+	case *ast.PrefixExpression:
+		return c.checkPrefixExpression(node.Token, node.Operator, c.check(node.Right))
+
+	case *ast.InfixExpression:
+		return c.checkInfixExpression(node.Token, node.Operator, c.check(node.Left), c.check(node.Right))
+
+	case *ast.IfExpression:
+		cond := c.check(node.Condition)
+		if c.Strict && cond.kind != "" && cond.kind != object.BOOL {
+			c.errorf(node.Token, "if condition must be a BOOL in strict mode, got %s", cond.kind)
+		}
+
+		consequence := c.check(node.Consequence)
+		if node.Alternative == nil {
+			return unknown
+		}
+
+		alternative := c.check(node.Alternative)
+		if consequence.kind == alternative.kind {
+			return known(consequence.kind)
+		}
+		return unknown
+
+	case *ast.FuncExpression:
+		return c.checkFuncExpression(node)
+
+	case *ast.CallExpression:
+		return c.checkCallExpression(node)
+
+	case *ast.ThrowExpression:
+		c.check(node.Value)
+		return unknown
+
+	case *ast.YieldExpression:
+		c.check(node.Value)
+		return known(object.NIL)
+
+	case *ast.SpawnExpression:
+		if node.Call != nil {
+			c.check(node.Call)
+		}
+		return known(object.TASK)
+
+	case *ast.TryExpression:
+		c.check(node.Block)
+
+		if node.Catch != nil {
+			c.beginScope()
+			if node.CatchParam != nil {
+				c.declare(node.CatchParam, unknown)
+			}
+			c.declareAll(node.Catch.Statements)
+			c.checkStatements(node.Catch.Statements)
+			c.endScope()
+		}
+
+		if node.Finally != nil {
+			c.check(node.Finally)
+		}
+		return unknown
+	}
+
+	return unknown
+}
+
+// the type of a list of statements is the type of the last one,
+// just like a block evaluates to its last statement
+func (c *Checker) checkStatements(statements []ast.Statement) typ {
+	result := known(object.NIL)
+
+	for _, stmt := range statements {
+		result = c.check(stmt)
+	}
+
+	return result
+}
+
+func (c *Checker) checkExpressions(expressions []ast.Expression) {
+	for _, expr := range expressions {
+		c.check(expr)
+	}
+}
+
+func (c *Checker) checkVarStatement(node *ast.VarStatement) typ {
+	val := c.check(node.Value)
+
+	if declared, ok := c.annotation(node.Name.Type); ok && !assignable(declared, val) {
+		c.errorf(node.Name.Token, "cannot assign %s to '%s', which is declared as %s", describe(val), node.Name, describe(declared))
+	}
+
+	if b, ok := c.scopes[len(c.scopes)-1][node.Name.String()]; ok && !b.fixed {
+		b.typ = val
+	}
+
+	return val
+}
+
+func (c *Checker) checkFuncExpression(node *ast.FuncExpression) typ {
+	sig := c.signature(node)
+
+	c.beginScope()
+	c.functions = append(c.functions, sig)
+
+	c.declare(&ast.Identifier{Value: []rune("self")}, unknown)
+	c.declare(&ast.Identifier{Value: []rune("super")}, unknown)
+	for _, param := range node.Params {
+		declared, _ := c.annotation(param.Type)
+		c.declare(param, declared)
+	}
+	c.declareAll(node.Body.Statements)
+
+	// the last statement is what the function returns
+	// if it doesn't return anything explicitly
+	result := c.checkStatements(node.Body.Statements)
+	if !sig.isGenerator {
+		c.checkReturn(node.Token, result)
+	}
+
+	c.functions = c.functions[:len(c.functions)-1]
+	c.endScope()
+
+	if sig.isGenerator && !assignable(sig.returns, known(object.GENERATOR)) {
+		c.errorf(node.Token, "cannot return %s from a function declared to return %s", object.GENERATOR, describe(sig.returns))
+	}
+
+	return typ{kind: object.FUNC, fn: sig}
+}
+
+// checkReturn checks what the innermost function returns
+// against the return type it's been annotated with
+func (c *Checker) checkReturn(at token.Token, val typ) {
+	if len(c.functions) == 0 {
+		return
+	}
+
+	sig := c.functions[len(c.functions)-1]
+	if sig.isGenerator {
+		return
+	}
+
+	if !assignable(sig.returns, val) {
+		c.errorf(at, "cannot return %s from a function declared to return %s", describe(val), describe(sig.returns))
+	}
+}
+
+func (c *Checker) checkCallExpression(node *ast.CallExpression) typ {
+	fun := c.check(node.Function)
+
+	args := make([]typ, len(node.Arguments))
+	for i, arg := range node.Arguments {
+		args[i] = c.check(arg)
+	}
+
+	if ident, ok := node.Function.(*ast.Identifier); ok {
+		if _, ok := object.IntrinsicFuncs[ident.String()]; ok {
+			if ident.String() == "match" && len(args) == 2 {
+				c.checkMatch(node.Token, args[0], node.Arguments[1])
+			}
+			return known(intrinsicResults[ident.String()])
+		}
+	}
+
+	switch fun.kind {
+	case "", object.FUNC, object.INTRINSIC_FUNC, object.DICT:
+	default:
+		c.errorf(node.Token, "%s is not a function", fun.kind)
+		return unknown
+	}
+
+	sig := fun.fn
+	if sig == nil {
+		return unknown
+	}
+
+	name := calleeName(node.Function)
+	if len(args) != len(sig.params) {
+		c.errorf(node.Token, "%s expects %d argument(s), got %d", name, len(sig.params), len(args))
+		return unknown
+	}
+
+	for i, param := range sig.params {
+		expected, _ := c.annotation(param.Type)
+		if !assignable(expected, args[i]) {
+			c.errorf(node.Token, "argument %d of %s expects %s, got %s", i+1, name, describe(expected), describe(args[i]))
+		}
+	}
+
+	if sig.isGenerator {
+		return known(object.GENERATOR)
+	}
+
+	return sig.returns
+}
+
+// Enum.Variant is either a variant or its constructor,
+// and variant.field has to be a field of some variant
+func (c *Checker) checkPropertyAccess(node *ast.PropertyAccess, obj typ) typ {
+	if obj.enum == nil {
+		return unknown
+	}
+
+	enum := obj.enum
+	if obj.kind == object.VARIANT {
+		for _, variant := range enum.Variants {
+			for _, field := range variant.Fields {
+				if field.String() == node.Property {
+					return unknown
+				}
+			}
+		}
+
+		c.errorf(node.Token, "no variant of %s has a field '%s'", enum.Name, node.Property)
+		return unknown
+	}
+
+	variant := findVariant(enum, node.Property)
+	if variant == nil {
+		c.noVariant(node.Token, enum, node.Property)
+		return unknown
+	}
+
+	value := typ{kind: object.VARIANT, enum: enum}
+	if len(variant.Fields) == 0 {
+		return value
+	}
+
+	return typ{kind: object.INTRINSIC_FUNC, fn: &signature{params: variant.Fields, returns: value}}
+}
+
+// checkMatch checks the cases of match(value, { ... }) against
+// the enum of the value. If the enum isn't known, it's the one
+// whose variants the cases are named after
+func (c *Checker) checkMatch(at token.Token, value typ, cases ast.Expression) {
+	literal, ok := cases.(*ast.ObjectLiteral)
+	if !ok {
+		return
+	}
+
+	tags := make([]string, 0, len(literal.Pairs))
+	for tag := range literal.Pairs {
+		if tag != "_" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	enum := value.enum
+	if enum == nil {
+		var candidates []*ast.EnumStatement
+		for _, candidate := range c.visibleEnums() {
+			if hasVariants(candidate, tags) {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		// there's no telling which one it is
+		if len(candidates) != 1 {
+			return
+		}
+		enum = candidates[0]
+	}
+
+	for _, tag := range tags {
+		if findVariant(enum, tag) == nil {
+			c.noVariant(at, enum, tag)
+		}
+	}
+
+	if _, ok := literal.Pairs["_"]; ok {
+		return
+	}
+
+	var missing []string
+	for _, variant := range enum.Variants {
+		if _, ok := literal.Pairs[variant.Name.String()]; !ok {
+			missing = append(missing, variant.Name.String())
+		}
+	}
+
+	if len(missing) != 0 {
+		c.warnf(at, "match on %s doesn't handle %s", enum.Name, strings.Join(missing, ", "))
+	}
+}
+
+func (c *Checker) noVariant(at token.Token, enum *ast.EnumStatement, tag string) {
+	names := make([]string, len(enum.Variants))
+	for i, variant := range enum.Variants {
+		names[i] = variant.Name.String()
+	}
+
+	if suggestion := object.Suggest(tag, names); suggestion != "" {
+		c.errorf(at, "enum %s has no variant '%s' (did you mean '%s'?)", enum.Name, tag, suggestion)
+		return
+	}
+
+	c.errorf(at, "enum %s has no variant '%s'", enum.Name, tag)
+}
+
+func findVariant(enum *ast.EnumStatement, tag string) *ast.EnumVariant {
+	for _, variant := range enum.Variants {
+		if variant.Name.String() == tag {
+			return variant
+		}
+	}
+
+	return nil
+}
+
+func hasVariants(enum *ast.EnumStatement, tags []string) bool {
+	for _, tag := range tags {
+		if findVariant(enum, tag) == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// visibleEnums are the enums declared in any of the scopes,
+// the ones that are shadowed included
+func (c *Checker) visibleEnums() []*ast.EnumStatement {
+	var enums []*ast.EnumStatement
+
+	for _, scope := range c.scopes {
+		for _, b := range scope {
+			if b.typ.kind == object.ENUM && b.typ.enum != nil {
+				enums = append(enums, b.typ.enum)
+			}
+		}
+	}
+
+	return enums
+}
+
+// calleeName is how a function being called is referred to in
+// diagnostics, e.g. Payment.Paid rather than (Payment.Paid)
+func calleeName(node ast.Expression) string {
+	if access, ok := node.(*ast.PropertyAccess); ok {
+		return calleeName(access.Object) + "." + access.Property
+	}
+
+	return node.String()
+}
+
+func (c *Checker) checkPrefixExpression(at token.Token, operator string, right typ) typ {
+	if operator == "not" {
+		return known(object.BOOL)
+	}
+
+	switch right.kind {
+	case object.INT:
+		return known(object.INT)
+	case "", object.DICT:
+		return unknown
+	}
+
+	c.errorf(at, "unsupported operand type for %s: %s", operator, right.kind)
+	return unknown
+}
+
+// checkInfixExpression follows the rules of the evaluator. Dicts
+// can overload operators, so there's no telling what they do
+func (c *Checker) checkInfixExpression(at token.Token, operator string, left typ, right typ) typ {
+	l, r := left.kind, right.kind
+
+	switch operator {
+	case "is", "and", "or":
+		return known(object.BOOL)
+
+	case "==", "!=":
+		// __eq__ can return anything
+		if l == object.DICT || r == object.DICT {
+			return unknown
+		}
+		return known(object.BOOL)
+
+	case "in":
+		switch r {
+		case "", object.DICT, object.LIST, object.TUPLE, object.SET:
+		case object.STRING:
+			if l != "" && l != object.STRING {
+				c.errorf(at, "'in <STRING>' expects a STRING on the left, got %s", l)
+			}
+		default:
+			c.errorf(at, "'in' expects a STRING, LIST, TUPLE, SET or DICT on the right, got %s", r)
+		}
+		return known(object.BOOL)
+	}
+
+	if l == "" || r == "" || l == object.DICT || r == object.DICT {
+		return unknown
+	}
+
+	comparison := operator == "<" || operator == ">" || operator == "<=" || operator == ">="
+
+	switch {
+	case l == object.INT && r == object.INT,
+		!c.Strict && (l == object.INT || l == object.BOOL) && (r == object.INT || r == object.BOOL) &&
+			(l == object.INT || r == object.INT):
+		if comparison {
+			return known(object.BOOL)
+		}
+		return known(object.INT)
+
+	case l == object.STRING && r == object.STRING && (comparison || operator == "+"):
+		if comparison {
+			return known(object.BOOL)
+		}
+		return known(object.STRING)
+
+	case l == object.LIST && r == object.LIST && operator == "+":
+		return known(object.LIST)
+
+	case operator == "*" && r == object.INT && (l == object.STRING || l == object.LIST):
+		return known(l)
+
+	case operator == "*" && l == object.INT && (r == object.STRING || r == object.LIST):
+		return known(r)
+	}
+
+	c.errorf(at, "unsupported operand types for %s: %s and %s", operator, l, r)
+	return unknown
+}
+
+// assignable tells whether a value of type actual can go where
+// a value of type expected is expected. Unknown types always can
+func assignable(expected typ, actual typ) bool {
+	if expected.kind == "" || actual.kind == "" {
+		return true
+	}
+
+	// variants of one enum don't go where another's are expected
+	if expected.kind == object.VARIANT && actual.kind == object.VARIANT {
+		return expected.enum == nil || actual.enum == nil || expected.enum == actual.enum
+	}
+
+	if expected.kind == actual.kind {
+		return true
+	}
+
+	// intrinsics and dicts with a __call__ hook are functions too
+	return expected.kind == object.FUNC && (actual.kind == object.INTRINSIC_FUNC || actual.kind == object.DICT)
+}
+
+// describe names a type in diagnostics, using
+// the name of the enum for its variants
+func describe(t typ) string {
+	if t.kind == object.VARIANT && t.enum != nil {
+		return t.enum.Name.String()
+	}
+
+	return string(t.kind)
+}
+
+// annotation returns the type an annotation stands for, reporting
+// the names that aren't types. Enums are types too, standing for
+// their variants. The second return value is false if there's
+// no annotation to begin with
+func (c *Checker) annotation(annotation *ast.TypeAnnotation) (typ, bool) {
+	if annotation == nil {
+		return unknown, false
+	}
+
+	if kind, ok := annotations[annotation.Name]; ok {
+		return known(kind), true
+	}
+
+	if enum := c.lookup(annotation.Name); enum.kind == object.ENUM && enum.enum != nil {
+		return typ{kind: object.VARIANT, enum: enum.enum}, true
+	}
+
+	if c.unknownTypes[annotation] {
+		return unknown, true
+	}
+	c.unknownTypes[annotation] = true
+
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+
+	for _, enum := range c.visibleEnums() {
+		names = append(names, enum.Name.String())
+	}
+
+	if suggestion := object.Suggest(annotation.Name, names); suggestion != "" {
+		c.errorf(annotation.Token, "unknown type '%s' (did you mean '%s'?)", annotation.Name, suggestion)
+	} else {
+		c.errorf(annotation.Token, "unknown type '%s'", annotation.Name)
+	}
+
+	return unknown, true
+}
+
+func (c *Checker) signature(node *ast.FuncExpression) *signature {
+	returns, _ := c.annotation(node.ReturnType)
+
+	return &signature{
+		params:      node.Params,
+		returns:     returns,
+		isGenerator: node.IsGenerator,
+	}
+}
+
+// like the resolver, every declaration of a scope is visible in all
+// of it, but what's inferred from the value only becomes known once
+// the declaration has been checked. Functions are known right away
+func (c *Checker) declareAll(statements []ast.Statement) {
+	// enums go first, since annotations can refer to them
+	for _, stmt := range statements {
+		if enum, ok := stmt.(*ast.EnumStatement); ok {
+			c.declare(enum.Name, typ{kind: object.ENUM, enum: enum})
+		}
+	}
+
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.VarStatement:
+			val, _ := c.annotation(stmt.Name.Type)
+			if fun, ok := stmt.Value.(*ast.FuncExpression); ok && (val.kind == "" || val.kind == object.FUNC) {
+				val = typ{kind: object.FUNC, fn: c.signature(fun)}
+			}
+			c.declare(stmt.Name, val)
+
+		case *ast.ImportStatement:
+			if stmt.Alias != nil {
+				c.declare(stmt.Alias, known(object.MODULE))
+			}
+			for _, name := range stmt.Names {
+				c.declare(name, unknown)
+			}
+		}
+	}
+}
+
+func (c *Checker) declare(name *ast.Identifier, val typ) {
+	scope := c.scopes[len(c.scopes)-1]
+
+	if b, ok := scope[name.String()]; ok {
+		// declared more than once, it's the same type only if
+		// all the declarations are annotated with the same type
+		if !b.fixed || name.Type == nil || b.typ.kind != val.kind {
+			b.typ = unknown
+		}
+		b.fixed = true
+		return
+	}
+
+	scope[name.String()] = &binding{typ: val, fixed: name.Type != nil}
+}
+
+func (c *Checker) lookup(name string) typ {
+	// intrinsics win over everything else, like in the evaluator
+	if _, ok := object.IntrinsicFuncs[name]; ok {
+		return known(object.INTRINSIC_FUNC)
+	}
+
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b, ok := c.scopes[i][name]; ok {
+			return b.typ
+		}
+	}
+
+	return unknown
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*binding))
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// a program is strict if it starts with "use strict";
+func isStrictPragma(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	str, ok := stmt.Expression.(*ast.String)
+	return ok && string(str.Value) == "use strict"
+}
+
+// diagnostics start with the line and column of the token they're about
+func (c *Checker) errorf(at token.Token, format string, args ...any) {
+	c.errors = append(c.errors, position(at)+fmt.Sprintf(format, args...))
+}
+
+func (c *Checker) warnf(at token.Token, format string, args ...any) {
+	c.warnings = append(c.warnings, position(at)+fmt.Sprintf(format, args...))
+}
+
+func position(at token.Token) string {
+	return fmt.Sprintf("%d:%d: ", at.Line, at.Column)
+}
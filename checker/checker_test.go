@@ -0,0 +1,177 @@
+package checker_test
+
+import (
+	"testing"
+
+	"github.com/aziflaj/pingul/checker"
+	"github.com/aziflaj/pingul/lexer"
+	"github.com/aziflaj/pingul/parser"
+)
+
+func TestCheckWellTypedCode(t *testing.T) {
+	testCases := []string{
+		"var x: int = 1; x + 1;",
+		"var s: string = \"a\" * 3; s + \"!\";",
+		"var f = func(n: int): int { n * 2 }; f(21);",
+		"var f = func(n: int): bool { return n > 1; }; f(2);",
+		"var fib = func(n: int): int { if (n <= 1) { return n; } fib(n - 1) + fib(n - 2) };",
+		// unannotated code is left alone
+		"var f = func(a, b) { a - b }; f(\"a\", \"b\");",
+		"var f = func(x) { x }; f(1) - \"a\";",
+		"var obj = { n: 1 }; obj.n - \"a\";",
+		// dicts can overload anything
+		"var v = { __sub__: func(other) { 0 } }; v - \"a\";",
+		"var f = func(g: func) { g(1) }; f(len); f({ __call__: func(x) { x } });",
+		"var x: any = 1; var y: string = x;",
+		"5 + true;",
+		"if (1) { 2 };",
+		// redeclared names could be anything
+		"var x = 1; var x = \"a\"; x + \"b\";",
+		"var f = func(n) { var n = \"a\"; n + \"b\" };",
+		// generators
+		"var g = func(): generator { yield 1; }; next(g());",
+		`import "lib.pl" as lib; lib.thing - 1;`,
+		"var add = func(a: int, b: int): int { a + b }; add(len([1]), 2);",
+		// tail of an empty list is nil
+		"var rest: list = tail([1]); var none: nil = tail([]);",
+	}
+
+	for _, input := range testCases {
+		errors := check(t, input)
+		if len(errors) != 0 {
+			t.Fatalf("Expected no errors for %q. Got=%v", input, errors)
+		}
+	}
+}
+
+func TestCheckTypeErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a" - 1;`, []string{"1:5: unsupported operand types for -: STRING and INT"}},
+		{`-"a";`, []string{"1:1: unsupported operand type for -: STRING"}},
+		{`[1] < [2];`, []string{"1:5: unsupported operand types for <: LIST and LIST"}},
+		{`1 in 2;`, []string{"1:3: 'in' expects a STRING, LIST, TUPLE, SET or DICT on the right, got INT"}},
+		{`1 in "abc";`, []string{"1:3: 'in <STRING>' expects a STRING on the left, got INT"}},
+		{`var x: int = "a";`, []string{"1:5: cannot assign STRING to 'x', which is declared as INT"}},
+		{`var x: int = 1; var y: string = x + 1;`, []string{"1:21: cannot assign INT to 'y', which is declared as STRING"}},
+		// inferred types flow through names and calls
+		{`var s = "a"; s - 1;`, []string{"1:16: unsupported operand types for -: STRING and INT"}},
+		{`var half = func(n: int): int { n / 2 }; half(4) + "!";`, []string{"1:49: unsupported operand types for +: INT and STRING"}},
+		{`var half = func(n: int): int { n / 2 }; half("4");`, []string{"1:45: argument 1 of half expects INT, got STRING"}},
+		{`var half = func(n: int) { n / 2 }; half(1, 2);`, []string{"1:40: half expects 1 argument(s), got 2"}},
+		{`var f = func(): int { "a" };`, []string{"1:9: cannot return STRING from a function declared to return INT"}},
+		{`var f = func(): int { return "a"; };`, []string{"1:23: cannot return STRING from a function declared to return INT"}},
+		{`var f = func(): int { };`, []string{"1:9: cannot return NIL from a function declared to return INT"}},
+		{`var f = func(b: bool): int { if (b) { 1 } else { "a" } };`, nil},
+		{`var f = func(b: bool): int { if (b) { "b" } else { "a" } };`, []string{"1:9: cannot return STRING from a function declared to return INT"}},
+		{`var f = func(n: int) { n - "a" };`, []string{"1:26: unsupported operand types for -: INT and STRING"}},
+		{`var g = func(): int { yield 1; };`, []string{"1:9: cannot return GENERATOR from a function declared to return INT"}},
+		{`var n = 1; n(2);`, []string{"1:13: INT is not a function"}},
+		{`len([]) + "a";`, []string{"1:9: unsupported operand types for +: INT and STRING"}},
+		// functions can be used before they're declared
+		{`var f = func() { g("a") }; var g = func(s: int) { s };`, []string{"1:19: argument 1 of g expects INT, got STRING"}},
+		{`var x: strng = "a"; var y: strng = "b";`, []string{
+			"1:8: unknown type 'strng' (did you mean 'string'?)",
+			"1:28: unknown type 'strng' (did you mean 'string'?)",
+		}},
+		{`var f = func(x: float) { x };`, []string{"1:17: unknown type 'float'"}},
+		// strict files don't mix ints and bools
+		{`"use strict"; 5 + true;`, []string{"1:17: unsupported operand types for +: INT and BOOL"}},
+		{`"use strict"; if (1) { 2 };`, []string{"1:15: if condition must be a BOOL in strict mode, got INT"}},
+		// enums
+		{`enum E { A, B(x: int) }; E.B("a");`, []string{"1:29: argument 1 of E.B expects INT, got STRING"}},
+		{`enum E { A, B(x) }; E.B(1, 2);`, []string{"1:24: E.B expects 1 argument(s), got 2"}},
+		{`enum E { A, B(x) }; E.C;`, []string{"1:22: enum E has no variant 'C'"}},
+		{`enum E { A, B(x) }; E.A.y;`, []string{"1:24: no variant of E has a field 'y'"}},
+		{`enum E { A }; enum F { A }; var f = func(e: E) { e }; f(F.A);`, []string{"1:56: argument 1 of f expects E, got F"}},
+		{`var f = func(e: E): E { 1 }; enum E { A };`, []string{"1:9: cannot return INT from a function declared to return E"}},
+		{`enum E { A(x: strng) };`, []string{"1:15: unknown type 'strng' (did you mean 'string'?)"}},
+		{`enum Color { Red, Green }; match(Color.Red, { Red: 1, Gren: 2 });`, []string{"1:33: enum Color has no variant 'Gren' (did you mean 'Green'?)"}},
+	}
+
+	for _, tc := range testCases {
+		errors := check(t, tc.input)
+
+		if len(errors) != len(tc.expected) {
+			t.Fatalf("Wrong number of errors for %q. Got=%v, Expected=%v", tc.input, errors, tc.expected)
+		}
+
+		for i, msg := range errors {
+			if msg != tc.expected[i] {
+				t.Fatalf("Wrong error for %q. Got=%q, Expected=%q", tc.input, msg, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestCheckMatchExhaustiveness(t *testing.T) {
+	enums := "enum Color { Red, Green, Blue }; enum Light { Red, Off }; "
+
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"match(Color.Red, { Red: 1, Green: 2, Blue: 3 });", nil},
+		{"match(Color.Red, { Red: 1, _: 2 });", nil},
+		{"match(Color.Red, { Red: 1 });", []string{"1:64: match on Color doesn't handle Green, Blue"}},
+		{"match(Color.Red, { Red: 1 });\nmatch(Color.Red, { Blue: 1 });", []string{
+			"1:64: match on Color doesn't handle Green, Blue",
+			"2:6: match on Color doesn't handle Red, Green",
+		}},
+		{"var f = func(c: Color) { match(c, { Blue: 1 }) };", []string{"1:89: match on Color doesn't handle Red, Green"}},
+		// the cases tell which enum it is
+		{"var f = func(c) { match(c, { Green: 1 }) };", []string{"1:82: match on Color doesn't handle Red, Blue"}},
+		{"var f = func(l) { match(l, { Off: 1 }) };", []string{"1:82: match on Light doesn't handle Red"}},
+		// unless they don't
+		{"var f = func(c) { match(c, { Red: 1 }) };", nil},
+		{"var f = func(c, cases) { match(c, cases) };", nil},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(enums + tc.input)
+		program := parser.New(lxr).ParseProgram()
+
+		c := checker.New()
+		if errors := c.Check(program); len(errors) != 0 {
+			t.Fatalf("Expected no errors for %q. Got=%v", tc.input, errors)
+		}
+
+		warnings := c.Warnings()
+		if len(warnings) != len(tc.expected) {
+			t.Fatalf("Wrong number of warnings for %q. Got=%v, Expected=%v", tc.input, warnings, tc.expected)
+		}
+
+		for i, msg := range warnings {
+			if msg != tc.expected[i] {
+				t.Fatalf("Wrong warning for %q. Got=%q, Expected=%q", tc.input, msg, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	lxr := lexer.New("if (nil) { 1 };")
+	program := parser.New(lxr).ParseProgram()
+
+	c := checker.New()
+	c.Strict = true
+
+	errors := c.Check(program)
+	if len(errors) != 1 || errors[0] != "1:1: if condition must be a BOOL in strict mode, got NIL" {
+		t.Fatalf("Wrong errors. Got=%v", errors)
+	}
+}
+
+func check(t *testing.T, input string) []string {
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, p.Errors())
+	}
+
+	return checker.New().Check(program)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aziflaj/pingul/checker"
+	"github.com/aziflaj/pingul/lexer"
+	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
+)
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "check" {
+		fmt.Printf("Usage: %s check <filename>...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, filename := range os.Args[2:] {
+		if !check(filename) {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// check prints whatever is wrong with the file, returning
+// false if there's anything wrong at all. Warnings don't count
+func check(filename string) bool {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("Error reading file: %s\n", err)
+		return false
+	}
+
+	lxr := lexer.New(string(content))
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+
+	var warnings []string
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		r := resolver.New()
+		errors = r.Resolve(program)
+		warnings = r.Warnings()
+	}
+	// the checker assumes every name is defined
+	if len(errors) == 0 {
+		c := checker.New()
+		errors = c.Check(program)
+		warnings = append(warnings, c.Warnings()...)
+	}
+
+	for _, msg := range errors {
+		fmt.Printf("%s: %s\n", filename, msg)
+	}
+	for _, msg := range warnings {
+		fmt.Printf("%s: warning: %s\n", filename, msg)
+	}
+
+	return len(errors) == 0
+}
@@ -1,27 +1,43 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/aziflaj/pingul/eval"
 	"github.com/aziflaj/pingul/lexer"
 	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/optimize"
 	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Printf("Usage: %s <filename>\n", os.Args[0])
+	dumpAST := flag.Bool("dump-ast", false, "print the optimized program instead of running it")
+	rootDir := flag.String("root", "", "the directory the script can read, write and import from (default: the script's own)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("Usage: %s [-dump-ast] [-root dir] <filename>\n", os.Args[0])
 		return
 	}
 
-	filename := os.Args[1]
+	filename := flag.Arg(0)
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		fmt.Printf("File %s does not exist!\n", filename)
 		return
 	}
 
+	// paths the interpreter gets are taken relative to the root
+	// of its file system, not to the directory it's run from
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		fmt.Printf("Error reading file: %s\n", err)
+		return
+	}
+
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Printf("Error reading file: %s\n", err)
@@ -32,8 +48,68 @@ func main() {
 	p := parser.New(lxr)
 	program := p.ParseProgram()
 
-	scope := object.NewScope()
-	result := eval.Eval(scope, program)
+	// the optimizer moves expressions out of their blocks,
+	// so names are resolved once it's done with them
+	program = optimize.New().Optimize(program)
+	if *dumpAST {
+		for _, stmt := range program.Statements {
+			fmt.Println(stmt.String())
+		}
+		return
+	}
+
+	r := resolver.New()
+	if errors := r.Resolve(program); len(errors) != 0 {
+		for _, msg := range errors {
+			fmt.Printf("\t%s\n", msg)
+		}
+		os.Exit(1)
+	}
+	for _, msg := range r.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+	}
+
+	if *rootDir == "" {
+		*rootDir = filepath.Dir(filename)
+	}
+	caps, err := object.Everything(*rootDir)
+	if err != nil {
+		fmt.Printf("Error opening the root directory: %s\n", err)
+		os.Exit(1)
+	}
+
+	// imports that can't be found next to the importing file
+	// are looked up in the directories listed in PINGULPATH,
+	// which can't be imported from unless they're in the root
+	root, err := filepath.Abs(*rootDir)
+	if err != nil {
+		fmt.Printf("Error opening the root directory: %s\n", err)
+		os.Exit(1)
+	}
+	var searchPath []string
+	for _, dir := range filepath.SplitList(os.Getenv("PINGULPATH")) {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, dir); err != nil || !filepath.IsLocal(rel) {
+			fmt.Printf("PINGULPATH directory %s is outside of the filesystem root %s, pass a -root that holds both\n", dir, root)
+			os.Exit(1)
+		}
+		searchPath = append(searchPath, dir)
+	}
+
+	interpreter := eval.NewWithCapabilities(caps)
+	interpreter.SearchPath = searchPath
+
+	scope := object.NewModuleScope(filename)
+	result := interpreter.Eval(scope, program)
+
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "Uncaught %s: %s\n", err.Kind, err.Message)
+		fmt.Fprint(os.Stderr, err.Traceback())
+		os.Exit(1)
+	}
 
 	if result != nil {
 		fmt.Println(result.Inspect())
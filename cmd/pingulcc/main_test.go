@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pingulcc builds the command, returning the path to the binary
+func pingulcc(t *testing.T) string {
+	t.Helper()
+
+	binary := filepath.Join(t.TempDir(), "pingulcc")
+	if out, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("Building pingulcc failed: %s\n%s", err, out)
+	}
+
+	return binary
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestImportsFromAnotherDirectory(t *testing.T) {
+	binary := pingulcc(t)
+	script := writeFiles(t, map[string]string{
+		"main.pl":     `import "lib/m.pl" as m; m.answer;`,
+		"escape.pl":   `import "../lib/m.pl" as m;`,
+		"lib/m.pl":    `export var answer = 42;`,
+		"lib/data.pl": `read_file("m.pl");`,
+	})
+	elsewhere := t.TempDir()
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		// the file system is rooted at the script's directory
+		{[]string{filepath.Join(script, "main.pl")}, "INT(42)"},
+		{[]string{"-root", filepath.Join(script, "lib"), filepath.Join(script, "lib", "data.pl")}, "STRING(export var answer = 42;)"},
+		{[]string{filepath.Join(script, "escape.pl")}, "it's outside of the filesystem root " + script},
+	}
+
+	for _, tc := range testCases {
+		cmd := exec.Command(binary, tc.args...)
+		cmd.Dir = elsewhere
+		out, _ := cmd.CombinedOutput()
+
+		if !strings.Contains(string(out), tc.expected) {
+			t.Errorf("Wrong output for %v. Got=%q, Expected it to contain %q", tc.args, out, tc.expected)
+		}
+	}
+}
+
+func TestImportsFromPingulPath(t *testing.T) {
+	binary := pingulcc(t)
+	dir := writeFiles(t, map[string]string{
+		"app/main.pl": `import "m.pl" as m; m.answer;`,
+		"lib/m.pl":    `export var answer = 42;`,
+	})
+	lib := filepath.Join(dir, "lib")
+	main := filepath.Join(dir, "app", "main.pl")
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-root", dir, main}, "INT(42)"},
+		// the search path is only any use inside the root
+		{[]string{main}, fmt.Sprintf("PINGULPATH directory %s is outside of the filesystem root %s, pass a -root that holds both",
+			lib, filepath.Join(dir, "app"))},
+	}
+
+	for _, tc := range testCases {
+		cmd := exec.Command(binary, tc.args...)
+		cmd.Dir = t.TempDir()
+		cmd.Env = append(os.Environ(), "PINGULPATH="+lib)
+		out, _ := cmd.CombinedOutput()
+
+		if !strings.Contains(string(out), tc.expected) {
+			t.Errorf("Wrong output for %v. Got=%q, Expected it to contain %q", tc.args, out, tc.expected)
+		}
+	}
+}
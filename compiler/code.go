@@ -0,0 +1,193 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Instructions are a stream of opcodes, each one followed by its operands
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	// OpConstant pushes a constant from the pool
+	OpConstant Opcode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+
+	// variables live in slots. OpGetOuter reaches into the slots of
+	// an enclosing function, OpGetName tries every declaration a
+	// name could refer to, innermost first, until one of them is set
+	OpGetLocal
+	OpGetOuter
+	OpGetName
+	OpSetLocal
+	OpGetIntrinsic
+
+	OpList
+	OpTuple
+	OpSet
+	OpDict
+
+	OpIndex
+	OpProperty
+	OpPrefix
+	OpInfix
+	// OpTruthy turns the value into a BOOL
+	OpTruthy
+
+	OpJump
+	// OpJumpIfNot jumps if the value is falsy, OpBranch does
+	// the same for if conditions, which strict code checks
+	OpJumpIfNot
+	OpBranch
+
+	OpClosure
+	OpCall
+	OpTailCall
+	OpReturn
+	OpSpawn
+	OpYield
+
+	OpThrow
+	// OpTry runs a try expression, whose blocks follow it
+	OpTry
+
+	OpImport
+	OpEnum
+)
+
+// Definition is what an opcode is called and how wide its operands are
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:     {"OpConstant", []int{2}},
+	OpNil:          {"OpNil", []int{}},
+	OpTrue:         {"OpTrue", []int{}},
+	OpFalse:        {"OpFalse", []int{}},
+	OpPop:          {"OpPop", []int{}},
+	OpGetLocal:     {"OpGetLocal", []int{2}},
+	OpGetOuter:     {"OpGetOuter", []int{1, 2}},
+	OpGetName:      {"OpGetName", []int{2}},
+	OpSetLocal:     {"OpSetLocal", []int{2}},
+	OpGetIntrinsic: {"OpGetIntrinsic", []int{2}},
+	OpList:         {"OpList", []int{2}},
+	OpTuple:        {"OpTuple", []int{2}},
+	OpSet:          {"OpSet", []int{2}},
+	OpDict:         {"OpDict", []int{2}},
+	OpIndex:        {"OpIndex", []int{}},
+	OpProperty:     {"OpProperty", []int{2}},
+	OpPrefix:       {"OpPrefix", []int{1}},
+	OpInfix:        {"OpInfix", []int{1}},
+	OpTruthy:       {"OpTruthy", []int{}},
+	OpJump:         {"OpJump", []int{2}},
+	OpJumpIfNot:    {"OpJumpIfNot", []int{2}},
+	OpBranch:       {"OpBranch", []int{2}},
+	OpClosure:      {"OpClosure", []int{2}},
+	OpCall:         {"OpCall", []int{1}},
+	OpTailCall:     {"OpTailCall", []int{1}},
+	OpReturn:       {"OpReturn", []int{}},
+	OpSpawn:        {"OpSpawn", []int{1}},
+	OpYield:        {"OpYield", []int{}},
+	OpThrow:        {"OpThrow", []int{}},
+	OpTry:          {"OpTry", []int{2}},
+	OpImport:       {"OpImport", []int{2}},
+	OpEnum:         {"OpEnum", []int{2}},
+}
+
+// Operators are the operators OpPrefix and OpInfix take,
+// by the index they're encoded with
+var Operators = []string{"+", "-", "*", "/", "%", "==", "!=", "<", "<=", ">", ">=", "is", "in", "not"}
+
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction. Operands are big endian
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += def.OperandWidths[i]
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction,
+// returning them along with how many bytes they took
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 1:
+			operands[i] = int(ins[offset])
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// String disassembles the instructions, one per line
+func (ins Instructions) String() string {
+	var b strings.Builder
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(Opcode(ins[i]))
+		if err != nil {
+			fmt.Fprintf(&b, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&b, "%04d %s", i, def.Name)
+		for _, o := range operands {
+			fmt.Fprintf(&b, " %d", o)
+		}
+		b.WriteString("\n")
+
+		i += 1 + read
+	}
+
+	return b.String()
+}
@@ -0,0 +1,610 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
+)
+
+// Bytecode is a compiled program: the code of its top level and of
+// every function in it, along with the tables that code refers to
+type Bytecode struct {
+	Main      *Function
+	Functions []*Function
+	Constants []object.Object
+	// the property and intrinsic names the code refers to
+	Names   []string
+	Imports []Import
+	Enums   []*ast.EnumStatement
+
+	// the slots of the top level by name, and the names
+	// other modules are allowed to import out of them
+	Globals map[string]int
+	Exports []string
+
+	// set if the file starts with "use strict";
+	Strict bool
+
+	// File is where the program was read from, imports
+	// are looked up relative to it. "" if it's not a file
+	File string
+}
+
+// Function is the compiled body of a function, or of the top level
+type Function struct {
+	Name string
+	// nil for the top level
+	Node *ast.FuncExpression
+
+	Instructions Instructions
+
+	// the name of what every slot holds, params and the
+	// receiver included. Nested blocks get slots of their own
+	SlotNames  []string
+	ParamSlots []int
+	SelfSlot   int
+	SuperSlot  int
+
+	// how deep the operand stack gets
+	MaxStack int
+
+	Refs  []Ref
+	Tries []Try
+
+	// where the instructions that call into pingul code
+	// come from, by their position in Instructions
+	Sites map[int]Site
+
+	// the program the function is part of
+	Program *Bytecode
+}
+
+// Ref is a name OpGetName looks up: every slot it could be in,
+// innermost first. A name that was never declared has none
+type Ref struct {
+	Name string
+	Vars []Var
+}
+
+// Try is where the blocks of a try expression are. They follow
+// the OpTry, and the code goes on at End once they're done
+type Try struct {
+	Block   Range
+	Catch   *Range
+	Finally *Range
+
+	// where the caught value goes, -1 if the catch doesn't take it
+	CatchSlot int
+
+	End int
+}
+
+type Range struct {
+	Start, End int
+}
+
+// Import is an import statement. Names is nil when
+// the whole module gets imported, rather than names out of it
+type Import struct {
+	Path  string
+	Names []string
+}
+
+type Site struct {
+	Line, Column int
+}
+
+type Compiler struct {
+	code  *Bytecode
+	fn    *Function
+	scope *scope
+
+	// how deep the operand stack is, as far as the code compiled
+	// so far goes, which is how MaxStack gets figured out
+	depth int
+
+	constants map[object.HashKey]int
+	names     map[string]int
+
+	err error
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants: make(map[object.HashKey]int),
+		names:     make(map[string]int),
+	}
+}
+
+// Compile turns the program into bytecode for the vm package to run
+func Compile(program *ast.Program) (*Bytecode, error) {
+	return New().Compile(program)
+}
+
+func (c *Compiler) Compile(program *ast.Program) (*Bytecode, error) {
+	c.code = &Bytecode{Strict: isStrictPragma(program)}
+
+	main := &Function{SelfSlot: -1, SuperSlot: -1, Program: c.code}
+	c.code.Main = main
+	c.fn = main
+	c.scope = &scope{names: make(map[string]int), fn: main}
+	c.code.Globals = c.scope.names
+
+	c.declare(program.Statements)
+	for i, stmt := range program.Statements {
+		if stmt, ok := stmt.(*ast.VarStatement); ok && stmt.Exported {
+			c.code.Exports = append(c.code.Exports, stmt.Name.String())
+		}
+		if stmt, ok := stmt.(*ast.EnumStatement); ok && stmt.Exported {
+			c.code.Exports = append(c.code.Exports, stmt.Name.String())
+		}
+
+		c.compile(stmt)
+		if i < len(program.Statements)-1 {
+			c.emit(OpPop)
+		}
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.code, nil
+}
+
+// a file is strict if it starts with "use strict";
+func isStrictPragma(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	str, ok := stmt.Expression.(*ast.String)
+	return ok && string(str.Value) == "use strict"
+}
+
+// compile emits the code of the node, which leaves
+// exactly one value on the stack once it has run
+func (c *Compiler) compile(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		c.enterBlock()
+		c.compileBlock(node)
+		c.leaveBlock()
+
+	case *ast.ExpressionStatement:
+		c.compile(node.Expression)
+
+	case *ast.VarStatement:
+		c.compile(node.Value)
+		c.emit(OpSetLocal, c.scope.names[node.Name.String()])
+
+	case *ast.ReturnStatement:
+		c.compile(node.ReturnValue)
+		c.emit(OpReturn)
+		// nothing after it runs, but the code that follows
+		// still gets compiled as if there was a value
+		c.depth++
+
+	case *ast.ImportStatement:
+		c.compileImport(node)
+
+	case *ast.EnumStatement:
+		c.code.Enums = append(c.code.Enums, node)
+		c.emit(OpEnum, len(c.code.Enums)-1)
+		c.emit(OpSetLocal, c.scope.names[node.Name.String()])
+
+	case *ast.IntegerLiteral:
+		c.emit(OpConstant, c.constant(object.NewInteger(node.Value)))
+
+	case *ast.String:
+		c.emit(OpConstant, c.constant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+
+	case *ast.Nil:
+		c.emit(OpNil)
+
+	case *ast.List:
+		c.compileAll(node.Items)
+		c.emit(OpList, len(node.Items))
+
+	case *ast.Tuple:
+		c.compileAll(node.Items)
+		c.emit(OpTuple, len(node.Items))
+
+	case *ast.Set:
+		c.compileAll(node.Items)
+		c.emit(OpSet, len(node.Items))
+
+	case *ast.ObjectLiteral:
+		keys := make([]string, 0, len(node.Pairs))
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			c.emit(OpConstant, c.constant(&object.String{Value: []rune(key)}))
+			c.compile(node.Pairs[key])
+		}
+		for _, pair := range node.ComputedPairs {
+			c.compile(pair.Key)
+			c.compile(pair.Value)
+		}
+		c.emit(OpDict, len(keys)+len(node.ComputedPairs))
+
+	case *ast.PropertyAccess:
+		c.compile(node.Object)
+		c.emit(OpProperty, c.name(node.Property))
+
+	case *ast.IndexExpression:
+		c.compile(node.List)
+		c.compile(node.Index)
+		c.emitAt(node.Token, OpIndex)
+
+	case *ast.Identifier:
+		c.compileIdentifier(node.String())
+
+	case *ast.PrefixExpression:
+		c.compile(node.Right)
+		c.emitAt(node.Token, OpPrefix, operator(node.Operator))
+
+	case *ast.InfixExpression:
+		if node.Operator == "and" || node.Operator == "or" {
+			c.compileLogical(node)
+			return
+		}
+
+		c.compile(node.Left)
+		c.compile(node.Right)
+		c.emitAt(node.Token, OpInfix, operator(node.Operator))
+
+	case *ast.IfExpression:
+		c.compile(node.Condition)
+		branch := c.emit(OpBranch, 0xFFFF)
+		depth := c.depth
+
+		c.compile(node.Consequence)
+		jump := c.emit(OpJump, 0xFFFF)
+
+		c.depth = depth
+		c.patch(branch)
+		if node.Alternative != nil {
+			c.compile(node.Alternative)
+		} else {
+			c.emit(OpNil)
+		}
+		c.patch(jump)
+
+	case *ast.FuncExpression:
+		c.compileFunction(node)
+
+	case *ast.CallExpression:
+		// the args are evaluated before the function
+		c.compileAll(node.Arguments)
+		c.compile(node.Function)
+		if node.IsTail {
+			c.emitAt(node.Token, OpTailCall, len(node.Arguments))
+		} else {
+			c.emitAt(node.Token, OpCall, len(node.Arguments))
+		}
+
+	case *ast.SpawnExpression:
+		c.compileAll(node.Call.Arguments)
+		c.compile(node.Call.Function)
+		c.emitAt(node.Call.Token, OpSpawn, len(node.Call.Arguments))
+
+	case *ast.YieldExpression:
+		c.compile(node.Value)
+		c.emit(OpYield)
+
+	case *ast.ThrowExpression:
+		c.compile(node.Value)
+		c.emit(OpThrow)
+
+	case *ast.TryExpression:
+		c.compileTry(node)
+
+	default:
+		c.emit(OpNil)
+	}
+}
+
+// compileBlock compiles the statements of the block into the
+// scope that's already there. An empty block evaluates to nil
+func (c *Compiler) compileBlock(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		c.emit(OpNil)
+		return
+	}
+
+	c.declare(block.Statements)
+	for i, stmt := range block.Statements {
+		c.compile(stmt)
+		if i < len(block.Statements)-1 {
+			c.emit(OpPop)
+		}
+	}
+}
+
+func (c *Compiler) compileAll(nodes []ast.Expression) {
+	for _, node := range nodes {
+		c.compile(node)
+	}
+}
+
+// declare gives a slot to whatever the statements declare, before
+// any of them is compiled: a function can use names that are only
+// declared after it
+func (c *Compiler) declare(statements []ast.Statement) {
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.VarStatement:
+			c.scope.declare(stmt.Name.String())
+		case *ast.EnumStatement:
+			c.scope.declare(stmt.Name.String())
+		case *ast.ImportStatement:
+			if stmt.Alias != nil {
+				c.scope.declare(stmt.Alias.String())
+			}
+			for _, name := range stmt.Names {
+				c.scope.declare(name.String())
+			}
+		}
+	}
+}
+
+func (c *Compiler) enterBlock() {
+	c.scope = &scope{names: make(map[string]int), outer: c.scope, fn: c.fn}
+}
+
+func (c *Compiler) leaveBlock() {
+	c.scope = c.scope.outer
+}
+
+// intrinsics come first, like they do for the evaluator
+func (c *Compiler) compileIdentifier(name string) {
+	if _, ok := object.IntrinsicFuncs[name]; ok {
+		c.emit(OpGetIntrinsic, c.name(name))
+		return
+	}
+
+	vars := c.scope.resolve(name)
+	switch {
+	case len(vars) == 1 && vars[0].Depth == 0:
+		c.emit(OpGetLocal, vars[0].Slot)
+	case len(vars) == 1:
+		c.emit(OpGetOuter, vars[0].Depth, vars[0].Slot)
+	default:
+		c.fn.Refs = append(c.fn.Refs, Ref{Name: name, Vars: vars})
+		c.emit(OpGetName, len(c.fn.Refs)-1)
+	}
+}
+
+// and & or only evaluate the right operand if they need
+// to, and always evaluate to a BOOL
+func (c *Compiler) compileLogical(node *ast.InfixExpression) {
+	c.compile(node.Left)
+	jumpIfNot := c.emit(OpJumpIfNot, 0xFFFF)
+	depth := c.depth
+
+	if node.Operator == "and" {
+		c.compile(node.Right)
+		c.emit(OpTruthy)
+		jump := c.emit(OpJump, 0xFFFF)
+
+		c.depth = depth
+		c.patch(jumpIfNot)
+		c.emit(OpFalse)
+		c.patch(jump)
+		return
+	}
+
+	c.emit(OpTrue)
+	jump := c.emit(OpJump, 0xFFFF)
+
+	c.depth = depth
+	c.patch(jumpIfNot)
+	c.compile(node.Right)
+	c.emit(OpTruthy)
+	c.patch(jump)
+}
+
+// the blocks of a try are run by the VM on their own, each of
+// them starting from the depth the stack is at when the try does
+func (c *Compiler) compileTry(node *ast.TryExpression) {
+	try := Try{CatchSlot: -1}
+	index := len(c.fn.Tries)
+	c.fn.Tries = append(c.fn.Tries, try)
+
+	c.emit(OpTry, index)
+	depth := c.depth
+
+	try.Block = c.compileRange(func() { c.compile(node.Block) })
+
+	if node.Catch != nil {
+		catch := c.compileRange(func() {
+			c.enterBlock()
+			if node.CatchParam != nil {
+				try.CatchSlot = c.scope.declare(node.CatchParam.String())
+			}
+			c.compileBlock(node.Catch)
+			c.leaveBlock()
+		})
+		try.Catch = &catch
+	}
+
+	if node.Finally != nil {
+		finally := c.compileRange(func() { c.compile(node.Finally) })
+		try.Finally = &finally
+	}
+
+	try.End = len(c.fn.Instructions)
+	c.fn.Tries[index] = try
+	c.depth = depth + 1
+}
+
+func (c *Compiler) compileRange(compile func()) Range {
+	depth := c.depth
+	start := len(c.fn.Instructions)
+
+	compile()
+
+	c.depth = depth
+	return Range{Start: start, End: len(c.fn.Instructions)}
+}
+
+func (c *Compiler) compileImport(node *ast.ImportStatement) {
+	imp := Import{Path: node.Path}
+	for _, name := range node.Names {
+		imp.Names = append(imp.Names, name.String())
+	}
+	c.code.Imports = append(c.code.Imports, imp)
+	c.emit(OpImport, len(c.code.Imports)-1)
+	c.depth += max(len(imp.Names), 1)
+	c.fn.MaxStack = max(c.fn.MaxStack, c.depth)
+
+	if node.Alias != nil {
+		c.emit(OpSetLocal, c.scope.names[node.Alias.String()])
+		return
+	}
+
+	// the values of the names are on the stack, the last one on top
+	for i := len(node.Names) - 1; i >= 0; i-- {
+		c.emit(OpSetLocal, c.scope.names[node.Names[i].String()])
+		c.emit(OpPop)
+	}
+	c.emit(OpNil)
+}
+
+// params and the function body share the same scope. The receiver
+// gets its slots first, so that a param named self or super wins
+func (c *Compiler) compileFunction(node *ast.FuncExpression) {
+	fn := &Function{Name: node.Name, Node: node, Program: c.code}
+
+	outer, depth := c.fn, c.depth
+	c.fn, c.depth = fn, 0
+	c.enterBlock()
+
+	fn.SelfSlot = c.scope.declare("self")
+	fn.SuperSlot = c.scope.declare("super")
+	for _, param := range node.Params {
+		fn.ParamSlots = append(fn.ParamSlots, c.scope.declare(param.String()))
+	}
+
+	c.compileBlock(node.Body)
+	c.emit(OpReturn)
+
+	c.leaveBlock()
+	c.fn, c.depth = outer, depth
+
+	c.code.Functions = append(c.code.Functions, fn)
+	c.emit(OpClosure, len(c.code.Functions)-1)
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	def := definitions[op]
+	for i, o := range operands {
+		if o < 0 || o >= 1<<(8*def.OperandWidths[i]) {
+			c.fail(fmt.Errorf("%s can't take %d, the program is too big", def.Name, o))
+		}
+	}
+
+	pos := len(c.fn.Instructions)
+	c.fn.Instructions = append(c.fn.Instructions, Make(op, operands...)...)
+
+	c.depth += stackEffect(op, operands)
+	c.fn.MaxStack = max(c.fn.MaxStack, c.depth)
+
+	return pos
+}
+
+// emitAt emits an instruction that can call into pingul code,
+// remembering where it comes from for the call frames
+func (c *Compiler) emitAt(at token.Token, op Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+	if c.fn.Sites == nil {
+		c.fn.Sites = make(map[int]Site)
+	}
+	c.fn.Sites[pos] = Site{Line: at.Line, Column: at.Column}
+
+	return pos
+}
+
+// patch points the jump at pos to the code that comes next
+func (c *Compiler) patch(pos int) {
+	target := len(c.fn.Instructions)
+	if target > 0xFFFF {
+		c.fail(fmt.Errorf("can't jump to %d, the program is too big", target))
+	}
+
+	binary.BigEndian.PutUint16(c.fn.Instructions[pos+1:], uint16(target))
+}
+
+func (c *Compiler) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// constant adds the value to the pool, unless it's already there
+func (c *Compiler) constant(obj object.Object) int {
+	hash, _ := object.Hash(obj)
+	if index, ok := c.constants[hash]; ok {
+		return index
+	}
+
+	c.code.Constants = append(c.code.Constants, obj)
+	c.constants[hash] = len(c.code.Constants) - 1
+
+	return len(c.code.Constants) - 1
+}
+
+func (c *Compiler) name(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+
+	c.code.Names = append(c.code.Names, name)
+	c.names[name] = len(c.code.Names) - 1
+
+	return len(c.code.Names) - 1
+}
+
+func operator(op string) int {
+	return slices.Index(Operators, op)
+}
+
+// stackEffect is how many values the instruction leaves
+// on the stack, minus how many it takes off of it
+func stackEffect(op Opcode, operands []int) int {
+	switch op {
+	case OpConstant, OpNil, OpTrue, OpFalse, OpGetLocal, OpGetOuter, OpGetName,
+		OpGetIntrinsic, OpClosure, OpEnum:
+		return 1
+	case OpPop, OpIndex, OpInfix, OpJumpIfNot, OpBranch, OpReturn:
+		return -1
+	case OpList, OpTuple, OpSet:
+		return 1 - operands[0]
+	case OpDict:
+		return 1 - 2*operands[0]
+	case OpCall, OpTailCall, OpSpawn:
+		return -operands[0]
+	}
+
+	return 0
+}
@@ -0,0 +1,155 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/aziflaj/pingul/compiler"
+	"github.com/aziflaj/pingul/parser"
+)
+
+func TestMake(t *testing.T) {
+	testCases := []struct {
+		op       compiler.Opcode
+		operands []int
+		expected []byte
+	}{
+		{compiler.OpConstant, []int{65534}, []byte{byte(compiler.OpConstant), 255, 254}},
+		{compiler.OpGetOuter, []int{2, 258}, []byte{byte(compiler.OpGetOuter), 2, 1, 2}},
+		{compiler.OpCall, []int{3}, []byte{byte(compiler.OpCall), 3}},
+		{compiler.OpPop, []int{}, []byte{byte(compiler.OpPop)}},
+	}
+
+	for _, tc := range testCases {
+		instruction := compiler.Make(tc.op, tc.operands...)
+		if string(instruction) != string(tc.expected) {
+			t.Fatalf("Wrong encoding of %d%v. Got=%v, Expected=%v", tc.op, tc.operands, instruction, tc.expected)
+		}
+
+		def, err := compiler.Lookup(tc.op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		operands, read := compiler.ReadOperands(def, instruction[1:])
+		if read != len(tc.expected)-1 {
+			t.Fatalf("Wrong number of bytes read for %s. Got=%d", def.Name, read)
+		}
+		for i, o := range operands {
+			if o != tc.operands[i] {
+				t.Fatalf("Wrong operand %d of %s. Got=%d, Expected=%d", i, def.Name, o, tc.operands[i])
+			}
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2;", `0000 OpConstant 0
+0003 OpConstant 1
+0006 OpInfix 0
+`},
+		{"var x = 1; x;", `0000 OpConstant 0
+0003 OpSetLocal 0
+0006 OpPop
+0007 OpGetLocal 0
+`},
+		// the args come before the function
+		{"len([1, 1]);", `0000 OpConstant 0
+0003 OpConstant 0
+0006 OpList 2
+0009 OpGetIntrinsic 0
+0012 OpCall 1
+`},
+		{"if (true) { 1 };", `0000 OpTrue
+0001 OpBranch 10
+0004 OpConstant 0
+0007 OpJump 11
+0010 OpNil
+`},
+		{"true and false;", `0000 OpTrue
+0001 OpJumpIfNot 9
+0004 OpFalse
+0005 OpTruthy
+0006 OpJump 10
+0009 OpFalse
+`},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		code := compile(t, tc.input)
+
+		if got := code.Main.Instructions.String(); got != tc.expected {
+			t.Fatalf("Wrong instructions for %q. Got=\n%s\nExpected=\n%s", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	code := compile(t, `
+export var x = 1;
+var f = func(a, b) { if (a) { var x = b; x } else { x } };
+var g = func() { y };`)
+
+	if len(code.Main.SlotNames) != 3 || code.Globals["f"] != 1 {
+		t.Fatalf("Wrong slots for the top level. Got=%v", code.Main.SlotNames)
+	}
+	if len(code.Exports) != 1 || code.Exports[0] != "x" {
+		t.Fatalf("Wrong exports. Got=%v", code.Exports)
+	}
+
+	// the receiver, the params, and the x of the if block
+	f := code.Functions[0]
+	expected := []string{"self", "super", "a", "b", "x"}
+	if len(f.SlotNames) != len(expected) {
+		t.Fatalf("Wrong slots for f. Got=%v, Expected=%v", f.SlotNames, expected)
+	}
+	for i, name := range expected {
+		if f.SlotNames[i] != name {
+			t.Fatalf("Wrong slots for f. Got=%v, Expected=%v", f.SlotNames, expected)
+		}
+	}
+
+	// the x of the block could be unset, the outer one is next
+	if len(f.Refs) != 1 || len(f.Refs[0].Vars) != 2 {
+		t.Fatalf("Wrong refs for f. Got=%v", f.Refs)
+	}
+	if v := f.Refs[0].Vars[1]; v.Depth != 1 || v.Slot != 0 {
+		t.Fatalf("Wrong outer x. Got=%+v", v)
+	}
+
+	g := code.Functions[1]
+	if len(g.Refs) != 1 || g.Refs[0].Name != "y" || len(g.Refs[0].Vars) != 0 {
+		t.Fatalf("Expected y to be undeclared. Got=%v", g.Refs)
+	}
+}
+
+func TestTry(t *testing.T) {
+	code := compile(t, `try { 1 } catch (e) { e } finally { 2 };`)
+
+	try := code.Main.Tries[0]
+	if try.Catch == nil || try.Finally == nil || try.CatchSlot != 0 {
+		t.Fatalf("Wrong try. Got=%+v", try)
+	}
+	if try.Block.End != try.Catch.Start || try.Catch.End != try.Finally.Start || try.Finally.End != try.End {
+		t.Fatalf("The blocks of the try don't follow each other. Got=%+v", try)
+	}
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	program, errors := parser.ParseFromString(input)
+	if len(errors) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, errors)
+	}
+
+	code, err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("Compiler error for %q: %s", input, err)
+	}
+
+	return code
+}
@@ -0,0 +1,52 @@
+package compiler
+
+// scope is a block of code, which is where names are declared. Every
+// name gets its own slot among the slots of the function the block
+// is part of, so blocks cost nothing once the code is compiled
+type scope struct {
+	names map[string]int
+	outer *scope
+
+	// the function the block is part of
+	fn *Function
+}
+
+// Var is a slot Depth functions out from the one running,
+// 0 being the slots of the function itself
+type Var struct {
+	Depth int
+	Slot  int
+}
+
+func (s *scope) declare(name string) int {
+	if slot, ok := s.names[name]; ok {
+		return slot
+	}
+
+	slot := len(s.fn.SlotNames)
+	s.fn.SlotNames = append(s.fn.SlotNames, name)
+	s.names[name] = slot
+
+	return slot
+}
+
+// resolve returns every slot the name could be in, innermost first.
+// Which one it's in depends on which of them are set by the time the
+// name gets looked up, since a block can use a name before declaring
+// it for itself
+func (s *scope) resolve(name string) []Var {
+	var vars []Var
+
+	depth, fn := 0, s.fn
+	for scope := s; scope != nil; scope = scope.outer {
+		if scope.fn != fn {
+			depth, fn = depth+1, scope.fn
+		}
+
+		if slot, ok := scope.names[name]; ok {
+			vars = append(vars, Var{Depth: depth, Slot: slot})
+		}
+	}
+
+	return vars
+}
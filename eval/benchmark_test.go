@@ -0,0 +1,58 @@
+package eval_test
+
+import (
+	"testing"
+
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// run with -benchmem: most of what these programs make are ints
+// and bools, which are shared instead of allocated when they can be
+const fib = `
+var fib = func(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20);`
+
+const listProcessing = `
+var map = func(list, fun) {
+  var iter = func(list, acc) {
+    if (len(list) == 0) { return acc; }
+    iter(tail(list), append(acc, fun(head(list))))
+  };
+  iter(list, [])
+};
+var filter = func(list, keep) {
+  var iter = func(list, acc) {
+    if (len(list) == 0) { return acc; }
+    var x = head(list);
+    iter(tail(list), if (keep(x)) { append(acc, x) } else { acc })
+  };
+  iter(list, [])
+};
+var reduce = func(list, fun, acc) {
+  if (len(list) == 0) { return acc; }
+  reduce(tail(list), fun, fun(acc, head(list)))
+};
+var nums = collect(range(300));
+var odd = filter(map(nums, func(x) { x * 3 % 7 }), func(x) { x % 2 == 1 });
+reduce(odd, func(acc, x) { acc + x }, 0);`
+
+func BenchmarkFib(b *testing.B) {
+	benchmark(b, fib)
+}
+
+func BenchmarkListProcessing(b *testing.B) {
+	benchmark(b, listProcessing)
+}
+
+func benchmark(b *testing.B, input string) {
+	program := parseProgram(input)
+	if result := eval.New().Eval(object.NewScope(), program); result.Type() != object.INT {
+		b.Fatalf("Expected an INT. Got=%s", result.Inspect())
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		eval.New().Eval(object.NewScope(), program)
+	}
+}
@@ -0,0 +1,210 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+)
+
+// Limits caps what a run started with EvalContext can use.
+// A limit that's left at zero doesn't apply
+type Limits struct {
+	// how many nodes can be evaluated, all tasks together
+	MaxSteps int64
+
+	// how many items a list, or characters a string, can have
+	MaxAllocation int
+
+	// how long the run can take
+	Timeout time.Duration
+}
+
+// budget is what a run in progress has left
+type budget struct {
+	ctx    context.Context
+	limits Limits
+	steps  atomic.Int64
+
+	// set once the run is over
+	over atomic.Bool
+}
+
+// how many steps go by before the context is looked at again
+const stepsPerCheck = 1024
+
+// step accounts for a node being evaluated
+func (b *budget) step() *object.Error {
+	if b.over.Load() {
+		return object.NewError(object.CANCELLED, "script was cancelled, its run is over")
+	}
+
+	steps := b.steps.Add(1)
+
+	if b.limits.MaxSteps > 0 && steps > b.limits.MaxSteps {
+		return object.NewError(object.STEP_LIMIT, "script exceeded its budget of %d steps", b.limits.MaxSteps)
+	}
+
+	if steps%stepsPerCheck == 0 && b.ctx.Err() != nil {
+		return b.interrupted()
+	}
+
+	return nil
+}
+
+// interrupted is the error for a run whose context is done
+func (b *budget) interrupted() *object.Error {
+	return object.Interrupted(b.ctx)
+}
+
+// checkSize fails if obj is a list or a string bigger than allowed
+func (b *budget) checkSize(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.List:
+		return b.checkAllocation(obj.Type(), int64(obj.Len()), "items")
+	case *object.String:
+		return b.checkAllocation(obj.Type(), int64(len(obj.Value)), "characters")
+	}
+
+	return nil
+}
+
+// checkOperation fails before an operator makes
+// a list or a string bigger than allowed
+func (b *budget) checkOperation(operator string, left object.Object, right object.Object) *object.Error {
+	switch operator {
+	case "*":
+		return b.checkRepetition(left, right)
+	case "+":
+		return b.checkConcatenation(left, right)
+	}
+
+	return nil
+}
+
+func (b *budget) checkConcatenation(left object.Object, right object.Object) *object.Error {
+	switch left := left.(type) {
+	case *object.List:
+		if right, ok := right.(*object.List); ok {
+			return b.checkAllocation(object.LIST, int64(left.Len())+int64(right.Len()), "items")
+		}
+	case *object.String:
+		if right, ok := right.(*object.String); ok {
+			return b.checkAllocation(object.STRING, int64(len(left.Value))+int64(len(right.Value)), "characters")
+		}
+	}
+
+	return nil
+}
+
+func (b *budget) checkRepetition(left object.Object, right object.Object) *object.Error {
+	times, ok := right.(*object.Integer)
+	if !ok {
+		return nil
+	}
+
+	var size int64
+	switch left := left.(type) {
+	case *object.List:
+		size = int64(left.Len())
+	case *object.String:
+		size = int64(len(left.Value))
+	default:
+		return nil
+	}
+
+	units := "items"
+	if left.Type() == object.STRING {
+		units = "characters"
+	}
+
+	// don't let the product overflow
+	if size > 0 && times.Value > math.MaxInt64/size {
+		return b.checkAllocation(left.Type(), math.MaxInt64, units)
+	}
+
+	return b.checkAllocation(left.Type(), size*times.Value, units)
+}
+
+func (b *budget) checkAllocation(what object.ObjectType, size int64, units string) *object.Error {
+	return object.CheckAllocation(what, size, units, b.limits.MaxAllocation)
+}
+
+// meter makes the iterators intrinsics hand out pay for every item,
+// since they can be looped over without evaluating any pingul code
+func (b *budget) meter(obj object.Object) object.Object {
+	it, ok := obj.(*object.LazyIterator)
+	if !ok {
+		return obj
+	}
+
+	return object.NewIterator(func(caller *object.Frame) (object.Object, bool) {
+		if err := b.step(); err != nil {
+			return err, true
+		}
+
+		return it.Next(caller)
+	})
+}
+
+// EvalContext evaluates the node within the Limits of the interpreter,
+// until ctx is done. The interpreter runs one of these at a time, and
+// the budget covers everything the run does, including tasks and calls
+// made by intrinsics, which can't make lists or read strings bigger
+// than allowed either. Once the run is over, the tasks it left running
+// are cancelled, and so are its generators. Intrinsics only get to
+// call its functions back within another run
+func (in *Interpreter) EvalContext(ctx context.Context, scope *object.Scope, node ast.Node) object.Object {
+	in.running.Lock()
+	defer in.running.Unlock()
+
+	var cancel context.CancelFunc
+	if in.Limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, in.Limits.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	b := &budget{ctx: ctx, limits: in.Limits}
+	run := in.newRun(b)
+	in.run.Store(run)
+	defer in.run.Store(nil)
+	defer b.over.Store(true)
+
+	// code blocked on a channel doesn't get to take a step, so the run
+	// is waited on from the outside. What's blocked gives up on its
+	// own once ctx is done, see object.IntrinsicsWithin
+	done := make(chan object.Object, 1)
+	go func() {
+		done <- run.Eval(scope, node)
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return b.interrupted()
+	}
+}
+
+// newRun makes the interpreter a run of EvalContext is evaluated
+// with, which has a budget of its own, and intrinsics that stick to it
+func (in *Interpreter) newRun(b *budget) *Interpreter {
+	intrinsics := object.IntrinsicsWithin(b.ctx, in.caps, in.Limits.MaxAllocation)
+
+	return &Interpreter{
+		SearchPath: in.SearchPath,
+		Strict:     in.Strict,
+		MaxDepth:   in.MaxDepth,
+		Limits:     in.Limits,
+		modules:    in.modules,
+		caps:       in.caps,
+		intrinsics: intrinsics,
+		budget:     b,
+		parent:     in,
+	}
+}
@@ -0,0 +1,190 @@
+package eval_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// an endless loop that doesn't grow the stack
+const spin = "var spin = func(n) { spin(n + 1) }; "
+
+func evalWithLimits(ctx context.Context, limits eval.Limits, input string) object.Object {
+	in := eval.New()
+	in.Limits = limits
+
+	return in.EvalContext(ctx, object.NewScope(), parseProgram(input))
+}
+
+func assertErrorKind(t *testing.T, obj object.Object, kind string, message string) {
+	t.Helper()
+
+	err, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("Object is not an Error. Got=%s", obj.Inspect())
+	}
+
+	if err.Kind != kind || err.Message != message {
+		t.Fatalf("Wrong error. Got=%s: %s, Expected=%s: %s", err.Kind, err.Message, kind, message)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	limits := eval.Limits{MaxSteps: 10000}
+	message := "script exceeded its budget of 10000 steps"
+
+	testCases := []string{
+		spin + "spin(0);",
+		// budgets can't be caught, or cleaned up after
+		spin + `try { spin(0) } catch (e) { "caught" };`,
+		spin + `try { spin(0) } finally { "done" };`,
+		// intrinsics pay for the calls they make, and for the items
+		// they go through, even if no pingul code runs
+		spin + "enum E { A(n) }; match(E.A(0), { A: spin });",
+		"collect(range(1000000000000));",
+		spin + "await(spawn spin(0));",
+	}
+
+	for _, input := range testCases {
+		assertErrorKind(t, evalWithLimits(context.Background(), limits, input), object.STEP_LIMIT, message)
+	}
+
+	evaluated := evalWithLimits(context.Background(), limits,
+		"var fib = func(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);")
+	assertIntegerObject(t, evaluated, 55)
+}
+
+func TestAllocationLimit(t *testing.T) {
+	limits := eval.Limits{MaxAllocation: 100}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" * 1000;`, "script tried to make a STRING of 1000 characters, over its budget of 100"},
+		// it fails before the list is made
+		{"[0] * 1000000000000;", "script tried to make a LIST of 1000000000000 items, over its budget of 100"},
+		{`"ab" * 9223372036854775807;`, "script tried to make a STRING of 9223372036854775807 characters, over its budget of 100"},
+		{`var grow = func(s) { grow(s + s) }; grow("a");`, "script tried to make a STRING of 128 characters, over its budget of 100"},
+		{"var grow = func(l) { grow(append(l, 1)) }; grow([]);", "script tried to make a LIST of 101 items, over its budget of 100"},
+		// lists are checked while they're being made, not once they're done
+		{"collect(range(1000));", "script tried to make a LIST of 101 items, over its budget of 100"},
+		{"len(collect(range(20000000)));", "script tried to make a LIST of 101 items, over its budget of 100"},
+		{"tuple(range(20000000));", "script tried to make a TUPLE of 101 items, over its budget of 100"},
+		{"set(range(20000000));", "script tried to make a SET of 101 items, over its budget of 100"},
+		{"var grow = func(l) { grow(prepend(l, 1)) }; grow([]);", "script tried to make a LIST of 101 items, over its budget of 100"},
+		{"var l = [0] * 60; l + l;", "script tried to make a LIST of 120 items, over its budget of 100"},
+		{`var s = "a" * 60; s + s;`, "script tried to make a STRING of 120 characters, over its budget of 100"},
+		{`try { "a" * 1000 } catch (e) { "caught" };`, "script tried to make a STRING of 1000 characters, over its budget of 100"},
+		{"channel(101);", "script tried to make a CHANNEL of 101 items, over its budget of 100"},
+	}
+
+	for _, tc := range testCases {
+		assertErrorKind(t, evalWithLimits(context.Background(), limits, tc.input), object.ALLOCATION_LIMIT, tc.expected)
+	}
+
+	evaluated := evalWithLimits(context.Background(), limits, `len("a" * 100);`)
+	assertIntegerObject(t, evaluated, 100)
+
+	// files are only read as far as the budget goes
+	dir := writeModules(t, map[string]string{"big.txt": strings.Repeat("a", 1000000), "small.txt": "abc"})
+	caps, err := object.Everything(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := eval.NewWithCapabilities(caps)
+	in.Limits = limits
+
+	evaluated = in.EvalContext(context.Background(), object.NewScope(), parseProgram(`read_file("big.txt");`))
+	assertErrorKind(t, evaluated, object.ALLOCATION_LIMIT, "script tried to make a STRING of 101 characters, over its budget of 100")
+
+	evaluated = in.EvalContext(context.Background(), object.NewScope(), parseProgram(`read_file("small.txt");`))
+	assertStringObject(t, evaluated, "abc")
+}
+
+func TestTimeLimit(t *testing.T) {
+	limits := eval.Limits{Timeout: 50 * time.Millisecond}
+
+	testCases := []string{
+		spin + "spin(0);",
+		"collect(range(1000000000000));",
+		// stuck waiting on a channel nobody sends on
+		"recv(channel());",
+	}
+
+	for _, input := range testCases {
+		start := time.Now()
+		assertErrorKind(t, evalWithLimits(context.Background(), limits, input), object.TIME_LIMIT, "script ran out of time")
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("%q took %s to stop", input, elapsed)
+		}
+	}
+
+	// the deadline of the context counts as well
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assertErrorKind(t, evalWithLimits(ctx, eval.Limits{}, spin+"spin(0);"), object.TIME_LIMIT, "script ran out of time")
+}
+
+func TestTimeoutsLeaveNothingRunning(t *testing.T) {
+	limits := eval.Limits{Timeout: 20 * time.Millisecond}
+
+	testCases := []string{
+		"recv(channel());",
+		"send(channel(), 1);",
+		"select([channel(), channel()]);",
+		"await(spawn recv(channel()));",
+		// tasks that are left waiting
+		"spawn recv(channel()); spawn send(channel(), 1); recv(channel());",
+	}
+
+	before := runtime.NumGoroutine()
+
+	for _, input := range testCases {
+		for range 20 {
+			assertErrorKind(t, evalWithLimits(context.Background(), limits, input), object.TIME_LIMIT, "script ran out of time")
+		}
+	}
+
+	// what's waiting gives up on its own, if not right away
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Runs that timed out left goroutines behind. Before=%d, After=%d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	evaluated := evalWithLimits(ctx, eval.Limits{}, spin+"spin(0);")
+	assertErrorKind(t, evaluated, object.CANCELLED, "script was cancelled")
+
+	// whatever the run leaves behind is cancelled once it's over
+	in := eval.New()
+	scope := object.NewScope()
+
+	evaluated = in.EvalContext(context.Background(), scope, parseProgram(spin+"var task = spawn spin(0); 1;"))
+	assertIntegerObject(t, evaluated, 1)
+
+	evaluated = in.Eval(scope, parseProgram("await(task);"))
+	assertErrorKind(t, evaluated, object.CANCELLED, "script was cancelled, its run is over")
+
+	// but the interpreter isn't, and neither are the runs after it
+	evaluated = in.Eval(scope, parseProgram("var double = func(n) { n * 2 }; double(21);"))
+	assertIntegerObject(t, evaluated, 42)
+
+	in.Limits = eval.Limits{MaxSteps: 10000}
+	evaluated = in.EvalContext(context.Background(), scope, parseProgram("collect(lazy_map(range(3), double));"))
+	assertIntegerList(t, evaluated, []int64{0, 2, 4})
+}
@@ -0,0 +1,135 @@
+package eval_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+func TestPureProfile(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`print("hi");`, "print() needs the stdout capability"},
+		{`read_file("secrets.txt");`, "read_file() needs the filesystem capability"},
+		{`write_file("secrets.txt", "");`, "write_file() needs the filesystem capability"},
+		{`env("HOME");`, "env() needs the environment capability"},
+		{"now();", "now() needs the clock capability"},
+		{"random(6);", "random() needs the random capability"},
+		{`fetch("http://example.com");`, "fetch() needs the network capability"},
+		{`import "secrets.pl" as s;`, "import needs the filesystem capability"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := eval.New().Eval(object.NewScope(), parseProgram(tc.input))
+		assertErrorKind(t, evaluated, object.PERMISSION_ERROR, tc.expected)
+	}
+
+	// the default interpreter is just as pure
+	assertErrorKind(t, evalProgram(`print("hi");`), object.PERMISSION_ERROR, "print() needs the stdout capability")
+
+	evaluated := evalProgram(`try { print("hi") } catch (e) { e.type };`)
+	assertStringObject(t, evaluated, "PermissionError")
+}
+
+func TestCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			w.Write(bytes.Repeat([]byte("a"), object.MaxFetchSize+1))
+			return
+		}
+		fmt.Fprint(w, "pong")
+	}))
+	defer server.Close()
+
+	var stdout bytes.Buffer
+	in := eval.NewWithCapabilities(object.Capabilities{
+		Stdout: &stdout,
+		FS:     root,
+		Env: func(name string) (string, bool) {
+			value, ok := map[string]string{"GREETING": "hi"}[name]
+			return value, ok
+		},
+		Clock:   func() time.Time { return time.UnixMilli(1700000000000) },
+		Random:  rand.New(rand.NewPCG(1, 2)),
+		Network: server.Client(),
+	})
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`print("hello", 1);`, "NIL"},
+		{`write_file("notes.txt", "pingu"); read_file("notes.txt");`, "STRING(pingu)"},
+		{`env("GREETING");`, "STRING(hi)"},
+		{`env("NOPE");`, "NIL"},
+		{"now();", "INT(1700000000000)"},
+		{"var roll = random(6); roll >= 0 and roll < 6;", "BOOL(true)"},
+		{fmt.Sprintf("fetch(%q);", server.URL), "STRING(pong)"},
+		{fmt.Sprintf("fetch(%q);", server.URL+"/big"), "ERROR(RuntimeError: fetch(): the response is bigger than 10485760 bytes)"},
+		// the file system ends at the root
+		{`try { read_file("../outside.txt") } catch (e) { e.type };`, "STRING(RuntimeError)"},
+		{"random(0);", "ERROR(ArgumentError: random() expects a positive INT, got INT(0))"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := in.Eval(object.NewScope(), parseProgram(tc.input))
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+
+	if stdout.String() != "STRING(hello)\nINT(1)\n" {
+		t.Errorf("Wrong output. Got=%q", stdout.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("write_file didn't write inside the root: %s", err)
+	}
+}
+
+func TestFetchStopsWithTheRun(t *testing.T) {
+	cancelled := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	in := eval.NewWithCapabilities(object.Capabilities{Network: server.Client()})
+	in.Limits.Timeout = 50 * time.Millisecond
+
+	evaluated := in.EvalContext(context.Background(), object.NewScope(), parseProgram(fmt.Sprintf("fetch(%q);", server.URL)))
+	if evaluated.Inspect() != "ERROR(TimeLimitExceeded: script ran out of time)" {
+		t.Errorf("Wrong result. Got=%s", evaluated.Inspect())
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("The request went on after the run was over")
+	}
+}
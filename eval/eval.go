@@ -1,147 +1,307 @@
 package eval
 
 import (
+	"path/filepath"
+	"slices"
+	"strings"
+
 	"github.com/aziflaj/pingul/ast"
 	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
 )
 
-var scope = object.NewScope()
-
-func Eval(scope *object.Scope, node ast.Node) object.Object {
+func (in *Interpreter) eval(scope *object.Scope, node ast.Node) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(scope, node)
+		return in.evalProgram(scope, node)
 
 	case *ast.BlockStatement:
-		return evalBlock(scope, node)
+		// every block gets its own scope, so whatever is declared
+		// inside an if/else stays inside of it
+		return in.evalBlock(object.NewBlockScope(scope, node.Slots), node)
 
 	case *ast.ExpressionStatement:
-		return Eval(scope, node.Expression)
+		return in.Eval(scope, node.Expression)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return object.NewInteger(node.Value)
 
 	case *ast.Boolean:
-		return &object.Boolean{Value: node.Value}
+		return object.NewBoolean(node.Value)
 
 	case *ast.String:
 		return &object.String{Value: node.Value}
 
 	case *ast.List:
-		list := &object.List{Items: make([]object.Object, len(node.Items))}
+		items := in.evalExpressions(scope, node.Items)
+		if len(items) == 1 && isError(items[0]) {
+			return items[0]
+		}
 
-		for i, item := range node.Items {
-			list.Items[i] = Eval(scope, item)
+		return object.NewList(items...)
+
+	case *ast.Tuple:
+		items := in.evalExpressions(scope, node.Items)
+		if len(items) == 1 && isError(items[0]) {
+			return items[0]
 		}
 
-		return list
+		return &object.Tuple{Items: items}
+
+	case *ast.Set:
+		items := in.evalExpressions(scope, node.Items)
+		if len(items) == 1 && isError(items[0]) {
+			return items[0]
+		}
+
+		set, err := object.NewSet(items...)
+		if err != nil {
+			return err
+		}
+		return set
 
 	case *ast.ObjectLiteral:
 		dict := &object.Dict{Pairs: make(map[string]object.Object)}
 
 		for key, value := range node.Pairs {
-			dict.Pairs[key] = Eval(scope, value)
+			dict.Pairs[key] = in.Eval(scope, value)
+			if isError(dict.Pairs[key]) {
+				return dict.Pairs[key]
+			}
 		}
 
-		return dict
+		for _, pair := range node.ComputedPairs {
+			key := in.Eval(scope, pair.Key)
+			if isError(key) {
+				return key
+			}
 
-	case *ast.PropertyAccess:
-		obj := Eval(scope, node.Object)
+			value := in.Eval(scope, pair.Value)
+			if isError(value) {
+				return value
+			}
 
-		if obj.Type() == object.DICT {
-			val, ok := obj.(*object.Dict).Pairs[node.Property]
-			if ok {
-				return val
+			if err := dict.SetKey(key, value); err != nil {
+				return err
 			}
-			return &object.Nil{}
 		}
 
-		return &object.Nil{}
+		return dict
+
+	case *ast.PropertyAccess:
+		obj := in.Eval(scope, node.Object)
+		if isError(obj) {
+			return obj
+		}
+
+		return evalPropertyAccess(obj, node.Property)
 
 	case *ast.IndexExpression:
-		list := Eval(scope, node.List)
-		index := Eval(scope, node.Index)
+		list := in.Eval(scope, node.List)
+		if isError(list) {
+			return list
+		}
+
+		index := in.Eval(scope, node.Index)
+		if isError(index) {
+			return index
+		}
 
-		if list.Type() == object.LIST && index.Type() == object.INT {
-			return list.(*object.List).Items[index.(*object.Integer).Value]
+		if hook, ok := Metamethod(list, "__index__"); ok {
+			return in.applyFunction(scope, node.Token, hook, []object.Object{index})
 		}
 
-		return &object.Nil{}
+		return evalIndexExpression(list, index)
 
 	case *ast.Nil:
-		return &object.Nil{}
+		return object.Null
 
 	case *ast.ReturnStatement:
-		return &object.Return{Value: Eval(scope, node.ReturnValue)}
+		val := in.Eval(scope, node.ReturnValue)
+		if isError(val) {
+			return val
+		}
+
+		return &object.Return{Value: val}
 
 	case *ast.FuncExpression:
-		return &object.Func{Params: node.Params, Body: node.Body}
+		return &object.Func{
+			Params:      node.Params,
+			Body:        node.Body,
+			Scope:       scope,
+			Caller:      in,
+			Name:        node.Name,
+			IsGenerator: node.IsGenerator,
+		}
 
 	case *ast.CallExpression:
-		// eval args, left to right
-		args := make([]object.Object, len(node.Arguments))
-		for i, arg := range node.Arguments {
-			args[i] = Eval(scope, arg)
+		args := in.evalExpressions(scope, node.Arguments)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
 		}
 
-		fun := Eval(scope, node.Function)
+		fun := in.Eval(scope, node.Function)
+		if isError(fun) {
+			return fun
+		}
+
+		// applyFunction makes the call once this function returns
+		if function, ok := fun.(*object.Func); ok && node.IsTail && !function.IsGenerator {
+			return &object.TailCall{Fun: function, Args: args, Site: position(scope, node.Token)}
+		}
 
-		return applyFunction(scope, fun, args)
+		return in.applyFunction(scope, node.Token, fun, args)
 
 	case *ast.VarStatement:
-		val := Eval(scope, node.Value)
-		scope.Set(node.Name.String(), val)
-		return val
+		val := in.Eval(scope, node.Value)
+		if isError(val) {
+			return val
+		}
+
+		return declare(scope, node.Name, val)
+
+	case *ast.ImportStatement:
+		return in.evalImportStatement(scope, node)
+
+	case *ast.EnumStatement:
+		return scope.Set(node.Name.String(), NewEnum(node))
 
 	case *ast.Identifier:
-		// try the intrinsic functions first
-		ident, ok := object.IntrinsicFuncs[node.String()]
+		return in.evalIdentifier(scope, node)
 
-		if ok {
-			return ident
+	case *ast.PrefixExpression:
+		right := in.Eval(scope, node.Right)
+		if isError(right) {
+			return right
 		}
 
-		return scope.Get(node.String())
+		if result, ok := in.evalPrefixHook(scope, node.Token, node.Operator, right); ok {
+			return result
+		}
 
-	case *ast.PrefixExpression:
-		right := Eval(scope, node.Right)
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(scope, node.Left)
-		right := Eval(scope, node.Right)
-		return evalInfixExpression(node.Operator, left, right)
+		if node.Operator == "and" || node.Operator == "or" {
+			return in.evalLogicalExpression(scope, node)
+		}
+
+		left := in.Eval(scope, node.Left)
+		if isError(left) {
+			return left
+		}
+
+		right := in.Eval(scope, node.Right)
+		if isError(right) {
+			return right
+		}
+
+		if result, ok := in.evalInfixHook(scope, node.Token, node.Operator, left, right); ok {
+			return result
+		}
+
+		if b := in.budget; b != nil {
+			if err := b.checkOperation(node.Operator, left, right); err != nil {
+				return err
+			}
+		}
+
+		return evalInfixExpression(scope.Frame(), node.Operator, left, right, in.strict(scope))
+
+	case *ast.ThrowExpression:
+		val := in.Eval(scope, node.Value)
+		if isError(val) {
+			return val
+		}
+
+		return object.Throw(scope.Frame(), val)
+
+	case *ast.TryExpression:
+		return in.evalTryExpression(scope, node)
+
+	case *ast.SpawnExpression:
+		return in.evalSpawnExpression(scope, node)
+
+	case *ast.YieldExpression:
+		val := in.Eval(scope, node.Value)
+		if isError(val) {
+			return val
+		}
+
+		if !scope.InGenerator() {
+			return object.NewError(object.RUNTIME_ERROR, "yield outside of a generator")
+		}
+
+		// nobody is going to ask for more values,
+		// so return out of the generator body
+		if !scope.Yield(val) {
+			return &object.Return{Value: object.Null}
+		}
+
+		return object.Null
 
 	case *ast.IfExpression:
-		cond := Eval(scope, node.Condition)
-		return evalIfExpression(scope, cond.IsTruthy(), node.Consequence, node.Alternative)
+		cond := in.Eval(scope, node.Condition)
+		if isError(cond) {
+			return cond
+		}
+
+		if cond.Type() != object.BOOL && in.strict(scope) {
+			return object.NewError(object.TYPE_ERROR, "if condition must be a BOOL in strict mode, got %s", cond.Type())
+		}
+
+		return in.evalIfExpression(scope, cond.IsTruthy(), node.Consequence, node.Alternative)
 
 	default:
-		return &object.Nil{}
+		return object.Null
 	}
 }
 
-func evalProgram(scope *object.Scope, program *ast.Program) object.Object {
+func (in *Interpreter) evalProgram(scope *object.Scope, program *ast.Program) object.Object {
 	var result object.Object
 
+	if IsStrict(program) {
+		scope.SetStrict()
+	}
+
 	for _, stmt := range program.Statements {
-		result = Eval(scope, stmt)
+		result = in.Eval(scope, stmt)
 
 		if val, ok := result.(*object.Return); ok {
 			return val.Value
 		}
+
+		if isError(result) {
+			return result
+		}
 	}
 
 	return result
 }
 
-func evalBlock(scope *object.Scope, block *ast.BlockStatement) object.Object {
-	var result object.Object
+// IsStrict tells whether the file starts with "use strict";
+func IsStrict(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	str, ok := stmt.Expression.(*ast.String)
+	return ok && string(str.Value) == "use strict"
+}
+
+func (in *Interpreter) evalBlock(scope *object.Scope, block *ast.BlockStatement) object.Object {
+	// an empty block evaluates to nil
+	var result object.Object = object.Null
 
 	for _, stmt := range block.Statements {
-		result = Eval(scope, stmt)
+		result = in.Eval(scope, stmt)
 
-		if result.Type() == object.RETURN {
+		if result.Type() == object.RETURN || result.Type() == object.ERROR {
 			return result
 		}
 	}
@@ -149,78 +309,275 @@ func evalBlock(scope *object.Scope, block *ast.BlockStatement) object.Object {
 	return result
 }
 
+// evalExpressions evaluates the expressions left to right. If one of
+// them fails, the error is the only thing that gets returned
+func (in *Interpreter) evalExpressions(scope *object.Scope, expressions []ast.Expression) []object.Object {
+	result := make([]object.Object, len(expressions))
+
+	for i, expr := range expressions {
+		result[i] = in.Eval(scope, expr)
+		if isError(result[i]) {
+			return []object.Object{result[i]}
+		}
+	}
+
+	return result
+}
+
+// evalIdentifier goes straight to where the resolver found the
+// variable. Names it couldn't find, and variables that haven't been
+// declared yet, are looked up by name, as if they weren't resolved
+func (in *Interpreter) evalIdentifier(scope *object.Scope, node *ast.Identifier) object.Object {
+	switch binding := node.Binding; binding.Kind {
+	case ast.Intrinsic:
+		if intrinsic, ok := in.intrinsics[node.String()]; ok {
+			return intrinsic
+		}
+
+	case ast.Local:
+		if val := scope.Up(binding.Depth).Slot(binding.Slot); val != nil {
+			return val
+		}
+
+	case ast.Global:
+		if global := scope.Up(binding.Depth); global != nil {
+			if val, ok := global.Get(node.String()); ok {
+				return val
+			}
+		}
+	}
+
+	return in.lookup(scope, node)
+}
+
+func (in *Interpreter) lookup(scope *object.Scope, node *ast.Identifier) object.Object {
+	name := node.String()
+
+	// try the intrinsic functions first
+	if intrinsic, ok := in.intrinsics[name]; ok {
+		return intrinsic
+	}
+
+	if val, ok := scope.Get(name); ok {
+		return val
+	}
+
+	candidates := scope.Names()
+	for intrinsic := range in.intrinsics {
+		candidates = append(candidates, intrinsic)
+	}
+
+	if suggestion := object.Suggest(name, candidates); suggestion != "" {
+		return object.NewError(object.NAME_ERROR, "undefined variable '%s' (did you mean '%s'?)", name, suggestion)
+	}
+
+	return object.NewError(object.NAME_ERROR, "undefined variable '%s'", name)
+}
+
+// declare sets the variable in scope, in the slot
+// the resolver gave it if there's one
+func declare(scope *object.Scope, name *ast.Identifier, val object.Object) object.Object {
+	if name.Binding.Kind == ast.Local {
+		return scope.SetSlot(name.Binding.Slot, val)
+	}
+
+	return scope.Set(name.String(), val)
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	if operator == "not" {
-		return &object.Boolean{Value: !right.IsTruthy()}
+		return object.NewBoolean(!right.IsTruthy())
 	}
 
 	if operator == "-" && right.Type() == object.INT {
-		return &object.Integer{Value: -right.(*object.Integer).Value}
+		return object.NewInteger(-right.(*object.Integer).Value)
 	}
 
-	return &object.Nil{}
+	return object.NewError(object.TYPE_ERROR, "unsupported operand type for %s: %s", operator, right.Type())
 }
 
-// if left value is bool, all is bool
-// if left value is int, all is int
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	if left.Type() == object.STRING && right.Type() == object.STRING {
-		switch operator {
-		case "+":
-			return &object.String{
-				Value: append(left.(*object.String).Value, right.(*object.String).Value...),
-			}
-		case "==":
-			return &object.Boolean{
-				Value: string(left.(*object.String).Value) == string(right.(*object.String).Value),
-			}
-		case "!=":
-			return &object.Boolean{
-				Value: string(left.(*object.String).Value) != string(right.(*object.String).Value),
-			}
-		}
-		return &object.Nil{}
+// and & or only evaluate the right operand if they need to
+func (in *Interpreter) evalLogicalExpression(scope *object.Scope, node *ast.InfixExpression) object.Object {
+	left := in.Eval(scope, node.Left)
+	if isError(left) {
+		return left
 	}
 
-	if left.Type() == object.INT {
-		// transform right value to integer
-		if right.Type() == object.BOOL {
-			var btoi int64
-			if right.(*object.Boolean).Value {
-				btoi = 1
-			}
+	if node.Operator == "and" && !left.IsTruthy() {
+		return object.False
+	}
+	if node.Operator == "or" && left.IsTruthy() {
+		return object.True
+	}
 
-			right = &object.Integer{Value: btoi}
-		}
+	right := in.Eval(scope, node.Right)
+	if isError(right) {
+		return right
+	}
+
+	return object.NewBoolean(right.IsTruthy())
+}
+
+// ints and bools can be mixed, a bool counts as 0 or 1,
+// unless the code is strict. Anything that has no rule
+// of its own is a TypeError. caller is the frame the __eq__
+// hooks of the operands are called on behalf of
+func evalInfixExpression(caller *object.Frame, operator string, left object.Object, right object.Object, strict bool) object.Object {
+	// equality works the same for every type, see object.Equal
+	switch operator {
+	case "==":
+		return object.NewBoolean(object.EqualFrom(caller, left, right))
+	case "!=":
+		return object.NewBoolean(!object.EqualFrom(caller, left, right))
+	case "is":
+		return object.NewBoolean(object.Identical(left, right))
+	case "in":
+		return evalInExpression(caller, left, right)
+	}
 
+	leftType, rightType := left.Type(), right.Type()
+
+	switch {
+	case leftType == object.INT && rightType == object.INT:
 		return evalIntegerInfixExpression(operator, left, right)
+
+	case !strict && (leftType == object.INT || leftType == object.BOOL) &&
+		(rightType == object.INT || rightType == object.BOOL) &&
+		(leftType == object.INT || rightType == object.INT):
+		return evalIntegerInfixExpression(operator, toInteger(left), toInteger(right))
+
+	case leftType == object.STRING && rightType == object.STRING:
+		return evalStringInfixExpression(operator, left.(*object.String), right.(*object.String))
+
+	case leftType == object.LIST && rightType == object.LIST && operator == "+":
+		items := append(left.(*object.List).Elements(), right.(*object.List).Elements()...)
+		return object.NewList(items...)
+
+	case operator == "*" && rightType == object.INT && (leftType == object.STRING || leftType == object.LIST):
+		return evalRepetition(left, right.(*object.Integer).Value)
+
+	case operator == "*" && leftType == object.INT && (rightType == object.STRING || rightType == object.LIST):
+		return evalRepetition(right, left.(*object.Integer).Value)
 	}
 
-	// left value is bool or nil
-	leftBool := &object.Boolean{Value: left.IsTruthy()}
-	rightBool := &object.Boolean{Value: right.IsTruthy()}
+	return unsupportedOperands(operator, left, right)
+}
 
-	return evalBooleanInfixExpression(operator, leftBool, rightBool)
+func unsupportedOperands(operator string, left object.Object, right object.Object) *object.Error {
+	return object.NewError(object.TYPE_ERROR, "unsupported operand types for %s: %s and %s",
+		operator, left.Type(), right.Type())
+}
 
-	// return &object.Nil{}
+func toInteger(obj object.Object) *object.Integer {
+	if boolean, ok := obj.(*object.Boolean); ok {
+		if boolean.Value {
+			return object.NewInteger(1)
+		}
+		return object.NewInteger(0)
+	}
+
+	return obj.(*object.Integer)
 }
 
-func evalBooleanInfixExpression(
-	operator string,
-	left object.Object, right object.Object,
-) object.Object {
-	leftBool := left.(*object.Boolean).Value
-	rightBool := right.(*object.Boolean).Value
+// strings are compared character by character
+func evalStringInfixExpression(operator string, left *object.String, right *object.String) object.Object {
+	leftStr, rightStr := string(left.Value), string(right.Value)
 
-	if operator == "==" {
-		return &object.Boolean{Value: leftBool == rightBool}
+	switch operator {
+	case "+":
+		return &object.String{Value: []rune(leftStr + rightStr)}
+	case "<":
+		return object.NewBoolean(leftStr < rightStr)
+	case ">":
+		return object.NewBoolean(leftStr > rightStr)
+	case "<=":
+		return object.NewBoolean(leftStr <= rightStr)
+	case ">=":
+		return object.NewBoolean(leftStr >= rightStr)
+	}
+
+	return unsupportedOperands(operator, left, right)
+}
+
+// longest a repetition can make, budget or not. Running out of
+// memory takes the whole process down, rather than failing the
+// script, so this stays well within what can be allocated
+const maxRepeatedLength = 1 << 28
+
+// "ab" * 3 is "ababab", [0] * 3 is [0, 0, 0]
+func evalRepetition(obj object.Object, times int64) object.Object {
+	if times < 0 {
+		return object.NewError(object.ARGUMENT_ERROR, "can't repeat a %s %d times", obj.Type(), times)
 	}
 
-	if operator == "!=" {
-		return &object.Boolean{Value: leftBool != rightBool}
+	str, isString := obj.(*object.String)
+
+	size, units := 0, "items"
+	if isString {
+		size, units = len(str.Value), "characters"
+	} else {
+		size = obj.(*object.List).Len()
+	}
+
+	if size == 0 {
+		// nothing repeated any number of times is still nothing
+		times = 0
+	} else if times > maxRepeatedLength/int64(size) {
+		return object.NewError(object.ARGUMENT_ERROR, "can't repeat a %s of %d %s %d times, the result would be too long",
+			obj.Type(), size, units, times)
+	}
+
+	if isString {
+		return &object.String{Value: slices.Repeat(str.Value, int(times))}
 	}
 
-	return &object.Nil{}
+	return object.NewList(slices.Repeat(obj.(*object.List).Elements(), int(times))...)
+}
+
+// x in collection
+func evalInExpression(caller *object.Frame, obj object.Object, collection object.Object) object.Object {
+	switch collection := collection.(type) {
+	case *object.String:
+		str, ok := obj.(*object.String)
+		if !ok {
+			return object.NewError(object.TYPE_ERROR, "'in <STRING>' expects a STRING on the left, got %s", obj.Type())
+		}
+		return object.NewBoolean(strings.Contains(string(collection.Value), string(str.Value)))
+
+	case *object.List:
+		return object.NewBoolean(containsEqual(caller, collection.Elements(), obj))
+
+	case *object.Tuple:
+		return object.NewBoolean(containsEqual(caller, collection.Items, obj))
+
+	case *object.Set:
+		return object.NewBoolean(collection.Has(obj))
+
+	case *object.Dict:
+		// the same keys dict.key and dict[key] would find
+		if str, ok := obj.(*object.String); ok {
+			_, _, found := collection.Lookup(string(str.Value))
+			return object.NewBoolean(found)
+		}
+		_, found := collection.GetKey(obj)
+		return object.NewBoolean(found)
+	}
+
+	return object.NewError(object.TYPE_ERROR, "'in' expects a STRING, LIST, TUPLE, SET or DICT on the right, got %s", collection.Type())
+}
+
+func containsEqual(caller *object.Frame, items []object.Object, obj object.Object) bool {
+	for _, item := range items {
+		if object.EqualFrom(caller, item, obj) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func evalIntegerInfixExpression(
@@ -232,68 +589,286 @@ func evalIntegerInfixExpression(
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftInt + rightInt}
+		return object.NewInteger(leftInt + rightInt)
 	case "-":
-		return &object.Integer{Value: leftInt - rightInt}
+		return object.NewInteger(leftInt - rightInt)
 	case "*":
-		return &object.Integer{Value: leftInt * rightInt}
+		return object.NewInteger(leftInt * rightInt)
 	case "/":
-		return &object.Integer{Value: leftInt / rightInt}
+		if rightInt == 0 {
+			return object.NewError(object.ZERO_DIVISION, "division by zero")
+		}
+		return object.NewInteger(leftInt / rightInt)
 	case "%":
-		return &object.Integer{Value: leftInt % rightInt}
+		if rightInt == 0 {
+			return object.NewError(object.ZERO_DIVISION, "modulo by zero")
+		}
+		return object.NewInteger(leftInt % rightInt)
 
 	case "==":
-		return &object.Boolean{Value: leftInt == rightInt}
+		return object.NewBoolean(leftInt == rightInt)
 	case "!=":
-		return &object.Boolean{Value: leftInt != rightInt}
+		return object.NewBoolean(leftInt != rightInt)
 
 	case ">":
-		return &object.Boolean{Value: leftInt > rightInt}
+		return object.NewBoolean(leftInt > rightInt)
 	case "<":
-		return &object.Boolean{Value: leftInt < rightInt}
+		return object.NewBoolean(leftInt < rightInt)
 	case ">=":
-		return &object.Boolean{Value: leftInt >= rightInt}
+		return object.NewBoolean(leftInt >= rightInt)
 	case "<=":
-		return &object.Boolean{Value: leftInt <= rightInt}
+		return object.NewBoolean(leftInt <= rightInt)
 	}
 
-	return &object.Nil{}
+	return unsupportedOperands(operator, left, right)
 }
 
-func evalIfExpression(scope *object.Scope, cond bool, consequence *ast.BlockStatement, alternative *ast.BlockStatement) object.Object {
+func (in *Interpreter) evalIfExpression(scope *object.Scope, cond bool, consequence *ast.BlockStatement, alternative *ast.BlockStatement) object.Object {
 	if cond {
-		return Eval(scope, consequence)
+		return in.Eval(scope, consequence)
 	}
 
 	if alternative != nil {
-		return Eval(scope, alternative)
+		return in.Eval(scope, alternative)
+	}
+
+	return object.Null
+}
+
+// the value of a try expression is the value of the try block,
+// or the value of the catch block if something was thrown.
+// finally runs no matter what, and only gets the last word
+// if it returns or throws itself
+func (in *Interpreter) evalTryExpression(scope *object.Scope, node *ast.TryExpression) object.Object {
+	result := in.Eval(scope, node.Block)
+
+	if err, ok := result.(*object.Error); ok && node.Catch != nil && err.Catchable() {
+		catchScope := object.NewBlockScope(scope, node.Catch.Slots)
+		if node.CatchParam != nil {
+			declare(catchScope, node.CatchParam, err.Caught())
+		}
+
+		result = in.evalBlock(catchScope, node.Catch)
+	}
+
+	if node.Finally != nil {
+		finally := in.Eval(scope, node.Finally)
+
+		if finally.Type() == object.RETURN || finally.Type() == object.ERROR {
+			return finally
+		}
 	}
 
-	return &object.Nil{}
+	return result
+}
+
+// the function and its args are evaluated right away,
+// only the call itself happens on a different goroutine
+func (in *Interpreter) evalSpawnExpression(scope *object.Scope, node *ast.SpawnExpression) object.Object {
+	args := in.evalExpressions(scope, node.Call.Arguments)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	fun := in.Eval(scope, node.Call.Function)
+	if isError(fun) {
+		return fun
+	}
+
+	return object.Spawn(func() object.Object {
+		return in.applyFunction(scope, node.Call.Token, fun, args)
+	})
 }
 
-func applyFunction(scope *object.Scope, fun object.Object, args []object.Object) object.Object {
+func evalIndexExpression(collection object.Object, index object.Object) object.Object {
+	switch collection := collection.(type) {
+	case *object.List:
+		return indexItems("list", collection.Elements(), index)
+
+	case *object.Tuple:
+		return indexItems("tuple", collection.Items, index)
+
+	case *object.Dict:
+		if str, ok := index.(*object.String); ok {
+			return lookupProperty(collection, collection, string(str.Value))
+		}
+
+		if _, ok := object.Hash(index); !ok {
+			return object.NewError(object.TYPE_ERROR, "unhashable type: %s", index.Type())
+		}
+
+		val, ok := collection.GetKey(index)
+		if !ok {
+			return object.Null
+		}
+		return val
+	}
+
+	return object.Null
+}
+
+func indexItems(kind string, items []object.Object, index object.Object) object.Object {
+	if index.Type() != object.INT {
+		return object.Null
+	}
+
+	idx := index.(*object.Integer).Value
+	if idx < 0 || idx >= int64(len(items)) {
+		return object.NewError(object.INDEX_ERROR, "index %d out of range for a %s of length %d", idx, kind, len(items))
+	}
+
+	return items[idx]
+}
+
+func evalPropertyAccess(obj object.Object, property string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Dict:
+		return lookupProperty(obj, obj, property)
+
+	case *object.Super:
+		if obj.Proto == nil {
+			return object.NewError(object.TYPE_ERROR, "super used in a method of a dict with no prototype")
+		}
+		return lookupProperty(obj.Self, obj.Proto, property)
+
+	case *object.Module:
+		val, ok := obj.Get(property)
+		if !ok {
+			return object.NewError(object.NAME_ERROR, "module '%s' does not export '%s'",
+				filepath.Base(obj.Path), property)
+		}
+		return val
+
+	case *object.Enum:
+		val, ok := obj.Get(property)
+		if !ok {
+			return obj.NoVariant(property)
+		}
+		return val
+
+	case *object.Variant:
+		val, ok := obj.Field(property)
+		if !ok {
+			return object.NewError(object.NAME_ERROR, "%s.%s has no field '%s'", obj.Enum.Name, obj.Tag, property)
+		}
+		return val
+	}
+
+	return object.Null
+}
+
+// lookupProperty looks the property up the prototype chain of dict.
+// Methods remember self, whether they get called right away or later on
+func lookupProperty(self object.Object, dict *object.Dict, property string) object.Object {
+	val, ok := dict.Method(self, property)
+	if !ok {
+		return object.Null
+	}
+
+	return val
+}
+
+// applyFunction calls fun on behalf of the code running in scope.
+// at is the token the call is made at, e.g. the '(' or the operator
+func (in *Interpreter) applyFunction(scope *object.Scope, at token.Token, fun object.Object, args []object.Object) object.Object {
+	return in.apply(scope.Frame(), position(scope, at), fun, args)
+}
+
+// apply calls fun on behalf of the caller frame, from site. Intrinsics
+// get the frame too, and make their own calls on behalf of it
+func (in *Interpreter) apply(caller *object.Frame, site object.Position, fun object.Object, args []object.Object) object.Object {
+	if hook, ok := Metamethod(fun, "__call__"); ok {
+		return in.apply(caller, site, hook, args)
+	}
+
 	if fun.Type() == object.INTRINSIC_FUNC {
-		return fun.(object.IntrinsicFunc)(args...)
+		result := fun.(object.IntrinsicFunc)(caller, args...)
+		if b := in.budget; b != nil {
+			return b.meter(result)
+		}
+		return result
 	}
 
 	if fun.Type() != object.FUNC {
-		return &object.Nil{}
+		return object.NewError(object.TYPE_ERROR, "%s is not a function", fun.Type())
+	}
+
+	// a trampoline: the tail calls functions end with
+	// are made here, one after the other, all of them
+	// taking the place of the first call in the stack
+	for {
+		result := in.callFunction(caller, site, fun.(*object.Func), args)
+
+		tail, ok := result.(*object.TailCall)
+		if !ok {
+			return result
+		}
+		fun, args, site = tail.Fun, tail.Args, tail.Site
+	}
+}
+
+func (in *Interpreter) callFunction(caller *object.Frame, site object.Position, function *object.Func, args []object.Object) object.Object {
+	frame := object.NewFrame(function.Name, len(args), site, caller)
+	if frame.Depth > in.MaxDepth {
+		return withStack(in.stackOverflow(frame), frame)
+	}
+
+	if len(args) != len(function.Params) {
+		return withStack(object.NewError(object.ARGUMENT_ERROR,
+			"function expects %d argument(s), got %d", len(function.Params), len(args)), frame)
 	}
 
-	function := fun.(*object.Func)
-	localScope := object.NewLocalScope(scope)
+	localScope := object.NewBlockScope(function.Scope, function.Body.Slots)
+	localScope.SetFrame(frame)
+
+	// a param named self or super wins over the receiver
+	if function.Self != nil {
+		localScope.Set("self", function.Self)
+
+		super := &object.Super{Self: function.Self}
+		if function.Home != nil {
+			super.Proto = function.Home.Proto
+		}
+		localScope.Set("super", super)
+	}
 
 	for i, param := range function.Params {
-		p := param.String()
-		localScope.Set(p, args[i])
+		declare(localScope, param, args[i])
+	}
+
+	// the body only holds onto the body of the function, not the
+	// function itself, which would hold the scope it closes over
+	if function.IsGenerator {
+		localScope.HoldOuterWeakly()
+		body := function.Body
+
+		return object.NewGenerator(function.Scope, frame, func(yield func(object.Object) bool) object.Object {
+			localScope.SetYield(yield)
+			return withStack(in.evalBlock(localScope, body), frame)
+		})
 	}
 
-	result := Eval(localScope, function.Body)
+	// params and the function body share the same scope
+	result := in.evalBlock(localScope, function.Body)
 
 	if result.Type() == object.RETURN {
 		return result.(*object.Return).Value
 	}
 
+	return withStack(result, frame)
+}
+
+// withStack records the frame an error is leaving on its way up,
+// unless it has already left a frame deeper down the stack
+func withStack(result object.Object, frame *object.Frame) object.Object {
+	if err, ok := result.(*object.Error); ok && err.Stack == nil {
+		err.Stack = frame
+	}
+
 	return result
 }
+
+// position is where the token is, in the file the scope belongs to
+func position(scope *object.Scope, at token.Token) object.Position {
+	return object.Position{File: scope.File(), Line: at.Line, Column: at.Column}
+}
@@ -1,12 +1,17 @@
 package eval_test
 
 import (
+	"runtime"
+	"runtime/debug"
 	"testing"
+	"time"
 
+	"github.com/aziflaj/pingul/ast"
 	"github.com/aziflaj/pingul/eval"
 	"github.com/aziflaj/pingul/lexer"
 	"github.com/aziflaj/pingul/object"
 	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
 )
 
 func TestEvalInt(t *testing.T) {
@@ -99,11 +104,11 @@ func TestLists(t *testing.T) {
 			t.Fatalf("Object is not a List. Got=%T", evaluated)
 		}
 
-		if len(list.Items) != len(tc.expected) {
-			t.Fatalf("List has wrong length. Got=%d, Expected=%d", len(list.Items), len(tc.expected))
+		if len(list.Elements()) != len(tc.expected) {
+			t.Fatalf("List has wrong length. Got=%d, Expected=%d", len(list.Elements()), len(tc.expected))
 		}
 
-		for i, item := range list.Items {
+		for i, item := range list.Elements() {
 			integer, ok := item.(*object.Integer)
 			if !ok {
 				t.Fatalf("Object is not an Integer. Got=%T", item)
@@ -117,6 +122,57 @@ func TestLists(t *testing.T) {
 	}
 }
 
+func TestListValueSemantics(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected [][]int64
+	}{
+		// lists built from the same list don't see each other
+		{`var a = [1, 2, 3]; var b = tail(a); var c = append(b, 9); var d = append(b, 8); [a, b, c, d];`,
+			[][]int64{{1, 2, 3}, {2, 3}, {2, 3, 9}, {2, 3, 8}}},
+		{`var a = [1]; var b = prepend(a, 0); var c = prepend(a, 5); [a, b, c];`,
+			[][]int64{{1}, {0, 1}, {5, 1}}},
+		{`var a = [1, 2]; var b = prepend(append(a, 3), 0); var c = append(prepend(a, 9), 8); [a, b, c];`,
+			[][]int64{{1, 2}, {0, 1, 2, 3}, {9, 1, 2, 8}}},
+		// pop and shift change only the list they're given
+		{`var a = [1, 2, 3]; var b = append(a, 4); pop(a); pop(a); var c = append(a, 7); [a, b, c];`,
+			[][]int64{{1}, {1, 2, 3, 4}, {1, 7}}},
+		{`var a = [1, 2, 3]; var b = tail(a); shift(b); shift(a); [a, b];`,
+			[][]int64{{2, 3}, {3}}},
+		{`var a = [1, 2]; var b = a; pop(b); [a, b];`,
+			[][]int64{{1}, {1}}},
+		{`var a = []; var b = append(a, 1); var c = prepend(a, 2); [a, b, c, tail([1])];`,
+			[][]int64{{}, {1}, {2}, {}}},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		lists, ok := evaluated.(*object.List)
+		if !ok {
+			t.Fatalf("Object is not a List. Got=%T (%v)", evaluated, evaluated)
+		}
+
+		for i, list := range lists.Elements() {
+			assertIntegerList(t, list, tc.expected[i])
+		}
+	}
+
+	// long lists are built one item at a time from both ends
+	evaluated := evalProgram(`
+var build = func(list, n) {
+	if (n == 0) {
+		return list;
+	}
+	if (n % 2 == 0) {
+		return build(append(list, n), n - 1);
+	}
+	build(prepend(list, n), n - 1);
+};
+var long = build([], 1000);
+[len(long), head(long), long[999], long[500]];`)
+	assertIntegerList(t, evaluated, []int64{1000, 1, 2, 1000})
+}
+
 func TestListIndex(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -173,9 +229,12 @@ func TestInfixBool(t *testing.T) {
 		{"false == false", true},
 		{"false == true", false},
 
-		{"true == 1", true},
-		{"true == 5", true},
+		// values of different types are never equal
+		{"true == 1", false},
+		{"true == 5", false},
 		{"true == 0", false},
+		{"1 == true", false},
+		{"3 == true", false},
 
 		{"false == 1", false},
 		{"false == 5", false},
@@ -220,80 +279,1198 @@ func TestInfixIntOperations(t *testing.T) {
 		{"5 + 5 * 5 - 5", 25},
 	}
 
-	for _, tc := range intEvaledTestCases {
+	for _, tc := range intEvaledTestCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+
+	boolEvaledTestCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"5 == 5", true},
+		{"5 == 1", false},
+		{"5 != 5", false},
+		{"5 != 1", true},
+
+		{"nil == nil", true},
+		{"nil != nil", false},
+
+		{"5 > 5", false},
+		{"5 > 1", true},
+		{"5 < 5", false},
+		{"5 < 1", false},
+		{"5 >= 5", true},
+		{"5 >= 1", true},
+		{"5 <= 5", true},
+		{"5 <= 1", false},
+	}
+
+	for _, tc := range boolEvaledTestCases {
+		evaluated := evalProgram(tc.input)
+		assertBooleanObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestEquality(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{`[1] == [2];`, false},
+		{`[1, 2] == [1, 2];`, true},
+		{`[1, [2, [3]]] == [1, [2, [3]]];`, true},
+		{`[1, [2, [3]]] == [1, [2, [4]]];`, false},
+		{`[1, 2] == [1, 2, 3];`, false},
+		{`[] == [];`, true},
+		{`[1] != [2];`, true},
+		{`{a: 1} == {b: 2};`, false},
+		{`{a: 1, b: [1, 2]} == {b: [1, 2], a: 1};`, true},
+		{`{a: 1} == {a: 1, b: 2};`, false},
+		{`{[(1, 2)]: 1} == {[(1, 2)]: 1};`, true},
+		{`{[(1, 2)]: 1} == {[(2, 1)]: 1};`, false},
+		{`{} == {};`, true},
+		// the prototype is part of what a dict is
+		{`var p = {}; extend(p, {a: 1}) == extend(p, {a: 1});`, true},
+		{`extend({}, {a: 1}) == {a: 1};`, false},
+		{`nil == nil;`, true},
+		{`nil == false;`, false},
+		{`nil == 0;`, false},
+		{`nil == [];`, false},
+		{`"1" == 1;`, false},
+		{`[1] == (1,);`, false},
+		// functions are equal if they are the same literal in the same scope
+		{`var f = func() { 1 }; f == f;`, true},
+		{`func() { 1 } == func() { 1 };`, false},
+		{`var make = func() { func() { 1 } }; make() == make();`, false},
+		{`var obj = { f: func() { self } }; obj.f == obj.f;`, true},
+		{`var f = func() { self }; bind(f, {}) == bind(f, {});`, false},
+		{`len == len;`, true},
+		{`len == head;`, false},
+		// __eq__ hooks are used inside lists and dicts too
+		{`var anything = { __eq__: func(other) { true } }; [anything] == [{}];`, true},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if _, ok := evaluated.(*object.Boolean); !ok {
+			t.Fatalf("Expected a Boolean for %q. Got=%s", tc.input, evaluated.Inspect())
+		}
+		if evaluated.(*object.Boolean).Value != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%t", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestEqualityOfSelfReferencingValues(t *testing.T) {
+	// there's no syntax for building a value that contains itself,
+	// but values built in Go can, so Equal must not loop forever
+	a := &object.Dict{Pairs: map[string]object.Object{}}
+	a.Pairs["self"] = a
+	b := &object.Dict{Pairs: map[string]object.Object{}}
+	b.Pairs["self"] = b
+
+	if !object.Equal(a, b) {
+		t.Errorf("Expected dicts containing themselves to be equal")
+	}
+
+	c := &object.Dict{Pairs: map[string]object.Object{"x": &object.Integer{Value: 1}}}
+	c.Pairs["self"] = c
+
+	if object.Equal(a, c) {
+		t.Errorf("Expected dicts with different keys to be different")
+	}
+
+	list := object.NewList(a)
+	if !object.Equal(object.NewList(b), list) {
+		t.Errorf("Expected lists of dicts containing themselves to be equal")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{`var a = [1]; var b = a; a is b;`, true},
+		{`[1] is [1];`, false},
+		{`var a = [1]; a is tail(prepend(a, 0));`, false},
+		{`var d = {}; d is d;`, true},
+		{`{} is {};`, false},
+		{`nil is nil;`, true},
+		{`var x = nil; x is nil;`, true},
+		{`true is true;`, true},
+		{`1 is 1;`, true},
+		{`1 is true;`, false},
+		{`"a" is "a";`, true},
+		{`var f = func() { 1 }; f is f;`, true},
+		// every access binds the method anew
+		{`var obj = { f: func() { self } }; obj.f is obj.f;`, false},
+		{`var obj = { f: func() { self } }; obj.f() is obj;`, true},
+		{`len is len;`, true},
+		{`(1, 2) is (1, 2);`, false},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if _, ok := evaluated.(*object.Boolean); !ok {
+			t.Fatalf("Expected a Boolean for %q. Got=%s", tc.input, evaluated.Inspect())
+		}
+		if evaluated.(*object.Boolean).Value != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%t", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestStringAndListOperators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc" < "abd";`, "BOOL(true)"},
+		{`"abc" < "ab";`, "BOOL(false)"},
+		{`"b" > "abc";`, "BOOL(true)"},
+		{`"a" <= "a";`, "BOOL(true)"},
+		{`"B" >= "a";`, "BOOL(false)"},
+		{`"ab" * 3;`, "STRING(ababab)"},
+		{`3 * "ab";`, "STRING(ababab)"},
+		{`"ab" * 0;`, "STRING()"},
+		{`"" * 9223372036854775807;`, "STRING()"},
+		{`[1, 2] + [3];`, "[INT(1), INT(2), INT(3)]"},
+		{`[] + [];`, "[]"},
+		{`[0] * 3;`, "[INT(0), INT(0), INT(0)]"},
+		{`2 * [1, 2];`, "[INT(1), INT(2), INT(1), INT(2)]"},
+		{`var a = [1]; var b = a + [2]; [a, b];`, "[[INT(1)], [INT(1), INT(2)]]"},
+		// bools still count as 0 or 1 next to an int
+		{`5 + true;`, "INT(6)"},
+		{`true + 5;`, "INT(6)"},
+		{`10 * false;`, "INT(0)"},
+		{`10 + not false;`, "INT(11)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestInOperator(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{`"ell" in "hello";`, true},
+		{`"" in "hello";`, true},
+		{`"lo!" in "hello";`, false},
+		{`2 in [1, 2, 3];`, true},
+		{`[2] in [1, [2], 3];`, true},
+		{`4 in [1, 2, 3];`, false},
+		{`"2" in [1, 2, 3];`, false},
+		{`1 in (1, 2);`, true},
+		{`(1, 2) in #{(1, 2)};`, true},
+		{`3 in #{1, 2};`, false},
+		{`"a" in {a: 1};`, true},
+		{`"b" in {a: 1};`, false},
+		{`(0, 0) in {[(0, 0)]: 1};`, true},
+		{`"a" in extend({a: 1});`, true},
+		{`1 + 1 in [2] and "a" < "b";`, true},
+		{`var evens = { __contains__: func(n) { n % 2 == 0 } }; 4 in evens;`, true},
+		{`var evens = { __contains__: func(n) { n % 2 == 0 } }; 5 in evens;`, false},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertBooleanObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{`true and false;`, false},
+		{`true and true;`, true},
+		{`true or false;`, true},
+		{`false or false;`, false},
+		{`1 and true;`, true},
+		{`not true and not false;`, false},
+		{`[] or "a";`, true},
+		// the right operand is only evaluated when needed
+		{`false and undefined_function();`, false},
+		{`true or undefined_function();`, true},
+	}
+
+	for _, tc := range testCases {
+		program := parseProgram(tc.input)
+		evaluated := eval.Eval(object.NewScope(), program)
+		assertBooleanObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestUnsupportedOperands(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + "a";`, "unsupported operand types for +: INT and STRING"},
+		{`"a" - "b";`, "unsupported operand types for -: STRING and STRING"},
+		{`[1] - [1];`, "unsupported operand types for -: LIST and LIST"},
+		{`[1] + 1;`, "unsupported operand types for +: LIST and INT"},
+		{`"a" * "b";`, "unsupported operand types for *: STRING and STRING"},
+		{`true + true;`, "unsupported operand types for +: BOOL and BOOL"},
+		{`nil + 1;`, "unsupported operand types for +: NIL and INT"},
+		{`{} < {};`, "unsupported operand types for <: DICT and DICT"},
+		{`1 < "a";`, "unsupported operand types for <: INT and STRING"},
+		{`"a" * -1;`, "can't repeat a STRING -1 times"},
+		{`"ab" * 9223372036854775807;`, "can't repeat a STRING of 2 characters 9223372036854775807 times, the result would be too long"},
+		{`[1, 2] * 4611686018427387904;`, "can't repeat a LIST of 2 items 4611686018427387904 times, the result would be too long"},
+		{`"a" * 100000000000;`, "can't repeat a STRING of 1 characters 100000000000 times, the result would be too long"},
+		{`[0] * 268435457;`, "can't repeat a LIST of 1 items 268435457 times, the result would be too long"},
+		{`-"a";`, "unsupported operand type for -: STRING"},
+		{`1 in 1;`, "'in' expects a STRING, LIST, TUPLE, SET or DICT on the right, got INT"},
+		{`1 in "abc";`, "'in <STRING>' expects a STRING on the left, got INT"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`5 + true;`, "ERROR(TypeError: unsupported operand types for +: INT and BOOL)"},
+		{`false * 10;`, "ERROR(TypeError: unsupported operand types for *: BOOL and INT)"},
+		{`10 + not false;`, "ERROR(TypeError: unsupported operand types for +: INT and BOOL)"},
+		{`nil + 1;`, "ERROR(TypeError: unsupported operand types for +: NIL and INT)"},
+		{`1 - nil;`, "ERROR(TypeError: unsupported operand types for -: INT and NIL)"},
+		{`if (1) { "yes" };`, "ERROR(TypeError: if condition must be a BOOL in strict mode, got INT)"},
+		{`if (nil) { "yes" } else { "no" };`, "ERROR(TypeError: if condition must be a BOOL in strict mode, got NIL)"},
+		{`var f = func() { if ("a") { 1 } }; f();`, "ERROR(TypeError: if condition must be a BOOL in strict mode, got STRING)"},
+		// what isn't a coercion works as usual
+		{`5 + 2 * 3;`, "INT(11)"},
+		{`if (1 < 2) { "yes" };`, "STRING(yes)"},
+		{`if (not nil) { "yes" };`, "STRING(yes)"},
+		{`1 == true;`, "BOOL(false)"},
+		{`"a" * 2;`, "STRING(aa)"},
+	}
+
+	interpreter := eval.New()
+	interpreter.Strict = true
+
+	for _, tc := range testCases {
+		evaluated := interpreter.Eval(object.NewScope(), parseProgram(tc.input))
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestStrictPragma(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`"use strict"; 5 + true;`, "ERROR(TypeError: unsupported operand types for +: INT and BOOL)"},
+		{`"use strict"; if (1) { "yes" };`, "ERROR(TypeError: if condition must be a BOOL in strict mode, got INT)"},
+		// the pragma only counts at the very top
+		{`5 + true; "use strict"; 5 + true;`, "INT(6)"},
+		{`"use strict!"; 5 + true;`, "INT(6)"},
+		{`5 + true;`, "INT(6)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestTypeAnnotationsAreIgnored(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{`var x: int = 5; x;`, 5},
+		{`var half = func(n: int): int { n / 2 }; half(10);`, 5},
+		// it's up to the checker to complain
+		{`var x: string = 5; x;`, 5},
+		{`var len2 = func(s: int): nil { len(s) }; len2("ab");`, 2},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	enum := "enum Payment { Pending, Paid(amount), Refunded(amount, reason) }; "
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Payment;", "ENUM(Payment)"},
+		{"Payment.Pending;", "Payment.Pending"},
+		{"Payment.Refunded(10, \"broken\");", "Payment.Refunded(INT(10), STRING(broken))"},
+		{"Payment.Refunded(10, \"broken\").reason;", "STRING(broken)"},
+		{"Payment.Paid(5).amount + 1;", "INT(6)"},
+		{"Payment.Pending is Payment.Pending;", "BOOL(true)"},
+		{"Payment.Paid(5) == Payment.Paid(5);", "BOOL(true)"},
+		{"Payment.Paid(5) == Payment.Paid(6);", "BOOL(false)"},
+		{"Payment.Paid(5) == Payment.Refunded(5, \"\");", "BOOL(false)"},
+		{"Payment.Paid([1]) == Payment.Paid([1]);", "BOOL(true)"},
+		// an enum of the same shape is still another enum
+		{"var first = Payment.Pending; enum Payment { Pending }; first == Payment.Pending;", "BOOL(false)"},
+		{"len(#{Payment.Paid(1), Payment.Paid(1), Payment.Pending});", "INT(2)"},
+		{"{[Payment.Paid(1)]: \"one\"}[Payment.Paid(1)];", "STRING(one)"},
+		{"var pay = Payment.Paid; pay(3);", "Payment.Paid(INT(3))"},
+		{"Payment.Paid(1, 2);", "ERROR(ArgumentError: Payment.Paid expects 1 argument(s), got 2)"},
+		{"Payment.Paied;", "ERROR(NameError: enum Payment has no variant 'Paied' (did you mean 'Paid'?))"},
+		{"Payment.Nope;", "ERROR(NameError: enum Payment has no variant 'Nope')"},
+		{"Payment.Pending.amount;", "ERROR(NameError: Payment.Pending has no field 'amount')"},
+		{"#{Payment.Paid([1])};", "ERROR(TypeError: unhashable type: VARIANT)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(enum + tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	enum := "enum Shape { Point, Circle(r), Rect(w, h) }; "
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var area = func(s) { match(s, { Point: 0, Circle: func(r) { 3 * r * r }, Rect: func(w, h) { w * h } }) }; " +
+			"[area(Shape.Point), area(Shape.Circle(2)), area(Shape.Rect(2, 3))];", "[INT(0), INT(12), INT(6)]"},
+		{"match(Shape.Rect(2, 3), { Circle: 1, _: func(s) { s.w } });", "INT(2)"},
+		{"match(Shape.Point, { _: \"anything\" });", "STRING(anything)"},
+		{"match(Shape.Point, { Circle: 1 });", "ERROR(RuntimeError: match has no case for Shape.Point)"},
+		{"match(Shape.Point, { Point: 1, Cirlce: 2 });", "ERROR(NameError: enum Shape has no variant 'Cirlce' (did you mean 'Circle'?))"},
+		{"match(1, { Point: 1 });", "ERROR(TypeError: match() expects a VARIANT, got INT)"},
+		{"match(Shape.Point, [1]);", "ERROR(TypeError: match() expects a DICT of cases, got LIST)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(enum + tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// a stack small enough that 100000 calls deep would blow it,
+	// unless calls in tail position don't make it grow
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var count = func(n) { if (n == 0) { "done" } else { count(n - 1) } }; count(100000);`, "STRING(done)"},
+		{`var count = func(n) { if (n == 0) { return "done"; } return count(n - 1); }; count(100000);`, "STRING(done)"},
+		{`var sum = func(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0);`, "INT(5000050000)"},
+		{`var isEven = func(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		  var isOdd = func(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		  isEven(100001);`, "BOOL(false)"},
+		{`var counter = { n: 0, upTo: func(max) { if (max == 0) { self.n } else { self.upTo(max - 1) } } }; counter.upTo(100000);`, "INT(0)"},
+		// calls that aren't the last thing a function does still work
+		{`var fact = func(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(10);`, "INT(3628800)"},
+		{`var f = func() { try { g() } finally { 2 } }; var g = func() { 1 }; f();`, "INT(1)"},
+		{`var f = func() { try { throw "oops" } catch (e) { g(e) } }; var g = func(e) { e + "!" }; f();`, "STRING(oops!)"},
+		{`var f = func() { try { g() } catch (e) { "caught " + e } }; var g = func() { throw "oops" }; f();`, "STRING(caught oops)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var f = func(n) { f(n + 1) + 1 }; f(0);",
+			"ERROR(StackOverflow: maximum call depth of 10000 exceeded (innermost calls: f, f, f, f, f, ...))"},
+		{"var f = func(n) { f(n + 1) + 1 }; var g = func() { try { f(0) } catch (e) { throw e.message } }; g();",
+			"ERROR(Exception: maximum call depth of 10000 exceeded (innermost calls: f, f, f, f, f, ...))"},
+		{"var f = func(n) { f(n + 1) + 1 }; try { f(0) } catch (e) { e.type };", "STRING(StackOverflow)"},
+		{"var obj = { loop: func() { self.loop() + 1 } }; obj.loop();",
+			"ERROR(StackOverflow: maximum call depth of 10000 exceeded (innermost calls: loop, loop, loop, loop, loop, ...))"},
+		{"var obj = { __add__: func(other) { self + other } }; obj + 1;",
+			"ERROR(StackOverflow: maximum call depth of 10000 exceeded (innermost calls: __add__, __add__, __add__, __add__, __add__, ...))"},
+		// calls made by intrinsics are counted too
+		{"enum E { A(x) }; var f = func(e) { match(e, { A: func(x) { f(E.A(x)) } }) }; f(E.A(1));",
+			"ERROR(StackOverflow: maximum call depth of 10000 exceeded (innermost calls: A, f, f, f, f, ...))"},
+		// the stack unwinds once the error is caught
+		{"var f = func(n) { f(n + 1) + 1 }; try { f(0) } catch (e) { 1 }; var g = func(n) { if (n == 0) { 0 } else { g(n - 1) + 1 } }; g(100);", "INT(100)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestTracebacks(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0;", ""},
+		{`var f = func(x) {
+  x + "a"
+};
+var g = func(y) {
+  [f(y)]
+};
+g(1);`, `Traceback (most recent call first):
+  in f (1 arg), called at line 5, column 5
+  in g (1 arg), called at line 7, column 2
+`},
+		// the error belongs to the call that went wrong
+		{"var f = func(a) { a }; var g = func() { [f(1, 2)] }; g();", `Traceback (most recent call first):
+  in f (2 args), called at line 1, column 43
+  in g (0 args), called at line 1, column 55
+`},
+		{"[func() { 1 / 0 }()];", `Traceback (most recent call first):
+  in <anonymous> (0 args), called at line 1, column 18
+`},
+		{`var obj = { __add__: func(other) { throw "nope" } };
+var f = func() { [obj + 1] };
+f();`, `Traceback (most recent call first):
+  in __add__ (1 arg), called at line 2, column 23
+  in f (0 args), called at line 3, column 2
+`},
+		{"enum E { A(x) }; match(E.A(1), { A: func(x) { x / 0 } });", `Traceback (most recent call first):
+  in A (1 arg), called by an intrinsic
+`},
+		// calls made by intrinsics keep the frames of whoever called the intrinsic
+		{`enum E { A(x) };
+var f = func(e) {
+  [match(e, { A: func(x) { x / 0 } })]
+};
+f(E.A(1));`, `Traceback (most recent call first):
+  in A (1 arg), called by an intrinsic
+  in f (1 arg), called at line 5, column 2
+`},
+		{`var g = func(x) { throw "nope" };
+var f = func() {
+  [collect(lazy_map([1], g))]
+};
+[f()];`, `Traceback (most recent call first):
+  in g (1 arg), called by an intrinsic
+  in f (0 args), called at line 5, column 3
+`},
+		// tail calls take the place of the caller
+		{"var f = func() { g() }; var g = func() { 1 / 0 }; [f()];", `Traceback (most recent call first):
+  in g (0 args), called at line 1, column 19
+`},
+		{"var f = func(n) { f(n + 1) + 1 }; f(0);", `Traceback (most recent call first):
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  in f (1 arg), called at line 1, column 20
+  ... and 9991 more
+`},
+	}
+
+	for _, tc := range testCases {
+		err, ok := evalProgram(tc.input).(*object.Error)
+		if !ok {
+			t.Fatalf("Expected an error for %q", tc.input)
+		}
+
+		if err.Traceback() != tc.expected {
+			t.Errorf("Wrong traceback for %q. Got=\n%s\nExpected=\n%s", tc.input, err.Traceback(), tc.expected)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	interpreter := eval.New()
+	interpreter.MaxDepth = 3
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var a = func() { b() + 1 }; var b = func() { c() + 1 }; var c = func() { 1 }; a();", "INT(3)"},
+		{"var a = func() { b() + 1 }; var b = func() { c() + 1 }; var c = func() { func() { 1 }() + 1 }; a();",
+			"ERROR(StackOverflow: maximum call depth of 3 exceeded (innermost calls: <anonymous>, c, b, a))"},
+		// tail calls take the place of their caller
+		{"var count = func(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(10);", "INT(0)"},
+		// calls made by intrinsics go on top of the caller's
+		{"enum E { A(x) }; var a = func() { match(E.A(1), { A: func(x) { b() + 1 } }) + 1 }; var b = func() { func() { 1 }() + 1 }; a();",
+			"ERROR(StackOverflow: maximum call depth of 3 exceeded (innermost calls: <anonymous>, b, A, a))"},
+		// and every task counts its own calls, however many wait in an intrinsic at once
+		{`enum E { A(x) };
+var entered = channel(10);
+var release = channel();
+var task = func() { match(E.A(1), { A: func(x) { send(entered, x); recv(release); x } }) };
+var tasks = collect(lazy_map(range(0, 6), func(i) { spawn task() }));
+collect(lazy_map(range(0, 6), func(i) { recv(entered) }));
+close(release);
+collect(lazy_map(tasks, func(t) { await(t) }));`, "[INT(1), INT(1), INT(1), INT(1), INT(1), INT(1)]"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := interpreter.Eval(object.NewScope(), parseProgram(tc.input))
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestIfElse(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (0) { 10 }", nil},
+		{"if (5) { 10 }", 10},
+		{"if (5 < 10) { 10 }", 10},
+		{"if (5 > 10) { 10 }", nil},
+		{"if (5 > 10) { 10 } else { 20 }", 20},
+		{"if (5 < 10) { 10 } else { 20 }", 10},
+		{"if (nil) { 10 } else {20 }", 20},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		integer, ok := tc.expected.(int)
+		if ok {
+			assertIntegerObject(t, evaluated, int64(integer))
+		} else {
+			_, ok := evaluated.(*object.Nil)
+			if !ok {
+				t.Fatalf("Expected nil object. Got=%v", evaluated)
+			}
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 1 + 2; 9;", 3},
+		{"true; return 1; false;", 1},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+
+	input := `
+if (10 > 1) {
+	if (true != false) {
+		return true;
+	}
+	return false;
+}`
+
+	evaluated := evalProgram(input)
+	assertBooleanObject(t, evaluated, true)
+}
+
+func TestVarStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x = 5; x;", 5},
+		{"var x = 5; var y = 10; x + y;", 15},
+		{"var x = 5; var y = 10; var z = 15; x + y + z;", 30},
+		{"var x = 5; if (true) { var x = 10; return x; }", 10},
+		{"var x = 5; if (false) { var x = 10; return x; } return x;", 5},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+
+	// test unasigned variable
+	input := `a;`
+	evaluated := evalProgram(input)
+	assertErrorObject(t, evaluated, "undefined variable 'a'")
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"foo;", "undefined variable 'foo'"},
+		{"var count = 1; cuont + 1;", "undefined variable 'cuont' (did you mean 'count'?)"},
+		{"var fib = func(n) { n }; fbi(1);", "undefined variable 'fbi' (did you mean 'fib'?)"},
+		{"lne([1, 2]);", "undefined variable 'lne' (did you mean 'len'?)"},
+		{"var f = func(total) { totl * 2 }; f(1);", "undefined variable 'totl' (did you mean 'total'?)"},
+		{"if (true) { var inner = 1; } inner;", "undefined variable 'inner'"},
+
+		// errors stop the evaluation right where they happen
+		{"var x = missing; 10;", "undefined variable 'missing'"},
+		{"[1, missing, 3];", "undefined variable 'missing'"},
+		{"{ key: missing };", "undefined variable 'missing'"},
+		{"var f = func(x) { x }; f(missing);", "undefined variable 'missing'"},
+		{"if (missing) { 1 } else { 2 }", "undefined variable 'missing'"},
+		{"var f = func() { missing; 10; }; f() + 1;", "undefined variable 'missing'"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+
+	// undefined names are not silently added to the global scope
+	scope := object.NewScope()
+	eval.Eval(scope, parseProgram("missing;"))
+	if _, ok := scope.Get("missing"); ok {
+		t.Fatalf("Expected 'missing' to stay undefined")
+	}
+}
+
+func TestBlockScoping(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		// blocks can read from the enclosing scope
+		{"var x = 5; if (true) { x + 1; }", 6},
+		{"var x = 5; if (false) { 0; } else { x * 2; }", 10},
+		{"var x = 5; if (true) { if (true) { x; } }", 5},
+
+		// inner declarations do not clobber outer names
+		{"var x = 5; if (true) { var x = 10; } x;", 5},
+		{"var x = 5; if (false) { 0; } else { var x = 10; } x;", 5},
+		{"var x = 5; if (true) { var x = 10; if (true) { var x = 20; } x; }", 10},
+		{"var x = 5; var f = func() { if (true) { var x = 10; } x; }; f();", 5},
+
+		// inner declarations do not leak out of the block
+		{"if (true) { var y = 10; } y;", "undefined variable 'y'"},
+		{"if (false) { 0; } else { var y = 10; } y;", "undefined variable 'y'"},
+		{"var f = func() { if (true) { var y = 10; } y; }; f();", "undefined variable 'y'"},
+		{"var f = func() { if (true) { var y = 10; } }; f(); y;", "undefined variable 'y'"},
+
+		// params and the function body still share a scope
+		{"var f = func(x) { var y = x + 1; y; }; f(1);", 2},
+		{"var f = func(x) { var x = x + 1; x; }; f(1);", 2},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+// the resolver puts variables in slots, which are
+// empty until the declaration is reached
+func TestResolvedVariables(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		{"var x = 1; if (true) { var y = x; var x = 2; y + x; }", 3},
+		{"if (true) { var y = z; var z = 2; }", "undefined variable 'z'"},
+		{"var f = func(count) { cuont }; f(1);", "undefined variable 'cuont' (did you mean 'count'?)"},
+		// inner functions have no self of their own
+		{"var o = { v: 7, get: func() { var inner = func() { self.v }; inner() } }; o.get();", 7},
+		{"var x = 1; var f = func() { x }; var g = func(x) { f() }; g(2);", 1},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			assertIntegerObject(t, evaluated, int64(expected))
+		case string:
+			assertErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+// the REPL resolves every line on its own, with the names
+// of the lines before it declared as globals
+func TestResolvedGlobals(t *testing.T) {
+	scope := object.NewScope()
+	eval.Eval(scope, parseProgram("var k = 3; var f = func(n) { n * k };"))
+
+	program := parser.New(lexer.New("var g = func() { f(2) }; g();")).ParseProgram()
+	r := resolver.New()
+	r.Declare(scope.Names()...)
+	if errors := r.Resolve(program); len(errors) != 0 {
+		t.Fatalf("Resolver errors: %v", errors)
+	}
+
+	assertIntegerObject(t, eval.Eval(scope, program), 6)
+}
+
+// bools, nil and small ints are shared, so making them costs nothing
+func TestSharedValues(t *testing.T) {
+	if evalProgram("1 < 2;") != object.True || evalProgram("not true;") != object.False {
+		t.Fatalf("Expected the shared booleans")
+	}
+	if evalProgram("nil;") != object.Null || evalProgram("if (false) { 1 };") != object.Null {
+		t.Fatalf("Expected the shared nil")
+	}
+	if evalProgram("6 * 7;") != object.NewInteger(42) {
+		t.Fatalf("Expected a shared small int")
+	}
+	assertIntegerObject(t, evalProgram("1000 * 1000;"), 1000000)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		eval.Infix(nil, "+", object.NewInteger(20), object.NewInteger(22), false)
+		eval.Infix(nil, "<", object.NewInteger(1), object.NewInteger(2), false)
+		eval.Prefix("not", object.Null)
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected no allocations. Got=%v", allocs)
+	}
+}
+
+func TestThrow(t *testing.T) {
+	testCases := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{`throw "boom";`, "Exception", "boom"},
+		{`throw 42; 10;`, "Exception", "INT(42)"},
+		{`var f = func() { throw "inside"; 10; }; f() + 1;`, "Exception", "inside"},
+		{`if (true) { throw "in a block"; } 10;`, "Exception", "in a block"},
+		// aborting a deep recursion
+		{`
+var countdown = func(n) {
+	if (n == 0) {
+		throw "reached the bottom";
+	}
+	countdown(n - 1);
+};
+countdown(50);`, "Exception", "reached the bottom"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.message)
+
+		if kind := evaluated.(*object.Error).Kind; kind != tc.kind {
+			t.Fatalf("Error has wrong kind. Got=%s, Expected=%s", kind, tc.kind)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		// nothing thrown, the try block is the value
+		{`try { 10 } catch (e) { 20 }`, int64(10)},
+		// the thrown value gets caught
+		{`try { throw 10; 5 } catch (e) { e + 1 }`, int64(11)},
+		{`try { throw "oops" } catch (e) { e }`, "oops"},
+		{`try { throw "oops" } catch { "caught" }`, "caught"},
+		// thrown values unwind through function calls
+		{`var f = func() { throw 7; }; var g = func() { f() + 1 }; try { g() } catch (e) { e * 2 }`, int64(14)},
+		// the catch value is scoped to the catch block
+		{`var e = 1; try { throw 10 } catch (e) { e }; e;`, int64(1)},
+		// nested tries: the innermost catches
+		{`try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e + 10 }`, int64(12)},
+		// it's an expression
+		{`var x = try { throw 3 } catch (e) { e }; x * 2;`, int64(6)},
+		{`var f = func() { return try { throw 5 } catch (e) { e }; }; f();`, int64(5)},
+		// return from inside try is still a return
+		{`var f = func() { try { return 1; } catch (e) { 2 }; 3; }; f();`, int64(1)},
+		// runtime errors are caught as { type, message } dicts
+		{`try { 1 / 0 } catch (e) { e.type }`, "ZeroDivisionError"},
+		{`try { 1 / 0 } catch (e) { e.message }`, "division by zero"},
+		{`try { 5 % 0 } catch (e) { e.type }`, "ZeroDivisionError"},
+		{`try { missing } catch (e) { e.type }`, "NameError"},
+		{`try { [1, 2][5] } catch (e) { e.message }`, "index 5 out of range for a list of length 2"},
+		{`try { [1, 2][-1] } catch (e) { e.type }`, "IndexError"},
+		{`try { var x = 5; x(1) } catch (e) { e.message }`, "INT is not a function"},
+		{`try { func(a, b) { a }(1) } catch (e) { e.message }`, "function expects 2 argument(s), got 1"},
+		// ...and so are the errors from intrinsics
+		{`try { len(5) } catch (e) { e.type }`, "TypeError"},
+		{`try { len(5) } catch (e) { e.message }`, "len() expects a STRING, LIST, TUPLE, SET or DICT, got INT"},
+		{`try { head() } catch (e) { e.type }`, "ArgumentError"},
+		{`try { append(1, 2) } catch (e) { e.message }`, "append() expects a LIST, got INT"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case string:
+			assertStringObject(t, evaluated, expected)
+		case int64:
+			assertIntegerObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestTryFinally(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		// finally runs, but doesn't change the value
+		{`var log = [1]; var x = try { 10 } finally { var log = 2; }; x;`, int64(10)},
+		{`try { throw 1 } catch (e) { e + 1 } finally { 100 }`, int64(2)},
+		// finally runs even when returning from inside try
+		{`var f = func(list) { try { return 1; } finally { pop(list); } }; var l = [1, 2]; f(l); len(l);`, int64(1)},
+		// and when the exception is not caught
+		{`var l = [1, 2]; try { try { throw 1 } finally { pop(l); } } catch (e) { len(l) }`, int64(1)},
+		// returning from finally has the last word
+		{`var f = func() { try { throw 1 } finally { return 2; } }; f();`, int64(2)},
+		{`var f = func() { try { return 1; } finally { return 2; } }; f();`, int64(2)},
+		// throwing from finally too
+		{`try { try { 1 } finally { throw "from finally" } } catch (e) { e }`, "from finally"},
+		// empty blocks are fine
+		{`try { } catch (e) { } finally { }`, nil},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case string:
+			assertStringObject(t, evaluated, expected)
+		case int64:
+			assertIntegerObject(t, evaluated, expected)
+		case nil:
+			if _, ok := evaluated.(*object.Nil); !ok {
+				t.Fatalf("Expected Nil, Got=%T", evaluated)
+			}
+		}
+	}
+
+	// without a catch, the exception keeps going after finally
+	evaluated := evalProgram(`try { throw "keeps going" } finally { 1 }`)
+	assertErrorObject(t, evaluated, "keeps going")
+}
+
+func TestClosures(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"var adder = func(x) { func(y) { x + y } }; var add5 = adder(5); add5(10);", 15},
+		{"var adder = func(x) { func(y) { x + y } }; adder(1)(2);", 3},
+		// functions see the scope they were created in, not the caller's
+		{"var x = 1; var getX = func() { x }; var f = func() { var x = 2; getX() }; f();", 1},
+		{"var counter = func() { var n = 10; func() { n } }; var n = 20; counter()();", 10},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+}
+
+// Functions used to run in the scope of their caller. Since
+// generators came along they run in the scope they were created in
+func TestLexicalScoping(t *testing.T) {
+	// what works the same either way
+	unchanged := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x = 1; var getX = func() { x }; getX();", 1},
+		// globals declared after the function, but before the call
+		{"var getY = func() { y }; var y = 2; getY();", 2},
+		{"var fact = func(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(5);", 120},
+		{"var double = func(n) { n * 2 }; var apply = func(f, n) { f(n) }; apply(double, 21);", 42},
+	}
+
+	for _, tc := range unchanged {
+		assertIntegerObject(t, evalProgram(tc.input), tc.expected)
+	}
+
+	// what the function sees now, next to what it used to see
+	changed := []struct {
+		input    string
+		expected int64
+	}{
+		// used to be 2, the x of f
+		{"var x = 1; var getX = func() { x }; var f = func() { var x = 2; getX() }; f();", 1},
+		// used to be 3, the parameter of f
+		{"var x = 1; var getX = func() { x }; var f = func(x) { getX() }; f(3);", 1},
+		// used to be 20, the n of whoever called the closure
+		{"var make = func() { var n = 10; func() { n } }; var n = 20; make()();", 10},
+	}
+
+	for _, tc := range changed {
+		assertIntegerObject(t, evalProgram(tc.input), tc.expected)
+	}
+
+	// functions can't reach into the variables of their callers anymore,
+	// whatever they need from them has to be passed in as arguments
+	evaluated := evalProgram("var getLocal = func() { local }; var f = func() { var local = 1; getLocal() }; f();")
+	assertErrorObject(t, evaluated, "undefined variable 'local'")
+}
+
+func TestGenerators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []int64
+	}{
+		{`var gen = func() { yield 1; yield 2; yield 3; }; collect(gen());`, []int64{1, 2, 3}},
+		{`var gen = func() { return 1; yield 2; }; collect(gen());`, []int64{}},
+		// yield works from nested blocks
+		{`var gen = func(n) { if (n > 0) { yield n; } else { yield 0; } yield 10; }; collect(gen(5));`, []int64{5, 10}},
+		// recursive generators
+		{`
+var countdown = func(n) {
+	if (n < 0) {
+		return nil;
+	}
+	yield n;
+	var rest = countdown(n - 1);
+	yield next(rest);
+	yield next(rest);
+};
+collect(countdown(2));`, []int64{2, 1, 0}},
+		// infinite generators are fine as long as you don't ask for everything
+		{`
+var naturals = func(n) {
+	yield n;
+	var rest = naturals(n + 1);
+	yield next(rest);
+	yield next(rest);
+	yield next(rest);
+};
+collect(take(naturals(1), 3));`, []int64{1, 2, 3}},
+		// generators close over their scope like any function
+		{`var step = 10; var gen = func(start) { yield start; yield start + step; }; collect(gen(1));`, []int64{1, 11}},
+		// a generator only runs when asked to
+		{`
+var log = [1, 2];
+var gen = func() { pop(log); yield 1; };
+var g = gen();
+var before = len(log);
+next(g);
+[before, len(log)];`, []int64{2, 1}},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerList(t, evaluated, tc.expected)
+	}
+}
+
+func TestGeneratorNext(t *testing.T) {
+	input := `
+var gen = func() { yield 1; yield 2; };
+var g = gen();
+[next(g), next(g), next(g)];`
+
+	evaluated := evalProgram(input)
+	list, ok := evaluated.(*object.List)
+	if !ok {
+		t.Fatalf("Object is not a List. Got=%T", evaluated)
+	}
+
+	assertIntegerObject(t, list.Elements()[0], 1)
+	assertIntegerObject(t, list.Elements()[1], 2)
+	if _, ok := list.Elements()[2].(*object.Nil); !ok {
+		t.Fatalf("Expected Nil after the generator is exhausted. Got=%T", list.Elements()[2])
+	}
+
+	evaluated = evalProgram(`var gen = func() { yield 1; }; gen();`)
+	if _, ok := evaluated.(*object.Generator); !ok {
+		t.Fatalf("Expected a Generator. Got=%T", evaluated)
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var gen = func() { yield 1; throw "broken"; }; collect(gen());`, "broken"},
+		{`var gen = func() { yield missing; }; collect(gen());`, "undefined variable 'missing'"},
+		{`yield 1;`, "yield outside of a generator"},
+		{`next([1, 2]);`, "next() expects an ITERATOR or a GENERATOR, got LIST"},
+		{`take(5, 1);`, "take() expects something iterable, got INT"},
+		{`lazy_map([1], 5);`, "lazy_map() expects a function, got INT"},
+		{`collect(lazy_map([1, 0], func(x) { 1 / x }));`, "division by zero"},
+		{`range(1, 10, 0);`, "range() step can't be 0"},
+		// a generator asking itself for values would wait for itself forever
+		{`var gen = func() { yield 1; next(g); yield 2 }; var g = gen(); collect(g);`, "generator already running"},
+		{`var gen = func() { yield 1; collect(lazy_map(g, func(x) { x })); yield 2 }; var g = gen(); collect(g);`, "generator already running"},
+	}
+
+	for _, tc := range testCases {
 		evaluated := evalProgram(tc.input)
-		assertIntegerObject(t, evaluated, tc.expected)
+		assertErrorObject(t, evaluated, tc.expected)
 	}
 
-	boolEvaledTestCases := []struct {
-		input    string
-		expected bool
-	}{
-		{"5 == 5", true},
-		{"5 == 1", false},
-		{"5 != 5", false},
-		{"5 != 1", true},
-
-		{"nil == nil", true},
-		{"nil != nil", false},
+	// errors thrown by a generator can be caught by the consumer
+	evaluated := evalProgram(`var gen = func() { throw "broken"; yield 1; }; try { collect(gen()) } catch (e) { e }`)
+	assertStringObject(t, evaluated, "broken")
+}
 
-		{"5 > 5", false},
-		{"5 > 1", true},
-		{"5 < 5", false},
-		{"5 < 1", false},
-		{"5 >= 5", true},
-		{"5 >= 1", true},
-		{"5 <= 5", true},
-		{"5 <= 1", false},
+// generators nobody can ask for values anymore let their bodies go,
+// even when the scope they close over holds the generator itself
+func TestAbandonedGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	evaluated := evalProgram(`
+var f = func() { var gen = func() { yield 1; yield 2; }; var g = gen(); next(g) };
+var loop = func(n) { if (n > 0) { f(); loop(n - 1) } };
+loop(2000);`)
+	if isError(evaluated) {
+		t.Fatalf("Unexpected error: %s", evaluated.Inspect())
 	}
 
-	for _, tc := range boolEvaledTestCases {
-		evaluated := evalProgram(tc.input)
-		assertBooleanObject(t, evaluated, tc.expected)
+	assertGoroutinesBelow(t, before+100)
+}
+
+func assertGoroutinesBelow(t *testing.T, limit int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() >= limit {
+		if time.Now().After(deadline) {
+			t.Fatalf("Abandoned generators are still running. Goroutines=%d", runtime.NumGoroutine())
+		}
+
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
 	}
 }
 
-func TestIfElse(t *testing.T) {
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func TestIterators(t *testing.T) {
 	testCases := []struct {
 		input    string
-		expected any
+		expected []int64
 	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (0) { 10 }", nil},
-		{"if (5) { 10 }", 10},
-		{"if (5 < 10) { 10 }", 10},
-		{"if (5 > 10) { 10 }", nil},
-		{"if (5 > 10) { 10 } else { 20 }", 20},
-		{"if (5 < 10) { 10 } else { 20 }", 10},
-		{"if (nil) { 10 } else {20 }", 20},
+		{`collect([1, 2, 3]);`, []int64{1, 2, 3}},
+		{`collect(iterate([1, 2, 3]));`, []int64{1, 2, 3}},
+		{`var it = iterate([1, 2, 3]); next(it); collect(it);`, []int64{2, 3}},
+		{`collect(range(5));`, []int64{0, 1, 2, 3, 4}},
+		{`collect(range(2, 5));`, []int64{2, 3, 4}},
+		{`collect(range(10, 0, -3));`, []int64{10, 7, 4, 1}},
+		{`collect(range(0));`, []int64{}},
+		// the last step would go past the biggest or the smallest INT
+		{`collect(range(9223372036854775800, 9223372036854775807, 5));`, []int64{9223372036854775800, 9223372036854775805}},
+		{`collect(range(-9223372036854775800, -9223372036854775807 - 1, -5));`, []int64{-9223372036854775800, -9223372036854775805}},
+		{`collect(range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807));`, []int64{-9223372036854775808, -1, 9223372036854775806}},
+		{`collect(take(range(100), 3));`, []int64{0, 1, 2}},
+		{`collect(take([1, 2], 5));`, []int64{1, 2}},
+		{`collect(drop(range(5), 2));`, []int64{2, 3, 4}},
+		{`collect(drop([1, 2], 5));`, []int64{}},
+		{`collect(lazy_map(range(4), func(x) { x * x }));`, []int64{0, 1, 4, 9}},
+		{`collect(lazy_filter(range(10), func(x) { x % 3 == 0 }));`, []int64{0, 3, 6, 9}},
+		{`var offset = 100; collect(lazy_map([1, 2], func(x) { x + offset }));`, []int64{101, 102}},
+		// the whole pipeline is lazy, so it doesn't matter how big the range is
+		{`
+var squares = lazy_map(range(1, 1000000000), func(x) { x * x });
+var odd = lazy_filter(squares, func(x) { x % 2 == 1 });
+collect(take(drop(odd, 1), 3));`, []int64{9, 25, 49}},
+		{`
+var naturals = func(n) { yield n; var rest = naturals(n + 1); yield next(rest); yield next(rest); };
+collect(lazy_map(take(naturals(1), 3), func(x) { x * 10 }));`, []int64{10, 20, 30}},
 	}
 
 	for _, tc := range testCases {
 		evaluated := evalProgram(tc.input)
+		assertIntegerList(t, evaluated, tc.expected)
+	}
 
-		integer, ok := tc.expected.(int)
-		if ok {
-			assertIntegerObject(t, evaluated, int64(integer))
-		} else {
-			_, ok := evaluated.(*object.Nil)
-			if !ok {
-				t.Fatalf("Expected nil object. Got=%v", evaluated)
-			}
+	strCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`collect("abc");`, []string{"a", "b", "c"}},
+		{`collect({ b: 2, a: 1, c: 3 });`, []string{"a", "b", "c"}},
+		{`collect(lazy_map("hi", func(c) { c + c }));`, []string{"hh", "ii"}},
+	}
+
+	for _, tc := range strCases {
+		evaluated := evalProgram(tc.input)
+		list, ok := evaluated.(*object.List)
+		if !ok {
+			t.Fatalf("Object is not a List. Got=%T", evaluated)
+		}
+
+		if len(list.Elements()) != len(tc.expected) {
+			t.Fatalf("List has wrong length. Got=%d, Expected=%d", len(list.Elements()), len(tc.expected))
+		}
+
+		for i, item := range list.Elements() {
+			assertStringObject(t, item, tc.expected[i])
 		}
 	}
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestSpawnAndAwait(t *testing.T) {
 	testCases := []struct {
 		input    string
 		expected int64
 	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 1 + 2; 9;", 3},
-		{"true; return 1; false;", 1},
+		{"var add = func(a, b) { a + b }; var task = spawn add(1, 2); await(task);", 3},
+		{"await(spawn func(x) { x * 2 }(21));", 42},
+		{"var fib = func(n) { if (n <= 1) { n } else { fib(n - 1) + fib(n - 2) } }; var a = spawn fib(10); var b = spawn fib(11); await(a) + await(b);", 144},
+		// awaiting twice gives back the same result
+		{"var task = spawn len([1, 2]); await(task) + await(task);", 4},
+		// tasks close over their scope, which the main task keeps changing
+		{`
+var base = 100;
+var read = func(n) { base + n };
+var tasks = [spawn read(1), spawn read(2), spawn read(3)];
+var other = 1;
+var another = 2;
+await(tasks[0]) + await(tasks[1]) + await(tasks[2]);`, 306},
 	}
 
 	for _, tc := range testCases {
@@ -301,41 +1478,153 @@ func TestReturnStatements(t *testing.T) {
 		assertIntegerObject(t, evaluated, tc.expected)
 	}
 
-	input := `
-if (10 > 1) {
-	if (true != false) {
-		return true;
-	}
-	return false;
-}`
+	// errors in a task are thrown by await
+	evaluated := evalProgram(`var task = spawn func() { throw "failed" }(); try { await(task) } catch (e) { e }`)
+	assertStringObject(t, evaluated, "failed")
 
-	evaluated := evalProgram(input)
-	assertBooleanObject(t, evaluated, true)
+	evaluated = evalProgram(`var task = spawn func() { 1 / 0 }(); await(task);`)
+	assertErrorObject(t, evaluated, "division by zero")
+
+	evaluated = evalProgram(`await(5);`)
+	assertErrorObject(t, evaluated, "await() expects a TASK, got INT")
 }
 
-func TestVarStatements(t *testing.T) {
+func TestChannels(t *testing.T) {
 	testCases := []struct {
 		input    string
-		expected int64
+		expected []int64
 	}{
-		{"var x = 5; x;", 5},
-		{"var x = 5; var y = 10; x + y;", 15},
-		{"var x = 5; var y = 10; var z = 15; x + y + z;", 30},
-		{"var x = 5; if (true) { var x = 10; return x; }", 10},
-		{"var x = 5; if (false) { var x = 10; return x; } return x;", 5},
+		// buffered channels don't block until they're full
+		{"var ch = channel(2); send(ch, 1); send(ch, 2); [recv(ch), recv(ch)];", []int64{1, 2}},
+		// unbuffered channels hand values over between tasks
+		{`
+var ch = channel();
+var producer = func(ch, n) { send(ch, n); send(ch, n + 1); close(ch); };
+spawn producer(ch, 10);
+[recv(ch), recv(ch)];`, []int64{10, 11}},
+		// ping pong
+		{`
+var ping = channel();
+var pong = channel();
+var player = func() { send(pong, recv(ping) * 2); };
+spawn player();
+send(ping, 21);
+[recv(pong)];`, []int64{42}},
+		// a bunch of workers sending their results back
+		{`
+var results = channel(10);
+var worker = func(n) { send(results, n * n); };
+var spawnAll = func(n) { if (n == 0) { return 0; } spawn worker(n); spawnAll(n - 1); };
+spawnAll(10);
+var sum = func(n) { if (n == 0) { return 0; } recv(results) + sum(n - 1) };
+[sum(10)];`, []int64{385}},
+		// select takes whichever channel is ready
+		{`
+var a = channel(1);
+var b = channel(1);
+send(b, 7);
+var r = select([a, b]);
+[r.index, r.value];`, []int64{1, 7}},
+		{`
+var a = channel();
+var b = channel();
+spawn func() { send(a, 3); }();
+var r = select([a, b]);
+[r.index, r.value];`, []int64{0, 3}},
 	}
 
 	for _, tc := range testCases {
 		evaluated := evalProgram(tc.input)
-		assertIntegerObject(t, evaluated, tc.expected)
+		assertIntegerList(t, evaluated, tc.expected)
 	}
 
-	// test unasigned variable
-	input := `a;`
+	// receiving from a closed channel gives back nil
+	evaluated := evalProgram(`var ch = channel(1); send(ch, 1); close(ch); recv(ch); recv(ch);`)
+	if _, ok := evaluated.(*object.Nil); !ok {
+		t.Fatalf("Expected Nil from a closed channel. Got=%T", evaluated)
+	}
+
+	evaluated = evalProgram(`var ch = channel(); close(ch); select([ch]).ok;`)
+	assertBooleanObject(t, evaluated, false)
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var ch = channel(1); close(ch); send(ch, 1);", "send on a closed channel"},
+		{"var ch = channel(1); close(ch); close(ch);", "close of a closed channel"},
+		{"channel(-1);", "channel() capacity can't be negative"},
+		{"channel(10000000000000);", "channel() capacity can't be more than 1048576, got 10000000000000"},
+		{"send(5, 1);", "send() expects a CHANNEL, got INT"},
+		{"select([]);", "select() needs at least one channel"},
+		{"select([1]);", "select() expects a LIST of CHANNELs, got INT"},
+		// a task blocked on send fails once the channel gets closed
+		{"var ch = channel(); var task = spawn send(ch, 1); close(ch); await(task);", "send on a closed channel"},
+	}
+
+	for _, tc := range errorCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestConcurrentListAccess(t *testing.T) {
+	// every task pops from the same list, which must end up empty
+	// with every item popped exactly once. Run with -race
+	input := `
+var items = collect(range(200));
+var done = channel(4);
+var popper = func(n, total) {
+	if (n == 0) {
+		send(done, total);
+		return nil;
+	}
+	popper(n - 1, total + pop(items));
+};
+spawn popper(50, 0);
+spawn popper(50, 0);
+spawn popper(50, 0);
+spawn popper(50, 0);
+[recv(done) + recv(done) + recv(done) + recv(done), len(items)];`
+
 	evaluated := evalProgram(input)
-	_, ok := evaluated.(*object.Nil)
-	if !ok {
-		t.Fatalf("Expected nil object. Got=%v", evaluated)
+	assertIntegerList(t, evaluated, []int64{19900, 0})
+}
+
+func TestConcurrentIteration(t *testing.T) {
+	// two tasks drain the same iterator, which must hand every
+	// item out exactly once, to one of them. Run with -race
+	testCases := []string{
+		`
+var gen = func() { yield 1; yield 2; yield 3; yield 4; yield 5; yield 6; yield 7; yield 8; yield 9; yield 10; };
+var shared = gen();
+var drain = func() { collect(shared) };
+var a = spawn drain();
+var b = spawn drain();
+var sum = func(l) { if (len(l) == 0) { 0 } else { head(l) + sum(tail(l)) } };
+var items = await(a) + await(b);
+[len(items), sum(items)];`,
+		`
+var shared = lazy_map(range(1, 11), func(n) { n });
+var drain = func() { collect(shared) };
+var a = spawn drain();
+var b = spawn drain();
+var sum = func(l) { if (len(l) == 0) { 0 } else { head(l) + sum(tail(l)) } };
+var items = await(a) + await(b);
+[len(items), sum(items)];`,
+		`
+var shared = take(drop(range(-5, 100), 6), 10);
+var drain = func() { collect(shared) };
+var a = spawn drain();
+var b = spawn drain();
+var sum = func(l) { if (len(l) == 0) { 0 } else { head(l) + sum(tail(l)) } };
+var items = await(a) + await(b);
+[len(items), sum(items)];`,
+	}
+
+	for _, input := range testCases {
+		evaluated := evalProgram(input)
+		assertIntegerList(t, evaluated, []int64{10, 55})
 	}
 }
 
@@ -568,6 +1857,320 @@ func TestObjectMethodCalls(t *testing.T) {
 	assertIntegerObject(t, evaluated, 30)
 }
 
+func TestSelf(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{`var point = { x: 3, y: 4, sum: func() { self.x + self.y } }; point.sum();`, 7},
+		{`
+var stack = {
+	items: [1, 2, 3],
+	pop: func() { pop(self.items) },
+	size: func() { len(self.items) }
+};
+stack.pop();
+stack.pop();
+stack.size();`, 1},
+		// bound methods remember their receiver when called later
+		{`var point = { x: 5, getX: func() { self.x } }; var getX = point.getX; getX();`, 5},
+		{`var a = { x: 1, getX: func() { self.x } }; var b = { x: 2, getX: a.getX }; b.getX();`, 1},
+		// nested objects bind to the innermost dict
+		{`var outer = { x: 1, inner: { x: 2, getX: func() { self.x } } }; outer.inner.getX();`, 2},
+		{`var getX = func() { self.x }; var bound = bind(getX, { x: 10 }); bound();`, 10},
+		{`var getX = func() { self.x }; var obj = { x: 1, getX: bind(getX, { x: 2 }) }; obj.getX();`, 2},
+		// a param named self shadows the receiver
+		{`var obj = { x: 1, f: func(self) { self } }; obj.f(42);`, 42},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var f = func() { self }; f();`, "undefined variable 'self'"},
+		{`bind(len, {});`, "bind() expects a FUNC, got INTRINSIC_FUNC"},
+		{`bind(func() { 1 });`, "bind() expects 2 argument(s), got 1"},
+	}
+
+	for _, tc := range errorCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestPrototypes(t *testing.T) {
+	animal := `
+var Animal = {
+	legs: 4,
+	sound: func() { "..." },
+	speak: func() { self.name + " says " + self.sound() }
+};
+var Dog = extend(Animal, {
+	sound: func() { "woof" },
+	speak: func() { super.speak() + "!" }
+});
+var Bird = extend(Animal, { legs: 2 });
+`
+
+	testCases := []struct {
+		input    string
+		expected object.Object
+	}{
+		// missing keys fall back to the prototype
+		{animal + `Bird.legs;`, &object.Integer{Value: 2}},
+		{animal + `extend(Bird).legs;`, &object.Integer{Value: 2}},
+		{animal + `Dog.legs;`, &object.Integer{Value: 4}},
+		{animal + `Dog.missing;`, &object.Nil{}},
+		// self is the receiver, even for methods of the prototype
+		{animal + `extend(Animal, { name: "Pingu" }).speak();`, &object.String{Value: []rune("Pingu says ...")}},
+		// super calls the prototype's method with the same self
+		{animal + `extend(Dog, { name: "Rex" }).speak();`, &object.String{Value: []rune("Rex says woof!")}},
+		{animal + `var rex = extend(Dog, { name: "Rex" }); var speak = rex.speak; speak();`, &object.String{Value: []rune("Rex says woof!")}},
+		{animal + `is_a(Dog, Animal);`, &object.Boolean{Value: true}},
+		{animal + `is_a(extend(Dog), Animal);`, &object.Boolean{Value: true}},
+		{animal + `is_a(Animal, Dog);`, &object.Boolean{Value: false}},
+		{animal + `is_a(Bird, Dog);`, &object.Boolean{Value: false}},
+		{animal + `is_a(Animal, Animal);`, &object.Boolean{Value: false}},
+		{animal + `is_a(5, Animal);`, &object.Boolean{Value: false}},
+		// the prototype's keys are not copied
+		{animal + `len(collect(Dog));`, &object.Integer{Value: 2}},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case *object.Integer:
+			assertIntegerObject(t, evaluated, expected.Value)
+		case *object.String:
+			assertStringObject(t, evaluated, string(expected.Value))
+		case *object.Boolean:
+			assertBooleanObject(t, evaluated, expected.Value)
+		case *object.Nil:
+			if evaluated.Type() != object.NIL {
+				t.Fatalf("Expected NIL, got=%s", evaluated.Inspect())
+			}
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var obj = { f: func() { super.f() } }; obj.f();`, "super used in a method of a dict with no prototype"},
+		{`extend(5);`, "extend() expects a DICT, got INT"},
+		{`extend({}, 5);`, "extend() expects a DICT of fields, got INT"},
+		{`extend();`, "extend() expects 1 or 2 argument(s), got 0"},
+		{`is_a({}, 5);`, "is_a() expects a DICT prototype, got INT"},
+	}
+
+	for _, tc := range errorCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestMetamethods(t *testing.T) {
+	vector := `
+var Vector = {
+	__add__: func(other) { vec(self.x + other.x, self.y + other.y) },
+	__sub__: func(other) { vec(self.x - other.x, self.y - other.y) },
+	__mul__: func(k) { vec(self.x * k, self.y * k) },
+	__rmul__: func(k) { self * k },
+	__neg__: func() { vec(-self.x, -self.y) },
+	__eq__: func(other) { if (self.x == other.x) { self.y == other.y } else { false } },
+	__lt__: func(other) { self.length() < other.length() },
+	__index__: func(i) { if (i == 0) { self.x } else { self.y } },
+	__len__: func() { 2 },
+	__str__: func() { "Vector" },
+	length: func() { self.x * self.x + self.y * self.y }
+};
+var vec = func(x, y) { extend(Vector, { x: x, y: y }) };
+var multiplier = { factor: 3, __call__: func(n) { n * self.factor } };
+`
+
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{vector + `var v = vec(1, 2) + vec(3, 4); v.x * 10 + v.y;`, 46},
+		{vector + `var v = vec(5, 5) - vec(1, 2); v.x * 10 + v.y;`, 43},
+		{vector + `var v = vec(1, 2) * 3; v.x * 10 + v.y;`, 36},
+		// the right operand's reflected hook
+		{vector + `var v = 2 * vec(1, 2); v.x * 10 + v.y;`, 24},
+		{vector + `var v = -vec(1, 2); v.x * 10 + v.y;`, -12},
+		{vector + `vec(7, 8)[1];`, 8},
+		{vector + `len(vec(7, 8));`, 2},
+		{vector + `multiplier(14);`, 42},
+		// callable dicts work wherever functions do
+		{vector + `collect(lazy_map([1, 2], multiplier))[1];`, 6},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		assertIntegerObject(t, evaluated, tc.expected)
+	}
+
+	boolCases := []struct {
+		input    string
+		expected bool
+	}{
+		{vector + `vec(1, 2) == vec(1, 2);`, true},
+		{vector + `vec(1, 2) == vec(2, 1);`, false},
+		// != falls back to __eq__
+		{vector + `vec(1, 2) != vec(2, 1);`, true},
+		{vector + `vec(1, 2) != vec(1, 2);`, false},
+		{vector + `vec(1, 1) < vec(2, 2);`, true},
+		// > falls back to the right operand's __lt__
+		{vector + `vec(1, 1) > vec(2, 2);`, false},
+		{vector + `vec(3, 3) > vec(2, 2);`, true},
+	}
+
+	for _, tc := range boolCases {
+		evaluated := evalProgram(tc.input)
+		assertBooleanObject(t, evaluated, tc.expected)
+	}
+
+	evaluated := evalProgram(vector + `vec(1, 2);`)
+	if evaluated.Inspect() != "Vector" {
+		t.Fatalf("Expected __str__ to be used. Got=%s", evaluated.Inspect())
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var bad = { __add__: func(other) { throw "nope" } }; bad + 1;`, "nope"},
+		{`var bad = { __add__: func() { 1 } }; bad + 1;`, "function expects 0 argument(s), got 1"},
+		{`var notCallable = { x: 1 }; notCallable();`, "DICT is not a function"},
+	}
+
+	for _, tc := range errorCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestTuples(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`();`, "()"},
+		{`(1,);`, "(INT(1),)"},
+		{`(1, "two", [3]);`, `(INT(1), STRING(two), [INT(3)])`},
+		{`tuple([1, 2]);`, "(INT(1), INT(2))"},
+		{`(1, 2)[1];`, "INT(2)"},
+		{`len((1, 2, 3));`, "INT(3)"},
+		{`collect((1, 2));`, "[INT(1), INT(2)]"},
+		{`(1, (2, 3)) == (1, (2, 3));`, "BOOL(true)"},
+		{`(1, 2) == (2, 1);`, "BOOL(false)"},
+		{`(1, 2) != (1, 2, 3);`, "BOOL(true)"},
+		{`([1], 2) == ([1], 2);`, "BOOL(true)"},
+		// parens on their own still group
+		{`(1 + 2) * 3;`, "INT(9)"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+
+	evaluated := evalProgram(`(1, 2)[2];`)
+	assertErrorObject(t, evaluated, "index 2 out of range for a tuple of length 2")
+}
+
+func TestSets(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`#{};`, "#{}"},
+		{`#{1, 2, 2, 3, 1};`, "#{INT(1), INT(2), INT(3)}"},
+		{`set([3, 1, 3]);`, "#{INT(3), INT(1)}"},
+		{`set("hello");`, "#{STRING(h), STRING(e), STRING(l), STRING(o)}"},
+		{`#{(1, 2), (1, 2), #{1}, #{1}};`, "#{(INT(1), INT(2)), #{INT(1)}}"},
+		{`union(#{1, 2}, #{2, 3});`, "#{INT(1), INT(2), INT(3)}"},
+		{`intersect(#{1, 2, 3}, #{3, 2, 4});`, "#{INT(2), INT(3)}"},
+		{`difference(#{1, 2, 3}, #{2});`, "#{INT(1), INT(3)}"},
+		{`has(#{1, 2}, 2);`, "BOOL(true)"},
+		{`has(#{1, 2}, "2");`, "BOOL(false)"},
+		{`has(#{(1, 2)}, (1, 2));`, "BOOL(true)"},
+		{`has(#{1}, [1]);`, "BOOL(false)"},
+		{`len(#{1, 1, 1});`, "INT(1)"},
+		// order doesn't matter for equality
+		{`#{1, 2, 3} == #{3, 2, 1};`, "BOOL(true)"},
+		{`#{1, 2} == #{1, 2, 3};`, "BOOL(false)"},
+		{`#{#{1, 2}, #{2, 1}};`, "#{#{INT(1), INT(2)}}"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{`#{[1, 2]};`, "unhashable type: LIST"},
+		{`#{([1], 2)};`, "unhashable type: TUPLE"},
+		{`set([{}]);`, "unhashable type: DICT"},
+		{`union(#{1}, [1]);`, "union() expects two SETs, got LIST"},
+		{`has([1], 1);`, "has() expects a SET or a DICT, got LIST"},
+	}
+
+	for _, tc := range errorCases {
+		evaluated := evalProgram(tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+}
+
+func TestHashableDictKeys(t *testing.T) {
+	grid := `var grid = { name: "grid", [(0, 0)]: "origin", [(1, 2)]: "point", [3]: "three", [#{1, 2}]: "pair", [true]: "yes" };`
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{grid + `grid[(0, 0)];`, "STRING(origin)"},
+		{grid + `grid[(1, 2)];`, "STRING(point)"},
+		{grid + `grid[(2, 1)];`, "NIL"},
+		{grid + `grid[3];`, "STRING(three)"},
+		{grid + `grid[#{2, 1}];`, "STRING(pair)"},
+		{grid + `grid[true];`, "STRING(yes)"},
+		// strings are the same keys as the plain ones
+		{grid + `grid["name"];`, "STRING(grid)"},
+		{`{ ["name"]: 1 }.name;`, "INT(1)"},
+		{grid + `len(grid);`, "INT(6)"},
+		{grid + `has(grid, (1, 2));`, "BOOL(true)"},
+		{grid + `has(grid, (9, 9));`, "BOOL(false)"},
+		{`collect({ b: 1, [2]: 2, a: 3, [(1,)]: 4 });`, "[STRING(a), STRING(b), INT(2), (INT(1),)]"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalProgram(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Wrong result for %q. Got=%s, Expected=%s", tc.input, evaluated.Inspect(), tc.expected)
+		}
+	}
+
+	evaluated := evalProgram(`{ [[1]]: 1 };`)
+	assertErrorObject(t, evaluated, "unhashable type: LIST")
+
+	evaluated = evalProgram(`{}[[1]];`)
+	assertErrorObject(t, evaluated, "unhashable type: LIST")
+}
+
 func TestComplexObjectStructure(t *testing.T) {
 	input := `
 		var user = {
@@ -616,6 +2219,32 @@ func assertStringObject(t *testing.T, obj object.Object, expected string) {
 	}
 }
 
+func assertErrorObject(t *testing.T, obj object.Object, expected string) {
+	err, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("Object is not an Error. Got=%T (%v)", obj, obj)
+	}
+
+	if err.Message != expected {
+		t.Fatalf("Error message is wrong. Got=%q, Expected=%q", err.Message, expected)
+	}
+}
+
+func assertIntegerList(t *testing.T, obj object.Object, expected []int64) {
+	list, ok := obj.(*object.List)
+	if !ok {
+		t.Fatalf("Object is not a List. Got=%T (%v)", obj, obj)
+	}
+
+	if len(list.Elements()) != len(expected) {
+		t.Fatalf("List has wrong length. Got=%s, Expected=%v", list.Inspect(), expected)
+	}
+
+	for i, item := range list.Elements() {
+		assertIntegerObject(t, item, expected[i])
+	}
+}
+
 func assertIntegerObject(t *testing.T, obj object.Object, expected int64) {
 	integer, ok := obj.(*object.Integer)
 	if !ok {
@@ -639,10 +2268,20 @@ func assertBooleanObject(t *testing.T, obj object.Object, expected bool) {
 }
 
 func evalProgram(input string) object.Object {
+	scope := object.NewScope()
+
+	return eval.Eval(scope, parseProgram(input))
+}
+
+// parseProgram resolves the program the way pingulcc does, so its
+// variables live in slots. Some tests use undefined names on purpose,
+// those are left to fail when they're evaluated
+func parseProgram(input string) *ast.Program {
 	lxr := lexer.New(input)
 	psr := parser.New(lxr)
+
 	program := psr.ParseProgram()
-	scope := object.NewScope()
+	resolver.New().Resolve(program)
 
-	return eval.Eval(scope, program)
+	return program
 }
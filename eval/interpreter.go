@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+)
+
+// Interpreter holds whatever outlives a single call to Eval,
+// like the settings and the modules that have been imported
+type Interpreter struct {
+	// where imports are looked up when they can't be
+	// found relative to the file doing the importing
+	SearchPath []string
+
+	// Strict turns off the implicit coercions: ints and bools
+	// don't mix, and if conditions have to be BOOLs. Files can
+	// opt in on their own with a "use strict" pragma
+	Strict bool
+
+	// MaxDepth is how deep pingul calls can nest before they fail
+	// with a StackOverflow, instead of taking the process down
+	MaxDepth int
+
+	// Limits caps the runs started with EvalContext
+	Limits Limits
+
+	modules *ModuleCache
+
+	// the capabilities it was created with,
+	// and the intrinsics limited to them
+	caps       object.Capabilities
+	intrinsics object.FuncTable
+
+	// set on the interpreters EvalContext evaluates each of its runs
+	// with, which share everything but the budget with their parent
+	budget *budget
+	parent *Interpreter
+
+	// the run in progress, if there's one
+	run     atomic.Pointer[Interpreter]
+	running sync.Mutex
+}
+
+// DefaultMaxDepth leaves plenty of room below Go's own stack limit
+const DefaultMaxDepth = 10000
+
+// New creates an interpreter with no capabilities,
+// which can run nothing but pure computation
+func New() *Interpreter {
+	return NewWithCapabilities(object.Capabilities{})
+}
+
+// NewWithCapabilities creates an interpreter whose intrinsics
+// can reach as far outside of it as caps allows them to
+func NewWithCapabilities(caps object.Capabilities) *Interpreter {
+	return &Interpreter{
+		SearchPath: []string{},
+		MaxDepth:   DefaultMaxDepth,
+		modules:    NewModuleCache(),
+		caps:       caps,
+		intrinsics: object.Intrinsics(caps),
+	}
+}
+
+var defaultInterpreter = New()
+
+// Eval evaluates the node using the default interpreter
+func Eval(scope *object.Scope, node ast.Node) object.Object {
+	return defaultInterpreter.Eval(scope, node)
+}
+
+// Eval evaluates the node, on the budget of the run
+// if the interpreter is one of those of EvalContext
+func (in *Interpreter) Eval(scope *object.Scope, node ast.Node) object.Object {
+	b := in.budget
+	if b == nil {
+		return in.eval(scope, node)
+	}
+
+	if err := b.step(); err != nil {
+		return err
+	}
+
+	result := in.eval(scope, node)
+	if err := b.checkSize(result); err != nil {
+		return err
+	}
+
+	return result
+}
+
+// Call lets intrinsics such as lazy_map call back into pingul functions,
+// on behalf of the caller frame the intrinsic was itself called from
+func (in *Interpreter) Call(caller *object.Frame, fun object.Object, args ...object.Object) object.Object {
+	return in.caller().apply(caller, object.Position{}, fun, args)
+}
+
+// caller is who calls back the functions the interpreter made: the
+// run in progress, unless they were made by a run that isn't over yet
+func (in *Interpreter) caller() *Interpreter {
+	if in.budget != nil && !in.budget.over.Load() {
+		return in
+	}
+
+	parent := in
+	if in.parent != nil {
+		parent = in.parent
+	}
+
+	// with no run in progress, the functions of a run that's
+	// over are left to be cancelled, like the rest of it
+	if run := parent.run.Load(); run != nil {
+		return run
+	}
+
+	return in
+}
+
+func (in *Interpreter) stackOverflow(frame *object.Frame) *object.Error {
+	return StackOverflow(in.MaxDepth, frame)
+}
+
+// strict tells whether code running in scope is strict,
+// either because of the interpreter or because of a pragma
+func (in *Interpreter) strict(scope *object.Scope) bool {
+	return in.Strict || scope.Strict()
+}
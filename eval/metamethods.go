@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
+)
+
+// Dicts can overload operators by defining metamethods, which
+// are looked up the prototype chain like any other method and
+// take precedence over the built-in rules
+var infixHooks = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"%":  "__mod__",
+	"==": "__eq__",
+	"!=": "__ne__",
+	"<":  "__lt__",
+	"<=": "__le__",
+	">":  "__gt__",
+	">=": "__ge__",
+}
+
+// the hooks of the right operand, when the left one has none.
+// Comparisons are flipped, e.g. a < b becomes b > a
+var reflectedHooks = map[string]string{
+	"+":  "__radd__",
+	"-":  "__rsub__",
+	"*":  "__rmul__",
+	"/":  "__rdiv__",
+	"%":  "__rmod__",
+	"==": "__eq__",
+	"!=": "__ne__",
+	"<":  "__gt__",
+	"<=": "__ge__",
+	">":  "__lt__",
+	">=": "__le__",
+}
+
+var prefixHooks = map[string]string{
+	"-": "__neg__",
+}
+
+// Metamethod returns the hook of a dict bound to the dict, if there's one
+func Metamethod(obj object.Object, name string) (object.Object, bool) {
+	dict, ok := obj.(*object.Dict)
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	return dict.Method(dict, name)
+}
+
+func (in *Interpreter) evalInfixHook(scope *object.Scope, at token.Token, operator string, left object.Object, right object.Object) (object.Object, bool) {
+	hook, arg, negate, ok := InfixHook(operator, left, right)
+	if !ok {
+		return nil, false
+	}
+
+	result := in.applyFunction(scope, at, hook, []object.Object{arg})
+	if negate && !isError(result) {
+		return object.NewBoolean(!result.IsTruthy()), true
+	}
+
+	return result, true
+}
+
+// InfixHook finds the metamethod that overloads the operator, along
+// with the operand it has to be called with. negate is set when
+// the result has to be flipped, which is how != falls back to ==
+func InfixHook(operator string, left object.Object, right object.Object) (hook object.Object, arg object.Object, negate bool, ok bool) {
+	// the collection decides what's in it
+	if operator == "in" {
+		hook, ok = Metamethod(right, "__contains__")
+		return hook, left, false, ok
+	}
+
+	if hook, ok := Metamethod(left, infixHooks[operator]); ok {
+		return hook, right, false, true
+	}
+
+	if hook, ok := Metamethod(right, reflectedHooks[operator]); ok {
+		return hook, left, false, true
+	}
+
+	// a != b is not (a == b), unless told otherwise
+	if operator == "!=" {
+		hook, arg, _, ok := InfixHook("==", left, right)
+		return hook, arg, true, ok
+	}
+
+	return nil, nil, false, false
+}
+
+func (in *Interpreter) evalPrefixHook(scope *object.Scope, at token.Token, operator string, right object.Object) (object.Object, bool) {
+	if hook, ok := PrefixHook(operator, right); ok {
+		return in.applyFunction(scope, at, hook, []object.Object{}), true
+	}
+
+	return nil, false
+}
+
+// PrefixHook finds the metamethod that overloads the operator
+func PrefixHook(operator string, right object.Object) (object.Object, bool) {
+	return Metamethod(right, prefixHooks[operator])
+}
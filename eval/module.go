@@ -0,0 +1,298 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
+)
+
+// ModuleCache makes sure every module is run only once,
+// no matter how many times and from where it gets imported
+type ModuleCache struct {
+	mu      sync.Mutex
+	modules map[string]*object.Module
+
+	// the modules being evaluated right now. Tasks can import
+	// at the same time, so there's one import chain per task
+	loading map[string]*loading
+}
+
+// loading is a module being evaluated, which whoever else
+// imports it in the meantime waits for
+type loading struct {
+	done   chan struct{}
+	result object.Object
+
+	// the modules this one can't finish without, because it's
+	// importing them, used to tell apart a cyclic import from
+	// one that only has to wait for another task's import
+	waitingFor map[string]int
+}
+
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{
+		modules: make(map[string]*object.Module),
+		loading: make(map[string]*loading),
+	}
+}
+
+func (in *Interpreter) evalImportStatement(scope *object.Scope, node *ast.ImportStatement) object.Object {
+	path, err := FindModule(in.caps.FS, scope.File(), node.Path, in.SearchPath)
+	if err != nil {
+		return err
+	}
+
+	result := in.modules.Load(in.caps.FS, scope.File(), path, func(program *ast.Program) object.Object {
+		scope := object.NewModuleScope(path)
+		if result := in.evalProgram(scope, program); isError(result) {
+			return result
+		}
+
+		return &object.Module{Path: path, Scope: scope, Exports: ExportedNames(program)}
+	})
+	if isError(result) {
+		return result
+	}
+	module := result.(*object.Module)
+
+	if node.Alias != nil {
+		return scope.Set(node.Alias.String(), module)
+	}
+
+	for _, name := range node.Names {
+		val, ok := module.Get(name.String())
+		if !ok {
+			return object.NewError(object.IMPORT_ERROR, "module '%s' does not export '%s'", node.Path, name)
+		}
+		scope.Set(name.String(), val)
+	}
+
+	return object.Null
+}
+
+// FindModule looks the import up relative to the file doing the
+// importing first, then in every directory of the search path.
+// Only the files inside of fs can be imported, and without an fs
+// there's nothing to import at all. Paths that aren't absolute
+// are relative to the root of fs, the path returned is absolute
+func FindModule(fs *os.Root, importer string, importPath string, searchPath []string) (string, *object.Error) {
+	if fs == nil {
+		return "", object.NewError(object.PERMISSION_ERROR, "import needs the filesystem capability")
+	}
+
+	root, err := filepath.Abs(fs.Name())
+	if err != nil {
+		return "", object.NewError(object.IMPORT_ERROR, "cannot import '%s': %s", importPath, err)
+	}
+
+	var candidates []string
+
+	if filepath.IsAbs(importPath) {
+		candidates = append(candidates, importPath)
+	} else {
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+
+		candidates = append(candidates, filepath.Join(dir, importPath))
+		for _, dir := range searchPath {
+			candidates = append(candidates, filepath.Join(dir, importPath))
+		}
+	}
+
+	var looked, outside []string
+	for _, candidate := range candidates {
+		if !filepath.IsAbs(candidate) {
+			candidate = filepath.Join(root, candidate)
+		}
+		if slices.Contains(looked, candidate) || slices.Contains(outside, candidate) {
+			continue
+		}
+
+		name, ok := inRoot(fs, candidate)
+		if !ok {
+			outside = append(outside, candidate)
+			continue
+		}
+
+		looked = append(looked, candidate)
+		if info, err := fs.Stat(name); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	// whether what's outside exists is none of the script's business
+	if len(looked) == 0 {
+		return "", object.NewError(object.IMPORT_ERROR, "cannot import '%s': it's outside of the filesystem root %s (tried %s)",
+			importPath, root, strings.Join(outside, ", "))
+	}
+
+	if len(outside) != 0 {
+		return "", object.NewError(object.IMPORT_ERROR, "module '%s' not found (looked in %s, and skipped %s, outside of the filesystem root %s)",
+			importPath, strings.Join(looked, ", "), strings.Join(outside, ", "), root)
+	}
+
+	return "", object.NewError(object.IMPORT_ERROR, "module '%s' not found (looked in %s)",
+		importPath, strings.Join(looked, ", "))
+}
+
+// inRoot is the name of the file at the absolute path within fs,
+// the second return value being false if it's not inside of it
+func inRoot(fs *os.Root, path string) (string, bool) {
+	root, err := filepath.Abs(fs.Name())
+	if err != nil {
+		return "", false
+	}
+
+	name, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// Load returns the module at path, found by FindModule in fs and
+// imported by the file importer, running its program with run the
+// first time around. run returns the *object.Module the program turned
+// into, or the error it failed with. If another task is loading the
+// module already, Load waits for it to be done
+func (cache *ModuleCache) Load(fs *os.Root, importer string, path string, run func(program *ast.Program) object.Object) object.Object {
+	cache.mu.Lock()
+	if module, ok := cache.modules[path]; ok {
+		cache.mu.Unlock()
+		return module
+	}
+
+	if chain := cache.cycle(path, importer); chain != nil {
+		cache.mu.Unlock()
+
+		var names []string
+		for _, p := range append(chain, path) {
+			names = append(names, filepath.Base(p))
+		}
+		return object.NewError(object.IMPORT_ERROR, "cyclic import: %s", strings.Join(names, " -> "))
+	}
+
+	if l, ok := cache.loading[importer]; ok {
+		l.waitingFor[path]++
+		defer func() {
+			cache.mu.Lock()
+			if l.waitingFor[path]--; l.waitingFor[path] == 0 {
+				delete(l.waitingFor, path)
+			}
+			cache.mu.Unlock()
+		}()
+	}
+
+	if l, ok := cache.loading[path]; ok {
+		cache.mu.Unlock()
+		<-l.done
+		return ownCopy(l.result)
+	}
+
+	l := &loading{done: make(chan struct{}), waitingFor: make(map[string]int)}
+	cache.loading[path] = l
+	cache.mu.Unlock()
+
+	result := cache.load(fs, path, run)
+	l.result = ownCopy(result)
+
+	cache.mu.Lock()
+	delete(cache.loading, path)
+	if module, ok := result.(*object.Module); ok {
+		cache.modules[path] = module
+	}
+	cache.mu.Unlock()
+	close(l.done)
+
+	return result
+}
+
+// ownCopy copies errors, whose stacks get filled in
+// by every importer as they make their way up
+func ownCopy(result object.Object) object.Object {
+	if err, ok := result.(*object.Error); ok {
+		copied := *err
+		return &copied
+	}
+
+	return result
+}
+
+// cycle returns the import chain that leads from path back to the
+// importer, if path can't be loaded without the importer being done
+func (cache *ModuleCache) cycle(path string, importer string) []string {
+	if path == importer {
+		return []string{path}
+	}
+
+	l, ok := cache.loading[path]
+	if !ok {
+		return nil
+	}
+
+	for next := range l.waitingFor {
+		if chain := cache.cycle(next, importer); chain != nil {
+			return append([]string{path}, chain...)
+		}
+	}
+
+	return nil
+}
+
+// load reads the module at path out of fs and runs it
+func (cache *ModuleCache) load(fs *os.Root, path string, run func(program *ast.Program) object.Object) object.Object {
+	name, ok := inRoot(fs, path)
+	if !ok {
+		return object.NewError(object.IMPORT_ERROR, "cannot read module '%s': it's outside of %s", path, fs.Name())
+	}
+
+	content, err := fs.ReadFile(name)
+	if err != nil {
+		return object.NewError(object.IMPORT_ERROR, "cannot read module '%s': %s", path, err)
+	}
+
+	// the first error is enough to go on, pingul check lists the rest
+	program, errors := parser.ParseFromString(string(content))
+	if len(errors) == 0 {
+		errors = resolver.New().Resolve(program)
+	}
+	switch {
+	case len(errors) == 1:
+		return object.NewError(object.IMPORT_ERROR, "module '%s' has errors: %s", filepath.Base(path), errors[0])
+	case len(errors) > 1:
+		return object.NewError(object.IMPORT_ERROR, "module '%s' has errors: %s (and %d more, run pingul check on it to see them)",
+			filepath.Base(path), errors[0], len(errors)-1)
+	}
+
+	return run(program)
+}
+
+// ExportedNames are the names the module lets others import
+func ExportedNames(program *ast.Program) []string {
+	var names []string
+
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.VarStatement:
+			if stmt.Exported {
+				names = append(names, stmt.Name.String())
+			}
+		case *ast.EnumStatement:
+			if stmt.Exported {
+				names = append(names, stmt.Name.String())
+			}
+		}
+	}
+
+	return names
+}
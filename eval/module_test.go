@@ -0,0 +1,274 @@
+package eval_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// writeModules lays the files out in a temporary directory,
+// returning the directory
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// importing is an interpreter that can import the modules
+// in dir, and nothing outside of it
+func importing(t *testing.T, dir string) *eval.Interpreter {
+	t.Helper()
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+
+	return eval.NewWithCapabilities(object.Capabilities{FS: root})
+}
+
+// evalModule evaluates the input as if it was the file main.pl of dir
+func evalModule(in *eval.Interpreter, dir string, input string) object.Object {
+	scope := object.NewModuleScope(filepath.Join(dir, "main.pl"))
+
+	return in.Eval(scope, parseProgram(input))
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.pl": `
+var square = func(x) { x * x };
+export var cube = func(x) { square(x) * x };
+export var answer = 42;`,
+		"lib/strings.pl": `
+from "helpers.pl" import excite;
+export var shout = func(s) { excite(s + "!") };`,
+		"lib/helpers.pl": `export var excite = func(s) { s + "!!" };`,
+		"shapes.pl":      `export enum Shape { Point, Circle(r) };`,
+	})
+
+	testCases := []struct {
+		input    string
+		expected object.Object
+	}{
+		{`import "math.pl" as m; m.cube(3);`, &object.Integer{Value: 27}},
+		{`import "math.pl" as m; m.answer;`, &object.Integer{Value: 42}},
+		{`from "math.pl" import cube, answer; cube(2) + answer;`, &object.Integer{Value: 50}},
+		// imports of a module are relative to the module itself
+		{`from "lib/strings.pl" import shout; shout("hi");`, &object.String{Value: []rune("hi!!!")}},
+		{`from "shapes.pl" import Shape; Shape.Circle(2).r;`, &object.Integer{Value: 2}},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalModule(importing(t, dir), dir, tc.input)
+
+		switch expected := tc.expected.(type) {
+		case *object.Integer:
+			assertIntegerObject(t, evaluated, expected.Value)
+		case *object.String:
+			assertStringObject(t, evaluated, string(expected.Value))
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.pl":   `var square = func(x) { x * x }; export var answer = 42;`,
+		"a.pl":      `from "b.pl" import b; export var a = 1;`,
+		"b.pl":      `from "a.pl" import a; export var b = 2;`,
+		"broken.pl": `export var x = undefinedThing;`,
+		"throws.pl": `throw "nope";`,
+		"syntax.pl": "var x = 1;\nvar y = ;",
+		"worse.pl":  "var x = a;\nvar y = b;",
+	})
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`import "math.pl" as m; m.square(2);`, "module 'math.pl' does not export 'square'"},
+		{`from "math.pl" import square;`, "module 'math.pl' does not export 'square'"},
+		{`import "a.pl" as a;`, "cyclic import: a.pl -> b.pl -> a.pl"},
+		// the first error of a module that doesn't parse says where it is
+		{`import "broken.pl" as b;`, "module 'broken.pl' has errors: 1:16: undefined variable 'undefinedThing'"},
+		{`import "syntax.pl" as s;`, "module 'syntax.pl' has errors: 2:9: syntax error"},
+		{`import "worse.pl" as w;`, "module 'worse.pl' has errors: 1:9: undefined variable 'a' (and 1 more, run pingul check on it to see them)"},
+		{`import "throws.pl" as t;`, "nope"},
+	}
+
+	for _, tc := range testCases {
+		evaluated := evalModule(importing(t, dir), dir, tc.input)
+		assertErrorObject(t, evaluated, tc.expected)
+	}
+
+	// modules can't be imported from outside of the file system
+	// the interpreter was given, nor without one at all
+	outside := writeModules(t, map[string]string{"outside.pl": `export var x = 1;`})
+	outsidePath := filepath.Join(outside, "outside.pl")
+	notFound := []struct {
+		path     string
+		expected string
+	}{
+		{"missing.pl", fmt.Sprintf("module 'missing.pl' not found (looked in %s)", filepath.Join(dir, "missing.pl"))},
+		{outsidePath, fmt.Sprintf("cannot import '%s': it's outside of the filesystem root %s (tried %s)", outsidePath, dir, outsidePath)},
+		{"../" + filepath.Base(outside) + "/outside.pl", fmt.Sprintf("cannot import '../%s/outside.pl': it's outside of the filesystem root %s (tried %s)",
+			filepath.Base(outside), dir, outsidePath)},
+	}
+	for _, tc := range notFound {
+		evaluated := evalModule(importing(t, dir), dir, fmt.Sprintf("import %q as m;", tc.path))
+		assertErrorKind(t, evaluated, object.IMPORT_ERROR, tc.expected)
+	}
+
+	// the search path is skipped where it leaves the file system
+	in := importing(t, dir)
+	in.SearchPath = []string{outside}
+	evaluated := evalModule(in, dir, `import "outside.pl" as m;`)
+	assertErrorKind(t, evaluated, object.IMPORT_ERROR, fmt.Sprintf(
+		"module 'outside.pl' not found (looked in %s, and skipped %s, outside of the filesystem root %s)",
+		filepath.Join(dir, "outside.pl"), outsidePath, dir))
+
+	evaluated = evalModule(eval.New(), dir, `import "math.pl" as m;`)
+	assertErrorKind(t, evaluated, object.PERMISSION_ERROR, "import needs the filesystem capability")
+}
+
+func TestModulesAreEvaluatedOnce(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"state.pl": `export var items = [1, 2, 3];`,
+		"user.pl":  `import "state.pl" as state; pop(state.items);`,
+	})
+
+	in := importing(t, dir)
+	evaluated := evalModule(in, dir, `
+import "state.pl" as first;
+pop(first.items);
+import "user.pl" as user;
+import "state.pl" as second;
+len(second.items);`)
+	assertIntegerObject(t, evaluated, 1)
+
+	// the same interpreter keeps its modules around between runs
+	evaluated = evalModule(in, dir, `from "state.pl" import items; len(items);`)
+	assertIntegerObject(t, evaluated, 1)
+
+	// a different one starts fresh
+	evaluated = evalModule(importing(t, dir), dir, `from "state.pl" import items; len(items);`)
+	assertIntegerObject(t, evaluated, 3)
+}
+
+// tasks importing a module that's being loaded wait for it,
+// rather than taking each other for a cyclic import
+func TestConcurrentImports(t *testing.T) {
+	slow := `var count = func(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(20000); `
+	dir := writeModules(t, map[string]string{
+		"slow.pl": slow + `export var items = [42];`,
+		"ping.pl": slow + `from "pong.pl" import pong; export var ping = 1;`,
+		"pong.pl": slow + `from "ping.pl" import ping; export var pong = 2;`,
+	})
+
+	testCases := []struct {
+		input    string
+		expected any
+	}{
+		{`var f = func() { import "slow.pl" as m; m.items };
+var a = spawn f(); var b = spawn f();
+await(a) is await(b);`, true},
+		{`var f = func() { import "slow.pl" as m; m.items[0] }; await(spawn f()) + await(spawn f());`, 84},
+		// a cycle split between two tasks fails instead of leaving both waiting
+		{`var ping = func() { import "ping.pl" as m; m.ping };
+var pong = func() { import "pong.pl" as m; m.pong };
+var a = spawn ping(); var b = spawn pong();
+var failed = func(task) { try { await(task); false } catch (e) { true } };
+failed(a) or failed(b);`, true},
+	}
+
+	for _, tc := range testCases {
+		in := importing(t, dir)
+		done := make(chan object.Object)
+		go func() { done <- evalModule(in, dir, tc.input) }()
+
+		select {
+		case evaluated := <-done:
+			switch expected := tc.expected.(type) {
+			case int:
+				assertIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				assertBooleanObject(t, evaluated, expected)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("The imports of %q never finished", tc.input)
+		}
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"libs/greet.pl": `export var greet = func(name) { "hello " + name };`,
+		"app/.keep":     "",
+	})
+
+	// the directories of the search path are in the file system too
+	for _, libs := range []string{"libs", filepath.Join(root, "libs")} {
+		in := importing(t, root)
+		in.SearchPath = []string{libs}
+
+		evaluated := evalModule(in, filepath.Join(root, "app"), `from "greet.pl" import greet; greet("pingu");`)
+		assertStringObject(t, evaluated, "hello pingu")
+	}
+}
+
+func TestStrictModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"strict.pl": `"use strict";
+export var add = func(a, b) { a + b };`,
+		"lenient.pl": `export var add = func(a, b) { a + b };`,
+	})
+
+	// functions stay as strict as the file they were written in,
+	// no matter who calls them
+	evaluated := evalModule(importing(t, dir), dir, `from "strict.pl" import add; add(1, true);`)
+	assertErrorObject(t, evaluated, "unsupported operand types for +: INT and BOOL")
+
+	evaluated = evalModule(importing(t, dir), dir, `"use strict"; from "lenient.pl" import add; add(1, true);`)
+	assertIntegerObject(t, evaluated, 2)
+}
+
+func TestTracebacksAcrossModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.pl": `export var half = func(n) {
+  [split(n, 0)]
+};
+var split = func(n, parts) { [n / parts] };`,
+	})
+
+	evaluated := evalModule(importing(t, dir), dir, `from "math.pl" import half;
+[half(4)];`)
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("Expected an error. Got=%s", evaluated.Inspect())
+	}
+
+	expected := "Traceback (most recent call first):\n" +
+		"  in split (2 args), called at " + filepath.Join(dir, "math.pl") + ", line 2, column 9\n" +
+		"  in half (1 arg), called at " + filepath.Join(dir, "main.pl") + ", line 2, column 6\n"
+	if err.Traceback() != expected {
+		t.Fatalf("Wrong traceback. Got=\n%s\nExpected=\n%s", err.Traceback(), expected)
+	}
+}
@@ -0,0 +1,61 @@
+package eval
+
+import (
+	"strings"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+)
+
+// The bytecode VM borrows the rules below from the evaluator, so that
+// the two can't disagree on what a program means. They're the built-in
+// rules only, running the metamethods is up to the caller
+
+// Infix applies an infix operator other than and & or, on behalf
+// of the caller frame, which is nil outside of a function call
+func Infix(caller *object.Frame, operator string, left object.Object, right object.Object, strict bool) object.Object {
+	return evalInfixExpression(caller, operator, left, right, strict)
+}
+
+// Prefix applies a prefix operator
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Index looks the index up in a list, a tuple or a dict
+func Index(collection object.Object, index object.Object) object.Object {
+	return evalIndexExpression(collection, index)
+}
+
+// PropertyAccess looks the property up, binding the methods it finds
+func PropertyAccess(obj object.Object, property string) object.Object {
+	return evalPropertyAccess(obj, property)
+}
+
+// NewEnum creates the enum an enum statement declares
+func NewEnum(node *ast.EnumStatement) *object.Enum {
+	variants := make([]string, len(node.Variants))
+	fields := make([][]string, len(node.Variants))
+	for i, variant := range node.Variants {
+		variants[i] = variant.Name.String()
+		for _, field := range variant.Fields {
+			fields[i] = append(fields[i], field.String())
+		}
+	}
+
+	return object.NewEnum(node.Name.String(), variants, fields)
+}
+
+// how many frames a StackOverflow shows
+const overflowFrames = 5
+
+// StackOverflow is the error of a call that went deeper than maxDepth
+func StackOverflow(maxDepth int, frame *object.Frame) *object.Error {
+	calls := strings.Join(frame.Innermost(overflowFrames), ", ")
+	if frame.Depth > overflowFrames {
+		calls += ", ..."
+	}
+
+	return object.NewError(object.STACK_OVERFLOW,
+		"maximum call depth of %d exceeded (innermost calls: %s)", maxDepth, calls)
+}
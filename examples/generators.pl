@@ -0,0 +1,14 @@
+var greetings = func(name) {
+  yield "Hello, " + name + "!";
+  yield "Goodbye, " + name + "!";
+};
+
+var g = greetings("Pingu");
+print(next(g));
+print(next(g));
+
+var evens = lazy_filter(range(1, 1000000000), func(x) { x % 2 == 0 });
+var squares = lazy_map(evens, func(x) { x * x });
+
+print("FIRST 5 EVEN SQUARES: ");
+print(collect(take(squares, 5)));
@@ -0,0 +1,21 @@
+export var map = func(list, fun) {
+  var iter = func(list, acc) {
+    if (len(list) == 0) {
+      return acc;
+    }
+    return iter(tail(list), append(acc, fun(head(list))));
+  };
+
+  return iter(list, []);
+}
+
+export var reduce = func(list, fun, initial) {
+  var iter = func(list, acc) {
+    if (len(list) == 0) {
+      return acc;
+    }
+    return iter(tail(list), fun(acc, head(list)));
+  };
+
+  return iter(list, initial);
+}
@@ -0,0 +1,17 @@
+var countdown = func(n) {
+  if (n == 0) {
+    "liftoff";
+  } else {
+    countdown(n - 1);
+  }
+};
+
+print("TAIL CALLS DON'T PILE UP: ");
+print(countdown(1000000));
+
+var depth = func(n) {
+  depth(n + 1) + 1;
+};
+
+print("THE OTHER CALLS DO: ");
+print(try { depth(0) } catch (e) { e.type + ": " + e.message });
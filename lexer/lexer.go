@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"sort"
+
 	"github.com/aziflaj/pingul/token"
 )
 
@@ -23,16 +25,23 @@ type LexerImpl struct {
 
 	// ch is short for char, but it's not really a char, it's a rune. sue me :)
 	ch rune
+
+	// where each line of the input starts
+	lineStarts []int
 }
 
 func New(input string) *Lexer {
-	impl := &LexerImpl{input: []rune(input)}
-	impl.readChar()
-	return &Lexer{impl: impl}
+	return &Lexer{impl: NewLexerImpl(input)}
 }
 
 func NewLexerImpl(input string) *LexerImpl {
-	lxr := &LexerImpl{input: []rune(input)}
+	lxr := &LexerImpl{input: []rune(input), lineStarts: []int{0}}
+	for i, ch := range lxr.input {
+		if ch == '\n' {
+			lxr.lineStarts = append(lxr.lineStarts, i+1)
+		}
+	}
+
 	lxr.readChar()
 	return lxr
 }
@@ -44,7 +53,9 @@ func (l *Lexer) NextToken() token.Token {
 
 // NextToken for LexerImpl
 func (l *LexerImpl) NextToken() token.Token {
+	start := l.position
 	tkn := token.Token{Literal: l.readNextToken()}
+	tkn.Line, tkn.Column = l.lineAndColumn(start)
 
 	// handle empty literals, i.e. EOF, whitespace, newlines, tabs,	etc.
 	if len(tkn.Literal) == 0 {
@@ -85,6 +96,15 @@ getNextToken:
 	return tkn
 }
 
+// lineAndColumn turns a position in the input into a line and a column
+func (l *LexerImpl) lineAndColumn(position int) (int, int) {
+	line := sort.Search(len(l.lineStarts), func(i int) bool {
+		return l.lineStarts[i] > position
+	})
+
+	return line, position - l.lineStarts[line-1] + 1
+}
+
 func (l *LexerImpl) readChar() {
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
@@ -200,3 +200,52 @@ var list = [1, 2, 3];`
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "var add = func(a, b) {\n\ta + b;\n};\n\nadd(\"one two\", -1)"
+
+	tests := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"var", 1, 1},
+		{"add", 1, 5},
+		{"=", 1, 9},
+		{"func", 1, 11},
+		{"(", 1, 15},
+		{"a", 1, 16},
+		{",", 1, 17},
+		{"b", 1, 19},
+		{")", 1, 20},
+		{"{", 1, 22},
+		{"a", 2, 2},
+		{"+", 2, 4},
+		{"b", 2, 6},
+		{";", 2, 7},
+		{"}", 3, 1},
+		{";", 3, 2},
+		{"add", 5, 1},
+		{"(", 5, 4},
+		{"one two", 5, 5},
+		{",", 5, 14},
+		{"-", 5, 16},
+		{"1", 5, 17},
+		{")", 5, 18},
+	}
+
+	lxr := lexer.New(input)
+
+	for i, tt := range tests {
+		tkn := lxr.NextToken()
+
+		if string(tkn.Literal) != tt.literal {
+			t.Fatalf("tests[%d] - wrong Token Literal. Expected=%q, got=%q", i, tt.literal, string(tkn.Literal))
+		}
+
+		if tkn.Line != tt.line || tkn.Column != tt.column {
+			t.Fatalf("tests[%d] - wrong position of %q. Expected=%d:%d, got=%d:%d",
+				i, tt.literal, tt.line, tt.column, tkn.Line, tkn.Column)
+		}
+	}
+}
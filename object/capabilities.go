@@ -0,0 +1,315 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Capabilities are what intrinsics get to touch outside of the
+// interpreter. The zero value grants none of them, which leaves
+// scripts with nothing but pure computation
+type Capabilities struct {
+	// where print writes to
+	Stdout io.Writer
+
+	// the directory read_file and write_file can't get out of
+	FS *os.Root
+
+	// looks environment variables up for env, e.g. os.LookupEnv
+	Env func(name string) (string, bool)
+
+	// tells the time for now, e.g. time.Now
+	Clock func() time.Time
+
+	// where random gets its numbers from
+	Random *rand.Rand
+
+	// what fetch makes its requests with
+	Network *http.Client
+}
+
+// Everything grants every capability, with the file system rooted
+// at dir. It's for running your own scripts, not somebody else's
+func Everything(dir string) (Capabilities, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	return Capabilities{
+		Stdout:  os.Stdout,
+		FS:      root,
+		Env:     os.LookupEnv,
+		Clock:   time.Now,
+		Random:  rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		Network: http.DefaultClient,
+	}, nil
+}
+
+// fetch gives up on servers that take longer than FetchTimeout
+// to respond, and on responses bigger than MaxFetchSize bytes
+const (
+	FetchTimeout = 30 * time.Second
+	MaxFetchSize = 10 << 20
+)
+
+// Intrinsics returns the intrinsic functions, where the
+// ones that reach outside of the interpreter can only do
+// as much as caps allows them to
+func Intrinsics(caps Capabilities) FuncTable {
+	return IntrinsicsWithin(context.Background(), caps, 0)
+}
+
+// IntrinsicsWithin returns the intrinsics of Intrinsics, except
+// that none of them makes a list, or reads a string, of more than
+// maxAllocation items or characters. They fail before they do.
+// The ones that wait on tasks, channels or the network give up
+// once ctx is done
+func IntrinsicsWithin(ctx context.Context, caps Capabilities, maxAllocation int) FuncTable {
+	table := make(FuncTable, len(pureFuncs))
+	for name, fun := range pureFuncs {
+		table[name] = fun
+	}
+
+	for name, fun := range blockingFuncs(ctx) {
+		table[name] = fun
+	}
+
+	for name, fun := range sizedFuncs(maxAllocation) {
+		table[name] = fun
+	}
+
+	for name, fun := range caps.intrinsics(ctx, maxAllocation) {
+		table[name] = fun
+	}
+
+	return table
+}
+
+func (caps Capabilities) intrinsics(ctx context.Context, maxAllocation int) FuncTable {
+	// tasks can ask for random numbers at the same time
+	var randomMu sync.Mutex
+
+	return FuncTable{
+		"print": func(caller *Frame, args ...Object) Object {
+			if caps.Stdout == nil {
+				return permissionError("print", "stdout")
+			}
+
+			for _, arg := range args {
+				fmt.Fprintln(caps.Stdout, InspectFrom(caller, arg))
+			}
+			return Null
+		},
+
+		// read_file(path) returns what the file has in it
+		"read_file": func(caller *Frame, args ...Object) Object {
+			path, err := stringArg("read_file", args)
+			if err != nil {
+				return err
+			}
+
+			if caps.FS == nil {
+				return permissionError("read_file", "filesystem")
+			}
+
+			file, openErr := caps.FS.Open(path)
+			if openErr != nil {
+				return NewError(RUNTIME_ERROR, "read_file(): %s", openErr)
+			}
+			defer file.Close()
+
+			return readString("read_file", file, maxAllocation)
+		},
+
+		// write_file(path, content) replaces whatever the file has in it
+		"write_file": func(caller *Frame, args ...Object) Object {
+			if err := checkArgCount("write_file", args, 2); err != nil {
+				return err
+			}
+
+			path, ok := args[0].(*String)
+			if !ok {
+				return NewError(TYPE_ERROR, "write_file() expects a STRING path, got %s", args[0].Type())
+			}
+
+			content, ok := args[1].(*String)
+			if !ok {
+				return NewError(TYPE_ERROR, "write_file() expects STRING content, got %s", args[1].Type())
+			}
+
+			if caps.FS == nil {
+				return permissionError("write_file", "filesystem")
+			}
+
+			if writeErr := caps.FS.WriteFile(string(path.Value), []byte(string(content.Value)), 0o644); writeErr != nil {
+				return NewError(RUNTIME_ERROR, "write_file(): %s", writeErr)
+			}
+
+			return Null
+		},
+
+		// env(name) returns the environment variable, or nil if it's not set
+		"env": func(caller *Frame, args ...Object) Object {
+			name, err := stringArg("env", args)
+			if err != nil {
+				return err
+			}
+
+			if caps.Env == nil {
+				return permissionError("env", "environment")
+			}
+
+			value, ok := caps.Env(name)
+			if !ok {
+				return Null
+			}
+
+			return &String{Value: []rune(value)}
+		},
+
+		// now() returns the milliseconds since the Unix epoch
+		"now": func(caller *Frame, args ...Object) Object {
+			if err := checkArgCount("now", args, 0); err != nil {
+				return err
+			}
+
+			if caps.Clock == nil {
+				return permissionError("now", "clock")
+			}
+
+			return NewInteger(caps.Clock().UnixMilli())
+		},
+
+		// random(n) returns an INT from 0 up to, but not including, n
+		"random": func(caller *Frame, args ...Object) Object {
+			if err := checkArgCount("random", args, 1); err != nil {
+				return err
+			}
+
+			n, ok := args[0].(*Integer)
+			if !ok || n.Value <= 0 {
+				return NewError(ARGUMENT_ERROR, "random() expects a positive INT, got %s", InspectFrom(caller, args[0]))
+			}
+
+			if caps.Random == nil {
+				return permissionError("random", "random")
+			}
+
+			randomMu.Lock()
+			defer randomMu.Unlock()
+
+			return NewInteger(caps.Random.Int64N(n.Value))
+		},
+
+		// fetch(url) makes a GET request, returning the body of the response
+		"fetch": func(caller *Frame, args ...Object) Object {
+			url, err := stringArg("fetch", args)
+			if err != nil {
+				return err
+			}
+
+			if caps.Network == nil {
+				return permissionError("fetch", "network")
+			}
+
+			// the request doesn't outlive the run
+			reqCtx, cancel := context.WithTimeout(ctx, FetchTimeout)
+			defer cancel()
+
+			req, reqErr := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+			if reqErr != nil {
+				return NewError(RUNTIME_ERROR, "fetch(): %s", reqErr)
+			}
+
+			resp, getErr := caps.Network.Do(req)
+			if getErr != nil {
+				if ctx.Err() != nil {
+					return Interrupted(ctx)
+				}
+				return NewError(RUNTIME_ERROR, "fetch(): %s", getErr)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode >= 400 {
+				return NewError(RUNTIME_ERROR, "fetch(): %s responded with %s", url, resp.Status)
+			}
+
+			body := &cappedReader{r: io.LimitReader(resp.Body, MaxFetchSize+1), max: MaxFetchSize}
+			return readString("fetch", body, maxAllocation)
+		},
+	}
+}
+
+// readString reads r into a STRING for read_file and fetch, one
+// character at a time, failing before it gets more than maxAllocation
+// of them, unless it's 0
+func readString(name string, r io.Reader, maxAllocation int) Object {
+	if maxAllocation == 0 {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return NewError(RUNTIME_ERROR, "%s(): %s", name, err)
+		}
+
+		return &String{Value: []rune(string(content))}
+	}
+
+	reader := bufio.NewReader(r)
+	value := []rune{}
+	for {
+		char, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return &String{Value: value}
+		}
+		if err != nil {
+			return NewError(RUNTIME_ERROR, "%s(): %s", name, err)
+		}
+
+		if err := CheckAllocation(STRING, int64(len(value))+1, "characters", maxAllocation); err != nil {
+			return err
+		}
+		value = append(value, char)
+	}
+}
+
+// cappedReader fails once more than max bytes have been read
+type cappedReader struct {
+	r    io.Reader
+	read int64
+	max  int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	if c.read > c.max {
+		return n, fmt.Errorf("the response is bigger than %d bytes", c.max)
+	}
+
+	return n, err
+}
+
+func permissionError(name string, capability string) *Error {
+	return NewError(PERMISSION_ERROR, "%s() needs the %s capability", name, capability)
+}
+
+// for the intrinsics that take a single STRING
+func stringArg(name string, args []Object) (string, *Error) {
+	if err := checkArgCount(name, args, 1); err != nil {
+		return "", err
+	}
+
+	str, ok := args[0].(*String)
+	if !ok {
+		return "", NewError(TYPE_ERROR, "%s() expects a STRING, got %s", name, args[0].Type())
+	}
+
+	return string(str.Value), nil
+}
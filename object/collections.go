@@ -0,0 +1,197 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HashKey identifies a hashable value: two values have
+// the same HashKey if and only if they are equal
+type HashKey struct {
+	Type  ObjectType
+	Value string
+}
+
+// Hash returns the HashKey of obj. Only immutable values can be
+// hashed: integers, booleans, strings, nil, and tuples, sets and
+// enum variants made of them. Everything else reports false
+func Hash(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return HashKey{Type: INT, Value: strconv.FormatInt(obj.Value, 10)}, true
+	case *Boolean:
+		return HashKey{Type: BOOL, Value: strconv.FormatBool(obj.Value)}, true
+	case *String:
+		return HashKey{Type: STRING, Value: string(obj.Value)}, true
+	case *Nil:
+		return HashKey{Type: NIL}, true
+	case *Tuple:
+		keys := make([]HashKey, len(obj.Items))
+		for i, item := range obj.Items {
+			key, ok := Hash(item)
+			if !ok {
+				return HashKey{}, false
+			}
+			keys[i] = key
+		}
+		return HashKey{Type: TUPLE, Value: joinKeys(keys)}, true
+	case *Variant:
+		// variants of different enums are never equal,
+		// even if they happen to have the same names
+		keys := make([]HashKey, len(obj.Payload))
+		for i, val := range obj.Payload {
+			key, ok := Hash(val)
+			if !ok {
+				return HashKey{}, false
+			}
+			keys[i] = key
+		}
+		return HashKey{Type: VARIANT, Value: fmt.Sprintf("%p.%s:%s", obj.Enum, obj.Tag, joinKeys(keys))}, true
+	case *Set:
+		// the same elements make the same set, whatever the order
+		keys := make([]HashKey, 0, len(obj.Items))
+		for key := range obj.keys {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+		return HashKey{Type: SET, Value: joinKeys(keys)}, true
+	}
+
+	return HashKey{}, false
+}
+
+func (k HashKey) less(other HashKey) bool {
+	if k.Type != other.Type {
+		return k.Type < other.Type
+	}
+
+	return k.Value < other.Value
+}
+
+// joinKeys prefixes every key with its length, so that
+// no two different lists of keys are joined the same
+func joinKeys(keys []HashKey) string {
+	var b strings.Builder
+
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s:%d:%s", key.Type, len(key.Value), key.Value)
+	}
+
+	return b.String()
+}
+
+// Tuple is a fixed-size list that can't be changed
+type Tuple struct {
+	Items []Object
+}
+
+func (t *Tuple) Type() ObjectType { return TUPLE }
+func (t *Tuple) Inspect() string  { return t.inspect(nil) }
+
+func (t *Tuple) inspect(caller *Frame) string {
+	var b strings.Builder
+
+	b.WriteString("(")
+	for i, item := range t.Items {
+		b.WriteString(InspectFrom(caller, item))
+
+		if i < len(t.Items)-1 {
+			b.WriteString(", ")
+		} else if len(t.Items) == 1 {
+			b.WriteString(",")
+		}
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
+func (t *Tuple) IsTruthy() bool { return len(t.Items) > 0 }
+
+// Set holds every value at most once, in the order they were
+// first added. Sets can't be changed, which makes them hashable
+type Set struct {
+	Items []Object
+
+	keys map[HashKey]Object
+}
+
+// NewSet builds a set out of the items, skipping the duplicates
+func NewSet(items ...Object) (*Set, *Error) {
+	set := &Set{Items: []Object{}, keys: make(map[HashKey]Object)}
+
+	for _, item := range items {
+		key, ok := Hash(item)
+		if !ok {
+			return nil, NewError(TYPE_ERROR, "unhashable type: %s", item.Type())
+		}
+
+		if _, ok := set.keys[key]; !ok {
+			set.keys[key] = item
+			set.Items = append(set.Items, item)
+		}
+	}
+
+	return set, nil
+}
+
+func (s *Set) Type() ObjectType { return SET }
+func (s *Set) Inspect() string  { return s.inspect(nil) }
+
+func (s *Set) inspect(caller *Frame) string {
+	var b strings.Builder
+
+	b.WriteString("#{")
+	for i, item := range s.Items {
+		b.WriteString(InspectFrom(caller, item))
+
+		if i < len(s.Items)-1 {
+			b.WriteString(", ")
+		}
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+func (s *Set) IsTruthy() bool { return len(s.Items) > 0 }
+
+// Has tells whether obj is in the set
+func (s *Set) Has(obj Object) bool {
+	key, ok := Hash(obj)
+	if !ok {
+		return false
+	}
+
+	_, ok = s.keys[key]
+	return ok
+}
+
+// Union has the elements of both sets, the ones of s first
+func (s *Set) Union(other *Set) *Set {
+	union, _ := NewSet(append(append([]Object{}, s.Items...), other.Items...)...)
+	return union
+}
+
+// Intersect has the elements of s that are in other too
+func (s *Set) Intersect(other *Set) *Set {
+	return s.filter(func(item Object) bool { return other.Has(item) })
+}
+
+// Difference has the elements of s that are not in other
+func (s *Set) Difference(other *Set) *Set {
+	return s.filter(func(item Object) bool { return !other.Has(item) })
+}
+
+func (s *Set) filter(keep func(Object) bool) *Set {
+	var items []Object
+
+	for _, item := range s.Items {
+		if keep(item) {
+			items = append(items, item)
+		}
+	}
+
+	set, _ := NewSet(items...)
+	return set
+}
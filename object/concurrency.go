@@ -0,0 +1,160 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Task is a function call running on its own goroutine
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+// Spawn starts running fn in the background
+func Spawn(fn func() Object) *Task {
+	t := &Task{done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+		t.result = fn()
+	}()
+
+	return t
+}
+
+// Await blocks until the task is done, returning whatever
+// the function returned, or the error it failed with. If ctx
+// is done first, it returns the error saying so instead
+func (t *Task) Await(ctx context.Context) Object {
+	select {
+	case <-t.done:
+		return t.result
+	case <-ctx.Done():
+		return Interrupted(ctx)
+	}
+}
+
+func (t *Task) Type() ObjectType { return TASK }
+func (t *Task) Inspect() string  { return string(t.Type()) }
+func (t *Task) IsTruthy() bool   { return true }
+
+// Channel lets tasks hand values over to each other
+type Channel struct {
+	ch chan Object
+
+	// closing a Go channel while someone is sending on it is a race,
+	// so ch is never closed, and done is closed instead
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{
+		ch:   make(chan Object, capacity),
+		done: make(chan struct{}),
+	}
+}
+
+// Send blocks until there's room in the channel, or until ctx
+// is done. Sending on a closed channel is an error
+func (c *Channel) Send(ctx context.Context, val Object) *Error {
+	// don't let a free spot in the buffer win over an earlier close
+	select {
+	case <-c.done:
+		return NewError(RUNTIME_ERROR, "send on a closed channel")
+	default:
+	}
+
+	select {
+	case c.ch <- val:
+		return nil
+	case <-c.done:
+		return NewError(RUNTIME_ERROR, "send on a closed channel")
+	case <-ctx.Done():
+		return Interrupted(ctx)
+	}
+}
+
+// Recv blocks until there's a value in the channel. Once the channel
+// is closed and drained, it returns false right away. If ctx is done
+// first, the error saying so is returned
+func (c *Channel) Recv(ctx context.Context) (Object, bool, *Error) {
+	select {
+	case val := <-c.ch:
+		return val, true, nil
+	case <-c.done:
+		val, ok := c.drain()
+		return val, ok, nil
+	case <-ctx.Done():
+		return nil, false, Interrupted(ctx)
+	}
+}
+
+// drain gets whatever was sent before the channel got closed
+func (c *Channel) drain() (Object, bool) {
+	select {
+	case val := <-c.ch:
+		return val, true
+	default:
+		return nil, false
+	}
+}
+
+func (c *Channel) Close() *Error {
+	closed := false
+	c.closeOnce.Do(func() {
+		close(c.done)
+		closed = true
+	})
+
+	if !closed {
+		return NewError(RUNTIME_ERROR, "close of a closed channel")
+	}
+
+	return nil
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL }
+func (c *Channel) Inspect() string  { return string(c.Type()) }
+func (c *Channel) IsTruthy() bool   { return true }
+
+// Select waits until any of the channels has something to receive,
+// returning its index and the value. ok is false if that channel is
+// closed. If ctx is done first, the error saying so is returned
+func Select(ctx context.Context, channels []*Channel) (int, Object, bool, *Error) {
+	// every channel gets two cases: its values and its closing,
+	// and the context gets the last one
+	cases := make([]reflect.SelectCase, 2*len(channels)+1)
+	for i, c := range channels {
+		cases[2*i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)}
+		cases[2*i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.done)}
+	}
+	cases[len(cases)-1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+
+	chosen, val, _ := reflect.Select(cases)
+	index := chosen / 2
+
+	if chosen == len(cases)-1 {
+		return 0, nil, false, Interrupted(ctx)
+	}
+
+	if chosen%2 == 1 {
+		obj, ok := channels[index].drain()
+		return index, obj, ok, nil
+	}
+
+	return index, val.Interface().(Object), true, nil
+}
+
+// Interrupted is the error for code that stopped waiting because
+// ctx is done, saying whether it ran out of time or got cancelled
+func Interrupted(ctx context.Context) *Error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return NewError(TIME_LIMIT, "script ran out of time")
+	}
+
+	return NewError(CANCELLED, "script was cancelled")
+}
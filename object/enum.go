@@ -0,0 +1,127 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Enum is a tagged union, declared with `enum Name { A, B(x, y) }`.
+// Its variants are reached through it: variants without fields are
+// values on their own, e.g. Name.A, the others are constructors
+type Enum struct {
+	Name string
+
+	// the variant names, in the order they were declared
+	Variants []string
+
+	fields  map[string][]string
+	members map[string]Object
+}
+
+// NewEnum creates the enum, along with its values and constructors.
+// fields holds the field names of every variant, in the same order
+func NewEnum(name string, variants []string, fields [][]string) *Enum {
+	enum := &Enum{
+		Name:     name,
+		Variants: variants,
+		fields:   make(map[string][]string, len(variants)),
+		members:  make(map[string]Object, len(variants)),
+	}
+
+	for i, tag := range variants {
+		enum.fields[tag] = fields[i]
+
+		if len(fields[i]) == 0 {
+			// there's only ever one of them, so `is` works too
+			enum.members[tag] = &Variant{Enum: enum, Tag: tag}
+			continue
+		}
+
+		enum.members[tag] = enum.constructor(tag)
+	}
+
+	return enum
+}
+
+func (e *Enum) constructor(tag string) IntrinsicFunc {
+	return func(caller *Frame, args ...Object) Object {
+		if len(args) != len(e.fields[tag]) {
+			return NewError(ARGUMENT_ERROR, "%s.%s expects %d argument(s), got %d",
+				e.Name, tag, len(e.fields[tag]), len(args))
+		}
+
+		return &Variant{Enum: e, Tag: tag, Payload: args}
+	}
+}
+
+// Get returns the value or the constructor of a variant
+func (e *Enum) Get(tag string) (Object, bool) {
+	member, ok := e.members[tag]
+	return member, ok
+}
+
+// Fields returns the field names of a variant
+func (e *Enum) Fields(tag string) ([]string, bool) {
+	fields, ok := e.fields[tag]
+	return fields, ok
+}
+
+// NoVariant is the error for a tag the enum doesn't have
+func (e *Enum) NoVariant(tag string) *Error {
+	if suggestion := Suggest(tag, e.Variants); suggestion != "" {
+		return NewError(NAME_ERROR, "enum %s has no variant '%s' (did you mean '%s'?)", e.Name, tag, suggestion)
+	}
+
+	return NewError(NAME_ERROR, "enum %s has no variant '%s'", e.Name, tag)
+}
+
+func (e *Enum) Type() ObjectType { return ENUM }
+func (e *Enum) Inspect() string  { return fmt.Sprintf("%s(%s)", e.Type(), e.Name) }
+func (e *Enum) IsTruthy() bool   { return true }
+
+// Variant is a value of an enum: the tag of the variant
+// it was made with, and the values of its fields
+type Variant struct {
+	Enum    *Enum
+	Tag     string
+	Payload []Object
+}
+
+// Field returns the value of one of the fields of the variant
+func (v *Variant) Field(name string) (Object, bool) {
+	for i, field := range v.Enum.fields[v.Tag] {
+		if field == name {
+			return v.Payload[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func (v *Variant) Type() ObjectType { return VARIANT }
+func (v *Variant) IsTruthy() bool   { return true }
+func (v *Variant) Inspect() string  { return v.inspect(nil) }
+
+func (v *Variant) inspect(caller *Frame) string {
+	var b strings.Builder
+
+	b.WriteString(v.Enum.Name)
+	b.WriteString(".")
+	b.WriteString(v.Tag)
+
+	if len(v.Payload) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("(")
+	for i, val := range v.Payload {
+		b.WriteString(InspectFrom(caller, val))
+
+		if i < len(v.Payload)-1 {
+			b.WriteString(", ")
+		}
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package object
+
+import "reflect"
+
+// Equal compares two values structurally: lists, tuples and dicts
+// are equal when their items are, functions when they are the same
+// function literal closing over the same scope. Values of different
+// types are never equal, so 1 == true is false. Dicts with an __eq__
+// hook are compared by the hook
+func Equal(a, b Object) bool {
+	return EqualFrom(nil, a, b)
+}
+
+// EqualFrom is Equal, except that the __eq__ hooks it runs into are
+// called on behalf of caller, the frame of the pingul function
+// comparing the values. Go code running on behalf of pingul code uses this
+func EqualFrom(caller *Frame, a, b Object) bool {
+	return equal(caller, a, b, make(map[[2]Object]bool))
+}
+
+// Identical tells whether a and b are the very same value. Numbers,
+// strings, booleans and nil can't change, so they are identical
+// whenever they are equal
+func Identical(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer, *Boolean, *String, *Nil:
+		return Equal(a, b)
+	case IntrinsicFunc:
+		return sameIntrinsic(a, b.(IntrinsicFunc))
+	}
+
+	return a == b
+}
+
+// seen holds the pairs being compared further up, which are taken
+// to be equal, so that values that contain themselves don't
+// send the comparison in circles
+func equal(caller *Frame, a, b Object, seen map[[2]Object]bool) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	// only looked up for containers, which are all pointers
+	pair := [2]Object{a, b}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return string(a.Value) == string(b.(*String).Value)
+	case *Nil:
+		return true
+
+	case *List:
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+		return equalItems(caller, a.Elements(), b.(*List).Elements(), seen)
+
+	case *Tuple:
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+		return equalItems(caller, a.Items, b.(*Tuple).Items, seen)
+
+	case *Set:
+		// sets only hold hashable values
+		aKey, _ := Hash(a)
+		bKey, _ := Hash(b)
+		return aKey == bKey
+
+	case *Dict:
+		if hook, ok := a.Method(a, "__eq__"); ok {
+			return Apply(caller, hook, b).IsTruthy()
+		}
+
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+		return equalDicts(caller, a, b.(*Dict), seen)
+
+	case *Variant:
+		other := b.(*Variant)
+		if a.Enum != other.Enum || a.Tag != other.Tag {
+			return false
+		}
+
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+		return equalItems(caller, a.Payload, other.Payload, seen)
+
+	case *Func:
+		other := b.(*Func)
+		if a.Self == nil || other.Self == nil {
+			return sameClosure(a, other) && a.Self == nil && other.Self == nil
+		}
+		return sameClosure(a, other) && Identical(a.Self, other.Self)
+
+	case IntrinsicFunc:
+		return sameIntrinsic(a, b.(IntrinsicFunc))
+	}
+
+	return a == b
+}
+
+// sameClosure tells whether both functions come from the same
+// function expression, evaluated in the same place
+func sameClosure(a, b *Func) bool {
+	return a.Body == b.Body && a.Scope == b.Scope && a.Closure == b.Closure
+}
+
+func equalItems(caller *Frame, a, b []Object, seen map[[2]Object]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !equal(caller, a[i], b[i], seen) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalDicts(caller *Frame, a, b *Dict, seen map[[2]Object]bool) bool {
+	if a.Proto != b.Proto || a.Len() != b.Len() {
+		return false
+	}
+
+	a.mu.RLock()
+	pairs := make(map[string]Object, len(a.Pairs))
+	for key, val := range a.Pairs {
+		pairs[key] = val
+	}
+	keyed := make([]keyedPair, 0, len(a.keyed))
+	for _, pair := range a.keyed {
+		keyed = append(keyed, pair)
+	}
+	a.mu.RUnlock()
+
+	for key, val := range pairs {
+		other, ok := b.Get(key)
+		if !ok || !equal(caller, val, other, seen) {
+			return false
+		}
+	}
+
+	for _, pair := range keyed {
+		other, ok := b.GetKey(pair.Key)
+		if !ok || !equal(caller, pair.Value, other, seen) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameIntrinsic(a, b IntrinsicFunc) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
@@ -0,0 +1,106 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a place in the source code
+type Position struct {
+	// "" if the code didn't come from a file
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s, line %d, column %d", p.File, p.Line, p.Column)
+}
+
+// Frame is a call to a pingul function that hasn't returned yet.
+// Frames link to the frame of their caller, making up the call stack
+type Frame struct {
+	// the name of the function, or "<anonymous>"
+	Name string
+
+	// how many arguments the function got
+	Args int
+
+	// where the call was made, the zero Position if it was
+	// made by an intrinsic rather than by pingul code
+	Site Position
+
+	// the frame of the function making the call, nil for calls
+	// coming from the top level. Calls made by an intrinsic have
+	// the frame the intrinsic itself was called from
+	Caller *Frame
+
+	// how many frames there are, counting this one
+	Depth int
+}
+
+func NewFrame(name string, args int, site Position, caller *Frame) *Frame {
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	depth := 1
+	if caller != nil {
+		depth = caller.Depth + 1
+	}
+
+	return &Frame{Name: name, Args: args, Site: site, Caller: caller, Depth: depth}
+}
+
+// Innermost returns the names of up to n frames, starting from this one
+func (f *Frame) Innermost(n int) []string {
+	var names []string
+
+	for frame := f; frame != nil && len(names) < n; frame = frame.Caller {
+		names = append(names, frame.Name)
+	}
+
+	return names
+}
+
+func (f *Frame) String() string {
+	args := "args"
+	if f.Args == 1 {
+		args = "arg"
+	}
+
+	if f.Site.Line == 0 {
+		return fmt.Sprintf("%s (%d %s), called by an intrinsic", f.Name, f.Args, args)
+	}
+
+	return fmt.Sprintf("%s (%d %s), called at %s", f.Name, f.Args, args, f.Site)
+}
+
+// how many frames a traceback shows, the rest are only counted
+const tracebackFrames = 10
+
+// Traceback lists the calls the error went through, innermost
+// first, one per line. It's "" if the error never left the top level
+func (e *Error) Traceback() string {
+	if e.Stack == nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString("Traceback (most recent call first):\n")
+	for frame := e.Stack; frame != nil; frame = frame.Caller {
+		if e.Stack.Depth-frame.Depth == tracebackFrames {
+			fmt.Fprintf(&b, "  ... and %d more\n", frame.Depth)
+			break
+		}
+
+		fmt.Fprintf(&b, "  in %s\n", frame)
+	}
+
+	return b.String()
+}
@@ -1,112 +1,787 @@
 package object
 
-import "fmt"
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
 
 type FuncTable map[string]IntrinsicFunc
 
-var IntrinsicFuncs = FuncTable{
-	"print": func(args ...Object) Object {
-		for _, arg := range args {
-			fmt.Println(arg.Inspect())
+// Caller knows how to run a function body, which is the evaluator's
+// job. caller is the frame the call is made on behalf of, nil if
+// it's made from the top level
+type Caller interface {
+	Call(caller *Frame, fun Object, args ...Object) Object
+}
+
+// Apply calls a function with the given args, on behalf of the caller
+// frame, by way of the interpreter that created the function
+func Apply(caller *Frame, fun Object, args ...Object) Object {
+	switch fun := fun.(type) {
+	case IntrinsicFunc:
+		return fun(caller, args...)
+	case *Func:
+		return fun.Caller.Call(caller, fun, args...)
+	case *Dict:
+		if hook, ok := fun.Method(fun, "__call__"); ok {
+			return Apply(caller, hook, args...)
 		}
-		return &Nil{}
-	},
-	"len": func(args ...Object) Object {
-		if len(args) != 1 {
-			return &Nil{}
+	}
+
+	return NewError(TYPE_ERROR, "%s is not a function", fun.Type())
+}
+
+// IsCallable tells whether Apply can call obj
+func IsCallable(obj Object) bool {
+	switch obj := obj.(type) {
+	case IntrinsicFunc, *Func:
+		return true
+	case *Dict:
+		_, _, ok := obj.Lookup("__call__")
+		return ok
+	}
+
+	return false
+}
+
+// IntrinsicFuncs are the intrinsics of an interpreter with no
+// capabilities: all of them are there, but the ones that reach
+// outside of the interpreter fail with a PermissionError
+var IntrinsicFuncs = Intrinsics(Capabilities{})
+
+// the intrinsics anyone can have, since they're pure computation
+var pureFuncs = FuncTable{
+	"len": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("len", args, 1); err != nil {
+			return err
 		}
 
 		switch arg := args[0].(type) {
 		case *String:
-			return &Integer{Value: int64(len(arg.Value))}
+			return NewInteger(int64(len(arg.Value)))
 		case *List:
-			return &Integer{Value: int64(len(arg.Items))}
+			return NewInteger(int64(arg.Len()))
+		case *Tuple:
+			return NewInteger(int64(len(arg.Items)))
+		case *Set:
+			return NewInteger(int64(len(arg.Items)))
+		case *Dict:
+			if hook, ok := arg.Method(arg, "__len__"); ok {
+				return Apply(caller, hook)
+			}
+			return NewInteger(int64(arg.Len()))
 		default:
-			return &Nil{}
+			return NewError(TYPE_ERROR, "len() expects a STRING, LIST, TUPLE, SET or DICT, got %s", arg.Type())
+		}
+	},
+	"head": func(caller *Frame, args ...Object) Object {
+		list, err := listArg("head", args, 1)
+		if err != nil {
+			return err
+		}
+
+		if items := list.Elements(); len(items) > 0 {
+			return items[0]
+		}
+
+		return Null
+	},
+	"tail": func(caller *Frame, args ...Object) Object {
+		list, err := listArg("tail", args, 1)
+		if err != nil {
+			return err
+		}
+
+		if list.Len() > 0 {
+			return list.Tail()
+		}
+
+		return Null
+	},
+
+	"pop": func(caller *Frame, args ...Object) Object {
+		list, err := listArg("pop", args, 1)
+		if err != nil {
+			return err
+		}
+
+		if popped, ok := list.Pop(); ok {
+			return popped
+		}
+
+		return Null
+	},
+
+	"shift": func(caller *Frame, args ...Object) Object {
+		list, err := listArg("shift", args, 1)
+		if err != nil {
+			return err
+		}
+
+		if shifted, ok := list.Shift(); ok {
+			return shifted
+		}
+
+		return Null
+	},
+
+	"iterate": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("iterate", args, 1); err != nil {
+			return err
+		}
+
+		it, err := iterArg("iterate", args[0])
+		if err != nil {
+			return err
+		}
+
+		return it
+	},
+
+	// next returns nil once the iterator is exhausted
+	"next": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("next", args, 1); err != nil {
+			return err
+		}
+
+		it, ok := args[0].(Iterator)
+		if !ok {
+			return NewError(TYPE_ERROR, "next() expects an ITERATOR or a GENERATOR, got %s", args[0].Type())
+		}
+
+		val, ok := it.Next(caller)
+		if !ok {
+			return Null
+		}
+
+		return val
+	},
+
+	// range(end), range(start, end) or range(start, end, step)
+	"range": func(caller *Frame, args ...Object) Object {
+		if len(args) < 1 || len(args) > 3 {
+			return NewError(ARGUMENT_ERROR, "range() expects 1 to 3 argument(s), got %d", len(args))
+		}
+
+		bounds := make([]int64, len(args))
+		for i, arg := range args {
+			integer, ok := arg.(*Integer)
+			if !ok {
+				return NewError(TYPE_ERROR, "range() expects INT arguments, got %s", arg.Type())
+			}
+			bounds[i] = integer.Value
+		}
+
+		start, end, step := int64(0), bounds[0], int64(1)
+		if len(bounds) > 1 {
+			start, end = bounds[0], bounds[1]
+		}
+		if len(bounds) > 2 {
+			step = bounds[2]
+		}
+
+		if step == 0 {
+			return NewError(ARGUMENT_ERROR, "range() step can't be 0")
 		}
+
+		var mu sync.Mutex
+		current, done := start, (step > 0 && start >= end) || (step < 0 && start <= end)
+		return NewIterator(func(caller *Frame) (Object, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if done {
+				return nil, false
+			}
+
+			// current + step may not fit in an INT, but how far
+			// current is from the end always fits in a uint64
+			value := current
+			if step > 0 {
+				done = uint64(end)-uint64(current) <= uint64(step)
+			} else {
+				done = uint64(current)-uint64(end) <= -uint64(step)
+			}
+			if !done {
+				current += step
+			}
+
+			return NewInteger(value), true
+		})
 	},
-	"head": func(args ...Object) Object {
-		if len(args) != 1 {
-			return &Nil{}
+
+	"take": func(caller *Frame, args ...Object) Object {
+		it, n, err := iterAndCountArgs("take", args)
+		if err != nil {
+			return err
 		}
 
-		if list, ok := args[0].(*List); ok {
-			if len(list.Items) > 0 {
-				return list.Items[0]
+		var taken atomic.Int64
+		return NewIterator(func(caller *Frame) (Object, bool) {
+			if taken.Add(1) > n {
+				return nil, false
 			}
+
+			return it.Next(caller)
+		})
+	},
+
+	"drop": func(caller *Frame, args ...Object) Object {
+		it, n, err := iterAndCountArgs("drop", args)
+		if err != nil {
+			return err
 		}
 
-		return &Nil{}
+		// the items are dropped by whoever asks first, while the others wait
+		var mu sync.Mutex
+		dropped := false
+		return NewIterator(func(caller *Frame) (Object, bool) {
+			mu.Lock()
+			for ; !dropped && n > 0; n-- {
+				val, ok := it.Next(caller)
+				if !ok || val.Type() == ERROR {
+					mu.Unlock()
+					return val, ok
+				}
+			}
+			dropped = true
+			mu.Unlock()
+
+			return it.Next(caller)
+		})
 	},
-	"tail": func(args ...Object) Object {
-		if len(args) != 1 {
-			return &Nil{}
+
+	"lazy_map": func(caller *Frame, args ...Object) Object {
+		it, fun, err := iterAndFuncArgs("lazy_map", args)
+		if err != nil {
+			return err
 		}
 
-		if list, ok := args[0].(*List); ok {
-			if len(list.Items) > 0 {
-				return &List{Items: list.Items[1:]}
+		return NewIterator(func(caller *Frame) (Object, bool) {
+			val, ok := it.Next(caller)
+			if !ok || val.Type() == ERROR {
+				return val, ok
 			}
+
+			return Apply(caller, fun, val), true
+		})
+	},
+
+	"lazy_filter": func(caller *Frame, args ...Object) Object {
+		it, fun, err := iterAndFuncArgs("lazy_filter", args)
+		if err != nil {
+			return err
+		}
+
+		return NewIterator(func(caller *Frame) (Object, bool) {
+			for {
+				val, ok := it.Next(caller)
+				if !ok || val.Type() == ERROR {
+					return val, ok
+				}
+
+				keep := Apply(caller, fun, val)
+				if keep.Type() == ERROR {
+					return keep, true
+				}
+
+				if keep.IsTruthy() {
+					return val, true
+				}
+			}
+		})
+	},
+
+	"close": func(caller *Frame, args ...Object) Object {
+		ch, err := channelArg("close", args, 1)
+		if err != nil {
+			return err
+		}
+
+		if err := ch.Close(); err != nil {
+			return err
+		}
+
+		return Null
+	},
+
+	// bind(fn, obj) returns a copy of fn where self is obj
+	"bind": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("bind", args, 2); err != nil {
+			return err
 		}
 
-		return &Nil{}
+		fun, ok := args[0].(*Func)
+		if !ok {
+			return NewError(TYPE_ERROR, "bind() expects a FUNC, got %s", args[0].Type())
+		}
+
+		return fun.Bind(args[1])
 	},
 
-	"append": func(args ...Object) Object {
-		if len(args) != 2 {
-			return &Nil{}
+	// has(set, value) tells whether value is in the set,
+	// has(dict, key) whether the dict has the key
+	"has": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("has", args, 2); err != nil {
+			return err
 		}
 
-		if list, ok := args[0].(*List); ok {
-			return &List{Items: append(list.Items, args[1])}
+		switch collection := args[0].(type) {
+		case *Set:
+			return NewBoolean(collection.Has(args[1]))
+		case *Dict:
+			_, ok := collection.GetKey(args[1])
+			return NewBoolean(ok)
 		}
 
-		return &Nil{}
+		return NewError(TYPE_ERROR, "has() expects a SET or a DICT, got %s", args[0].Type())
 	},
 
-	"prepend": func(args ...Object) Object {
-		if len(args) != 2 {
-			return &Nil{}
+	"union": func(caller *Frame, args ...Object) Object {
+		a, b, err := setArgs("union", args)
+		if err != nil {
+			return err
 		}
 
-		if list, ok := args[0].(*List); ok {
-			return &List{Items: append([]Object{args[1]}, list.Items...)}
+		return a.Union(b)
+	},
+
+	"intersect": func(caller *Frame, args ...Object) Object {
+		a, b, err := setArgs("intersect", args)
+		if err != nil {
+			return err
 		}
 
-		return &Nil{}
+		return a.Intersect(b)
 	},
 
-	"pop": func(args ...Object) Object {
-		if len(args) != 1 {
-			return &Nil{}
+	"difference": func(caller *Frame, args ...Object) Object {
+		a, b, err := setArgs("difference", args)
+		if err != nil {
+			return err
 		}
 
-		if list, ok := args[0].(*List); ok {
-			length := len(list.Items)
-			if length > 0 {
-				popped := list.Items[length-1]
-				list.Items = list.Items[:length-1]
-				return popped
+		return a.Difference(b)
+	},
+
+	// extend(proto) or extend(proto, { ... }) creates a dict
+	// that falls back to proto for the keys it doesn't have
+	"extend": func(caller *Frame, args ...Object) Object {
+		if len(args) != 1 && len(args) != 2 {
+			return NewError(ARGUMENT_ERROR, "extend() expects 1 or 2 argument(s), got %d", len(args))
+		}
+
+		proto, ok := args[0].(*Dict)
+		if !ok {
+			return NewError(TYPE_ERROR, "extend() expects a DICT, got %s", args[0].Type())
+		}
+
+		child := &Dict{Pairs: make(map[string]Object), Proto: proto}
+		if len(args) == 2 {
+			fields, ok := args[1].(*Dict)
+			if !ok {
+				return NewError(TYPE_ERROR, "extend() expects a DICT of fields, got %s", args[1].Type())
 			}
+
+			fields.mu.RLock()
+			for key, val := range fields.Pairs {
+				child.Pairs[key] = val
+			}
+			for hash, pair := range fields.keyed {
+				if child.keyed == nil {
+					child.keyed = make(map[HashKey]keyedPair)
+				}
+				child.keyed[hash] = pair
+			}
+			fields.mu.RUnlock()
 		}
 
-		return &Nil{}
+		return child
 	},
 
-	"shift": func(args ...Object) Object {
-		if len(args) != 1 {
-			return &Nil{}
+	// is_a(obj, proto) tells whether obj extends proto, directly or not
+	"is_a": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("is_a", args, 2); err != nil {
+			return err
+		}
+
+		proto, ok := args[1].(*Dict)
+		if !ok {
+			return NewError(TYPE_ERROR, "is_a() expects a DICT prototype, got %s", args[1].Type())
+		}
+
+		obj, ok := args[0].(*Dict)
+		return NewBoolean(ok && obj.Inherits(proto))
+	},
+
+	// match(variant, { Tag: handler, ..., _: fallback }) calls the
+	// handler of the variant's tag with the values of its fields.
+	// The fallback gets the variant itself. Handlers that aren't
+	// functions are returned as they are
+	"match": func(caller *Frame, args ...Object) Object {
+		if err := checkArgCount("match", args, 2); err != nil {
+			return err
+		}
+
+		variant, ok := args[0].(*Variant)
+		if !ok {
+			return NewError(TYPE_ERROR, "match() expects a VARIANT, got %s", args[0].Type())
+		}
+
+		cases, ok := args[1].(*Dict)
+		if !ok {
+			return NewError(TYPE_ERROR, "match() expects a DICT of cases, got %s", args[1].Type())
+		}
+
+		// a typo in a case would otherwise go unnoticed
+		cases.mu.RLock()
+		tags := make([]string, 0, len(cases.Pairs))
+		for tag := range cases.Pairs {
+			tags = append(tags, tag)
+		}
+		cases.mu.RUnlock()
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			if _, ok := variant.Enum.Fields(tag); !ok && tag != "_" {
+				return variant.Enum.NoVariant(tag)
+			}
+		}
+
+		if handler, ok := cases.Get(variant.Tag); ok {
+			if !IsCallable(handler) {
+				return handler
+			}
+			return Apply(caller, handler, variant.Payload...)
 		}
 
-		if list, ok := args[0].(*List); ok {
-			length := len(list.Items)
-			if length > 0 {
-				shifted := list.Items[0]
-				list.Items = list.Items[1:]
-				return shifted
+		if fallback, ok := cases.Get("_"); ok {
+			if !IsCallable(fallback) {
+				return fallback
 			}
+			return Apply(caller, fallback, variant)
 		}
 
-		return &Nil{}
+		return NewError(RUNTIME_ERROR, "match has no case for %s.%s", variant.Enum.Name, variant.Tag)
 	},
 }
+
+// blockingFuncs are pure too, but they wait on other tasks,
+// for as long as it takes, unless ctx is done first
+func blockingFuncs(ctx context.Context) FuncTable {
+	return FuncTable{
+		// await(task) waits for a spawned task, and throws if the task did
+		"await": func(caller *Frame, args ...Object) Object {
+			if err := checkArgCount("await", args, 1); err != nil {
+				return err
+			}
+
+			task, ok := args[0].(*Task)
+			if !ok {
+				return NewError(TYPE_ERROR, "await() expects a TASK, got %s", args[0].Type())
+			}
+
+			return task.Await(ctx)
+		},
+
+		"send": func(caller *Frame, args ...Object) Object {
+			ch, err := channelArg("send", args, 2)
+			if err != nil {
+				return err
+			}
+
+			if err := ch.Send(ctx, args[1]); err != nil {
+				return err
+			}
+
+			return Null
+		},
+
+		// recv returns nil once the channel is closed and drained
+		"recv": func(caller *Frame, args ...Object) Object {
+			ch, err := channelArg("recv", args, 1)
+			if err != nil {
+				return err
+			}
+
+			val, ok, interrupted := ch.Recv(ctx)
+			if interrupted != nil {
+				return interrupted
+			}
+			if !ok {
+				return Null
+			}
+
+			return val
+		},
+
+		// select([ch1, ch2, ...]) waits on all the channels, and returns
+		// { index, value, ok } for the first one with something to receive
+		"select": func(caller *Frame, args ...Object) Object {
+			list, err := listArg("select", args, 1)
+			if err != nil {
+				return err
+			}
+
+			items := list.Elements()
+			if len(items) == 0 {
+				return NewError(ARGUMENT_ERROR, "select() needs at least one channel")
+			}
+
+			channels := make([]*Channel, len(items))
+			for i, item := range items {
+				ch, ok := item.(*Channel)
+				if !ok {
+					return NewError(TYPE_ERROR, "select() expects a LIST of CHANNELs, got %s", item.Type())
+				}
+				channels[i] = ch
+			}
+
+			index, val, ok, interrupted := Select(ctx, channels)
+			if interrupted != nil {
+				return interrupted
+			}
+			if !ok {
+				val = Null
+			}
+
+			return &Dict{Pairs: map[string]Object{
+				"index": NewInteger(int64(index)),
+				"value": val,
+				"ok":    NewBoolean(ok),
+			}}
+		},
+	}
+}
+
+// channels can't hold more than MaxChannelCapacity values, budget
+// or not, since their buffer is allocated all at once
+const MaxChannelCapacity = 1 << 20
+
+// sizedFuncs are pure too, but they make lists and the like as big
+// as they're asked to. They stop before going over maxAllocation
+// items, unless it's 0
+func sizedFuncs(maxAllocation int) FuncTable {
+	return FuncTable{
+		// channel() is unbuffered, channel(capacity) is buffered
+		"channel": func(caller *Frame, args ...Object) Object {
+			if len(args) > 1 {
+				return NewError(ARGUMENT_ERROR, "channel() expects 0 or 1 argument(s), got %d", len(args))
+			}
+
+			capacity := int64(0)
+			if len(args) == 1 {
+				integer, ok := args[0].(*Integer)
+				if !ok {
+					return NewError(TYPE_ERROR, "channel() expects an INT capacity, got %s", args[0].Type())
+				}
+				capacity = integer.Value
+			}
+
+			if capacity < 0 {
+				return NewError(ARGUMENT_ERROR, "channel() capacity can't be negative")
+			}
+			if capacity > MaxChannelCapacity {
+				return NewError(ARGUMENT_ERROR, "channel() capacity can't be more than %d, got %d", MaxChannelCapacity, capacity)
+			}
+			if err := CheckAllocation(CHANNEL, capacity, "items", maxAllocation); err != nil {
+				return err
+			}
+
+			return NewChannel(int(capacity))
+		},
+
+		"append": func(caller *Frame, args ...Object) Object {
+			list, err := listArg("append", args, 2)
+			if err != nil {
+				return err
+			}
+
+			if err := CheckAllocation(LIST, int64(list.Len())+1, "items", maxAllocation); err != nil {
+				return err
+			}
+
+			return list.Append(args[1])
+		},
+
+		"prepend": func(caller *Frame, args ...Object) Object {
+			list, err := listArg("prepend", args, 2)
+			if err != nil {
+				return err
+			}
+
+			if err := CheckAllocation(LIST, int64(list.Len())+1, "items", maxAllocation); err != nil {
+				return err
+			}
+
+			return list.Prepend(args[1])
+		},
+
+		"collect": func(caller *Frame, args ...Object) Object {
+			if err := checkArgCount("collect", args, 1); err != nil {
+				return err
+			}
+
+			items, err := collectArg(caller, "collect", LIST, args, maxAllocation)
+			if err != nil {
+				return err
+			}
+
+			return NewList(items...)
+		},
+
+		// set() is empty, set(iterable) has each value of iterable once
+		"set": func(caller *Frame, args ...Object) Object {
+			items, err := collectArg(caller, "set", SET, args, maxAllocation)
+			if err != nil {
+				return err
+			}
+
+			set, err := NewSet(items...)
+			if err != nil {
+				return err
+			}
+			return set
+		},
+
+		// tuple() is empty, tuple(iterable) has the values of iterable
+		"tuple": func(caller *Frame, args ...Object) Object {
+			items, err := collectArg(caller, "tuple", TUPLE, args, maxAllocation)
+			if err != nil {
+				return err
+			}
+
+			return &Tuple{Items: items}
+		},
+	}
+}
+
+func checkArgCount(name string, args []Object, expected int) *Error {
+	if len(args) != expected {
+		return NewError(ARGUMENT_ERROR, "%s() expects %d argument(s), got %d", name, expected, len(args))
+	}
+
+	return nil
+}
+
+// listArg checks the arg count and that the first arg is a list
+func listArg(name string, args []Object, expected int) (*List, *Error) {
+	if err := checkArgCount(name, args, expected); err != nil {
+		return nil, err
+	}
+
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, NewError(TYPE_ERROR, "%s() expects a LIST, got %s", name, args[0].Type())
+	}
+
+	return list, nil
+}
+
+func iterArg(name string, arg Object) (Iterator, *Error) {
+	it, ok := ToIterator(arg)
+	if !ok {
+		return nil, NewError(TYPE_ERROR, "%s() expects something iterable, got %s", name, arg.Type())
+	}
+
+	return it, nil
+}
+
+// for take(iterable, n) and drop(iterable, n)
+func iterAndCountArgs(name string, args []Object) (Iterator, int64, *Error) {
+	if err := checkArgCount(name, args, 2); err != nil {
+		return nil, 0, err
+	}
+
+	it, err := iterArg(name, args[0])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, ok := args[1].(*Integer)
+	if !ok {
+		return nil, 0, NewError(TYPE_ERROR, "%s() expects an INT count, got %s", name, args[1].Type())
+	}
+
+	return it, n.Value, nil
+}
+
+// for lazy_map(iterable, fn) and lazy_filter(iterable, fn)
+func iterAndFuncArgs(name string, args []Object) (Iterator, Object, *Error) {
+	if err := checkArgCount(name, args, 2); err != nil {
+		return nil, nil, err
+	}
+
+	it, err := iterArg(name, args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !IsCallable(args[1]) {
+		return nil, nil, NewError(TYPE_ERROR, "%s() expects a function, got %s", name, args[1].Type())
+	}
+
+	return it, args[1], nil
+}
+
+// for collect(), set() and tuple(), which take an optional iterable,
+// making what of its items, up to maxAllocation of them
+func collectArg(caller *Frame, name string, what ObjectType, args []Object, maxAllocation int) ([]Object, *Error) {
+	if len(args) > 1 {
+		return nil, NewError(ARGUMENT_ERROR, "%s() expects 0 or 1 argument(s), got %d", name, len(args))
+	}
+
+	items := []Object{}
+	if len(args) == 0 {
+		return items, nil
+	}
+
+	it, err := iterArg(name, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		item, ok := it.Next(caller)
+		if !ok {
+			return items, nil
+		}
+		if err, ok := item.(*Error); ok {
+			return nil, err
+		}
+		if err := CheckAllocation(what, int64(len(items))+1, "items", maxAllocation); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// for union(a, b), intersect(a, b) and difference(a, b)
+func setArgs(name string, args []Object) (*Set, *Set, *Error) {
+	if err := checkArgCount(name, args, 2); err != nil {
+		return nil, nil, err
+	}
+
+	for _, arg := range args {
+		if arg.Type() != SET {
+			return nil, nil, NewError(TYPE_ERROR, "%s() expects two SETs, got %s", name, arg.Type())
+		}
+	}
+
+	return args[0].(*Set), args[1].(*Set), nil
+}
+
+func channelArg(name string, args []Object, expected int) (*Channel, *Error) {
+	if err := checkArgCount(name, args, expected); err != nil {
+		return nil, err
+	}
+
+	ch, ok := args[0].(*Channel)
+	if !ok {
+		return nil, NewError(TYPE_ERROR, "%s() expects a CHANNEL, got %s", name, args[0].Type())
+	}
+
+	return ch, nil
+}
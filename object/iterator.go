@@ -0,0 +1,190 @@
+package object
+
+import (
+	"iter"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Iterator produces values one at a time, and only when asked to.
+// An *Error coming out of Next means the iteration failed. caller
+// is the frame of the pingul function asking for the value, which
+// the functions the iterator calls back are called on behalf of
+type Iterator interface {
+	Object
+	Next(caller *Frame) (Object, bool)
+}
+
+// Iterable is anything that can be walked with an Iterator
+type Iterable interface {
+	Iter() Iterator
+}
+
+// LazyIterator is an iterator that computes its next value on demand
+type LazyIterator struct {
+	next func(caller *Frame) (Object, bool)
+}
+
+// NewIterator creates an iterator out of next. Tasks can share an
+// iterator, so next has to guard whatever it keeps track of. It
+// shouldn't hold a lock while calling back into pingul though, whatever
+// it calls might ask the same iterator for its next value
+func NewIterator(next func(caller *Frame) (Object, bool)) *LazyIterator {
+	return &LazyIterator{next: next}
+}
+
+func (l *LazyIterator) Type() ObjectType                  { return ITERATOR }
+func (l *LazyIterator) Inspect() string                   { return string(l.Type()) }
+func (l *LazyIterator) IsTruthy() bool                    { return true }
+func (l *LazyIterator) Next(caller *Frame) (Object, bool) { return l.next(caller) }
+func (l *LazyIterator) Iter() Iterator                    { return l }
+
+// Generator runs the body of a function that yields, pausing
+// at every yield until the next value is asked for
+type Generator struct {
+	pull *pull
+
+	// the call the body runs as
+	frame *Frame
+
+	// what the body closes over, see NewGenerator
+	captured any
+}
+
+// pull is what iter.Pull hands out for the body of a generator.
+// Neither of them can be called by more than one task at a time
+type pull struct {
+	mu   sync.Mutex
+	next func() (Object, bool)
+	stop func()
+}
+
+// NewGenerator creates a generator out of a body that hands its values
+// over to yield. The body only starts running on the first Next.
+//
+// The body runs on a goroutine of its own, which keeps everything it
+// holds alive while it waits, and what it closes over often holds the
+// generator itself. So the body must only hold what it captured weakly,
+// see Scope.HoldOuterWeakly, and the generator keeps captured alive for
+// it. Once nobody can ask for more values, the generator and what it
+// captured get collected together, and the body is let go. frame is
+// the call the body runs as
+func NewGenerator(captured any, frame *Frame, body func(yield func(Object) bool) Object) *Generator {
+	next, stop := iter.Pull(func(yield func(Object) bool) {
+		result := body(yield)
+
+		// the failure is the last thing the generator produces
+		if result != nil && result.Type() == ERROR {
+			yield(result)
+		}
+	})
+
+	g := &Generator{pull: &pull{next: next, stop: stop}, frame: frame, captured: captured}
+	// nobody will ask for more values, let the paused body go
+	runtime.AddCleanup(g, func(p *pull) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.stop()
+	}, g.pull)
+
+	return g
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR }
+func (g *Generator) Inspect() string  { return string(g.Type()) }
+func (g *Generator) IsTruthy() bool   { return true }
+func (g *Generator) Iter() Iterator   { return g }
+
+// Next waits for whoever else is running the body. The body keeps the
+// frames it was called with, whoever asks for the next value, but if
+// that's the body itself, it would be waiting for itself, and fails
+func (g *Generator) Next(caller *Frame) (Object, bool) {
+	if !g.pull.mu.TryLock() {
+		for frame := caller; frame != nil; frame = frame.Caller {
+			if frame == g.frame {
+				return NewError(RUNTIME_ERROR, "generator already running"), true
+			}
+		}
+		g.pull.mu.Lock()
+	}
+	defer g.pull.mu.Unlock()
+
+	return g.pull.next()
+}
+
+func (l *List) Iter() Iterator {
+	return sliceIterator(l.Elements())
+}
+
+func (t *Tuple) Iter() Iterator {
+	return sliceIterator(t.Items)
+}
+
+func (s *Set) Iter() Iterator {
+	return sliceIterator(s.Items)
+}
+
+func sliceIterator(items []Object) Iterator {
+	var mu sync.Mutex
+	i := 0
+
+	return NewIterator(func(*Frame) (Object, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if i >= len(items) {
+			return nil, false
+		}
+
+		i++
+		return items[i-1], true
+	})
+}
+
+// strings are iterated one character at a time
+func (s *String) Iter() Iterator {
+	var mu sync.Mutex
+	i := 0
+
+	return NewIterator(func(*Frame) (Object, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if i >= len(s.Value) {
+			return nil, false
+		}
+
+		i++
+		return &String{Value: []rune{s.Value[i-1]}}, true
+	})
+}
+
+// dicts are iterated over their keys, in alphabetical order
+func (d *Dict) Iter() Iterator {
+	d.mu.RLock()
+	keys := make([]string, 0, len(d.Pairs))
+	for key := range d.Pairs {
+		keys = append(keys, key)
+	}
+	d.mu.RUnlock()
+
+	sort.Strings(keys)
+
+	items := make([]Object, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, &String{Value: []rune(key)})
+	}
+
+	return sliceIterator(append(items, d.otherKeys()...))
+}
+
+// ToIterator gets an iterator for obj, if it's iterable at all
+func ToIterator(obj Object) (Iterator, bool) {
+	iterable, ok := obj.(Iterable)
+	if !ok {
+		return nil, false
+	}
+
+	return iterable.Iter(), true
+}
@@ -0,0 +1,213 @@
+package object
+
+import (
+	"strings"
+	"sync"
+)
+
+// List is a persistent list: head, tail, append and prepend never
+// change the list they're given, they return a new one instead.
+// The new list shares its items with the old one, so none of them
+// needs to copy the whole list, most of the time.
+//
+// pop and shift are the only ones that change a list in place,
+// and they only change the list they're given, never the lists
+// built out of it, nor the ones it was built out of
+type List struct {
+	// lists can be shared between spawned tasks
+	mu sync.RWMutex
+
+	buf        *listBuffer
+	start, end int
+}
+
+// listBuffer holds the items of all the lists built from one another,
+// each list seeing a range of it. Every slot is written only once,
+// when it's taken by an append or a prepend, which is why lists
+// sharing a buffer can't see each other's changes
+type listBuffer struct {
+	mu    sync.Mutex
+	items []Object
+
+	// where index 0 is in items. Prepending moves it to the left
+	zero int
+
+	// slots lo to hi (excluded) are taken
+	lo, hi int
+}
+
+func NewList(items ...Object) *List {
+	buf := &listBuffer{items: make([]Object, len(items)), hi: len(items)}
+	copy(buf.items, items)
+
+	return &List{buf: buf, start: 0, end: len(items)}
+}
+
+// Elements returns the items the list currently has. The items
+// never change, so it's safe to hold on to the result
+func (l *List) Elements() []Object {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	l.buf.mu.Lock()
+	defer l.buf.mu.Unlock()
+
+	from, to := l.buf.zero+l.start, l.buf.zero+l.end
+
+	// capped, so appending to the result never writes into the buffer
+	return l.buf.items[from:to:to]
+}
+
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.end - l.start
+}
+
+// Tail is the list without its first item
+func (l *List) Tail() *List {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	start := l.start
+	if start < l.end {
+		start++
+	}
+
+	return &List{buf: l.buf, start: start, end: l.end}
+}
+
+// Append returns a new list with obj at the end
+func (l *List) Append(obj Object) *List {
+	l.mu.RLock()
+	start, end := l.start, l.end
+	l.mu.RUnlock()
+
+	buf := l.buf
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	// someone else has taken the next slot already
+	if end != buf.hi {
+		items := append(buf.slice(start, end), obj)
+		return NewList(items...)
+	}
+
+	if buf.zero+buf.hi == len(buf.items) {
+		buf.grow(0, len(buf.items)+1)
+	}
+
+	buf.items[buf.zero+buf.hi] = obj
+	buf.hi++
+
+	return &List{buf: buf, start: start, end: end + 1}
+}
+
+// Prepend returns a new list with obj at the beginning
+func (l *List) Prepend(obj Object) *List {
+	l.mu.RLock()
+	start, end := l.start, l.end
+	l.mu.RUnlock()
+
+	buf := l.buf
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	// someone else has taken the previous slot already
+	if start != buf.lo {
+		items := append([]Object{obj}, buf.slice(start, end)...)
+		return NewList(items...)
+	}
+
+	if buf.zero+buf.lo == 0 {
+		buf.grow(len(buf.items)+1, 0)
+	}
+
+	buf.lo--
+	buf.items[buf.zero+buf.lo] = obj
+
+	return &List{buf: buf, start: start - 1, end: end}
+}
+
+// Pop removes the last item from this list only
+func (l *List) Pop() (Object, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.start == l.end {
+		return nil, false
+	}
+
+	l.end--
+	return l.buf.at(l.end), true
+}
+
+// Shift removes the first item from this list only
+func (l *List) Shift() (Object, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.start == l.end {
+		return nil, false
+	}
+
+	l.start++
+	return l.buf.at(l.start - 1), true
+}
+
+// slice must be called with the lock held
+func (b *listBuffer) slice(start, end int) []Object {
+	items := make([]Object, end-start)
+	copy(items, b.items[b.zero+start:b.zero+end])
+
+	return items
+}
+
+func (b *listBuffer) at(i int) Object {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.items[b.zero+i]
+}
+
+// grow makes room for at least left more slots before the
+// taken ones and right more after them, doubling the buffer.
+// It must be called with the lock held
+func (b *listBuffer) grow(left, right int) {
+	taken := b.hi - b.lo
+	size := max(2*len(b.items), taken+left+right, 4)
+
+	// keep the free room where it's needed
+	before := b.zero + b.lo
+	if left > 0 {
+		before = size - taken - (len(b.items) - b.zero - b.hi)
+	}
+
+	items := make([]Object, size)
+	copy(items[before:], b.items[b.zero+b.lo:b.zero+b.hi])
+
+	b.zero = before - b.lo
+	b.items = items
+}
+
+func (l *List) Type() ObjectType { return LIST }
+func (l *List) Inspect() string  { return l.inspect(nil) }
+
+func (l *List) inspect(caller *Frame) string {
+	var b strings.Builder
+
+	items := l.Elements()
+
+	b.WriteString("[")
+	for i, e := range items {
+		b.WriteString(InspectFrom(caller, e))
+		if i < len(items)-1 {
+			b.WriteString(", ")
+		}
+	}
+	b.WriteString("]")
+
+	return b.String()
+}
+func (l *List) IsTruthy() bool { return l.Len() > 0 }
@@ -0,0 +1,27 @@
+package object
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// Module is an imported file. Only the names it exports
+// can be reached from the outside
+type Module struct {
+	Path    string
+	Scope   *Scope
+	Exports []string
+}
+
+func (m *Module) Type() ObjectType { return MODULE }
+func (m *Module) Inspect() string  { return string(m.Type()) + "(" + filepath.Base(m.Path) + ")" }
+func (m *Module) IsTruthy() bool   { return true }
+
+// Get returns the current value of an exported name
+func (m *Module) Get(name string) (Object, bool) {
+	if !slices.Contains(m.Exports, name) {
+		return nil, false
+	}
+
+	return m.Scope.Get(name)
+}
@@ -2,7 +2,9 @@ package object
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/aziflaj/pingul/ast"
 )
@@ -19,6 +21,18 @@ const (
 	RETURN         = ObjectType("RETURN")
 	FUNC           = ObjectType("FUNC")
 	INTRINSIC_FUNC = ObjectType("INTRINSIC_FUNC")
+	ERROR          = ObjectType("ERROR")
+	GENERATOR      = ObjectType("GENERATOR")
+	ITERATOR       = ObjectType("ITERATOR")
+	TASK           = ObjectType("TASK")
+	CHANNEL        = ObjectType("CHANNEL")
+	MODULE         = ObjectType("MODULE")
+	SUPER          = ObjectType("SUPER")
+	TUPLE          = ObjectType("TUPLE")
+	SET            = ObjectType("SET")
+	ENUM           = ObjectType("ENUM")
+	VARIANT        = ObjectType("VARIANT")
+	TAIL_CALL      = ObjectType("TAIL_CALL")
 )
 
 type Object interface {
@@ -28,6 +42,24 @@ type Object interface {
 	IsTruthy() bool
 }
 
+// values that hold other values, which may be dicts with a __str__ hook
+type inspector interface {
+	inspect(caller *Frame) string
+}
+
+// InspectFrom is obj.Inspect(), except that the __str__ hooks it runs
+// into are called on behalf of caller, the frame of the pingul function
+// asking for it. Go code running on behalf of pingul code uses this
+func InspectFrom(caller *Frame, obj Object) string {
+	if obj, ok := obj.(inspector); ok {
+		return obj.inspect(caller)
+	}
+
+	return obj.Inspect()
+}
+
+// Integers, Booleans and Nils never change once they're made,
+// so the common ones are shared instead of allocated over and over
 type Integer struct {
 	Value int64
 }
@@ -36,6 +68,30 @@ func (i *Integer) Type() ObjectType { return INT }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%s(%d)", i.Type(), i.Value) }
 func (i *Integer) IsTruthy() bool   { return i.Value != 0 }
 
+// the integers NewInteger shares, which covers
+// most counters, indexes and lengths
+const (
+	minSmallInt = -128
+	maxSmallInt = 1024
+)
+
+var smallInts = func() (ints [maxSmallInt - minSmallInt + 1]Integer) {
+	for i := range ints {
+		ints[i].Value = int64(i + minSmallInt)
+	}
+	return ints
+}()
+
+// NewInteger returns an integer with the value,
+// the shared one if the value is small
+func NewInteger(value int64) *Integer {
+	if value >= minSmallInt && value <= maxSmallInt {
+		return &smallInts[value-minSmallInt]
+	}
+
+	return &Integer{Value: value}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -44,6 +100,19 @@ func (b *Boolean) Type() ObjectType { return BOOL }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%s(%t)", b.Type(), b.Value) }
 func (b *Boolean) IsTruthy() bool   { return b.Value }
 
+var (
+	True  = &Boolean{Value: true}
+	False = &Boolean{Value: false}
+)
+
+// NewBoolean returns True or False
+func NewBoolean(value bool) *Boolean {
+	if value {
+		return True
+	}
+	return False
+}
+
 type String struct {
 	Value []rune
 }
@@ -52,35 +121,167 @@ func (s *String) Type() ObjectType { return STRING }
 func (s *String) Inspect() string  { return fmt.Sprintf("%s(%s)", s.Type(), string(s.Value)) }
 func (s *String) IsTruthy() bool   { return string(s.Value) != "" }
 
-type List struct {
-	Items []Object
+type Dict struct {
+	Pairs map[string]Object
+
+	// keys other than strings, e.g. { [(1, 2)]: value }
+	keyed map[HashKey]keyedPair
+
+	// where missing keys are looked up, see extend()
+	Proto *Dict
+
+	mu sync.RWMutex
 }
 
-func (l *List) Type() ObjectType { return LIST }
-func (l *List) Inspect() string {
-	var b strings.Builder
+// Get only looks at the dict's own keys
+func (d *Dict) Get(key string) (Object, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	b.WriteString("[")
-	for i, e := range l.Items {
-		b.WriteString(e.Inspect())
-		if i < len(l.Items)-1 {
-			b.WriteString(", ")
+	val, ok := d.Pairs[key]
+	return val, ok
+}
+
+type keyedPair struct {
+	Key   Object
+	Value Object
+}
+
+// GetKey looks up a key of any hashable type,
+// where strings are the keys found in Pairs
+func (d *Dict) GetKey(key Object) (Object, bool) {
+	if str, ok := key.(*String); ok {
+		return d.Get(string(str.Value))
+	}
+
+	hash, ok := Hash(key)
+	if !ok {
+		return nil, false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	pair, ok := d.keyed[hash]
+	return pair.Value, ok
+}
+
+// SetKey is the counterpart of GetKey
+func (d *Dict) SetKey(key Object, val Object) *Error {
+	if str, ok := key.(*String); ok {
+		d.Set(string(str.Value), val)
+		return nil
+	}
+
+	hash, ok := Hash(key)
+	if !ok {
+		return NewError(TYPE_ERROR, "unhashable type: %s", key.Type())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.keyed == nil {
+		d.keyed = make(map[HashKey]keyedPair)
+	}
+	d.keyed[hash] = keyedPair{Key: key, Value: val}
+
+	return nil
+}
+
+// otherKeys are the keys that aren't strings, sorted
+func (d *Dict) otherKeys() []Object {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	hashes := make([]HashKey, 0, len(d.keyed))
+	for hash := range d.keyed {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].less(hashes[j]) })
+
+	keys := make([]Object, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = d.keyed[hash].Key
+	}
+
+	return keys
+}
+
+// Lookup walks up the prototype chain until it finds the key,
+// returning the value and the dict that holds it
+func (d *Dict) Lookup(key string) (Object, *Dict, bool) {
+	for dict := d; dict != nil; dict = dict.Proto {
+		if val, ok := dict.Get(key); ok {
+			return val, dict, true
 		}
 	}
-	b.WriteString("]")
 
-	return b.String()
+	return nil, nil, false
 }
-func (l *List) IsTruthy() bool { return len(l.Items) > 0 }
 
-type Dict struct {
-	Pairs map[string]Object
+// Method looks the key up the prototype chain on behalf of self.
+// Functions that aren't bound yet come back bound to self, remembering
+// the dict they were found in, which is where super starts looking
+func (d *Dict) Method(self Object, key string) (Object, bool) {
+	val, home, ok := d.Lookup(key)
+	if !ok {
+		return nil, false
+	}
+
+	if fun, ok := val.(*Func); ok && fun.Self == nil {
+		bound := fun.Bind(self)
+		bound.Home = home
+		return bound, true
+	}
+
+	return val, true
+}
+
+// Inherits tells whether proto is somewhere up the prototype chain
+func (d *Dict) Inherits(proto *Dict) bool {
+	for dict := d.Proto; dict != nil; dict = dict.Proto {
+		if dict == proto {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Dict) Set(key string, val Object) Object {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Pairs[key] = val
+	return val
+}
+
+func (d *Dict) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return len(d.Pairs) + len(d.keyed)
 }
 
 func (d *Dict) Type() ObjectType { return DICT }
-func (d *Dict) Inspect() string {
+func (d *Dict) Inspect() string  { return d.inspect(nil) }
+
+func (d *Dict) inspect(caller *Frame) string {
+	// a __str__ hook decides how the dict looks like
+	if hook, ok := d.Method(d, "__str__"); ok {
+		str := Apply(caller, hook)
+		if s, ok := str.(*String); ok {
+			return string(s.Value)
+		}
+		return InspectFrom(caller, str)
+	}
+
 	var b strings.Builder
 
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	b.WriteString("{")
 	first := true
 	for key, value := range d.Pairs {
@@ -90,13 +291,34 @@ func (d *Dict) Inspect() string {
 		first = false
 		b.WriteString(key)
 		b.WriteString(": ")
-		b.WriteString(value.Inspect())
+		b.WriteString(InspectFrom(caller, value))
+	}
+	for _, pair := range d.keyed {
+		if !first {
+			b.WriteString(", ")
+		}
+		first = false
+		b.WriteString("[")
+		b.WriteString(InspectFrom(caller, pair.Key))
+		b.WriteString("]: ")
+		b.WriteString(InspectFrom(caller, pair.Value))
 	}
 	b.WriteString("}")
 
 	return b.String()
 }
-func (d *Dict) IsTruthy() bool { return len(d.Pairs) > 0 }
+func (d *Dict) IsTruthy() bool { return d.Len() > 0 }
+
+// Super is what methods see as super: the receiver,
+// looking keys up from the prototype of the method's dict
+type Super struct {
+	Self  Object
+	Proto *Dict
+}
+
+func (s *Super) Type() ObjectType { return SUPER }
+func (s *Super) Inspect() string  { return string(s.Type()) }
+func (s *Super) IsTruthy() bool   { return s.Proto != nil }
 
 type Nil struct{}
 
@@ -104,6 +326,9 @@ func (n *Nil) Type() ObjectType { return NIL }
 func (n *Nil) Inspect() string  { return string(n.Type()) }
 func (n *Nil) IsTruthy() bool   { return false }
 
+// Null is the one nil there needs to be
+var Null = &Nil{}
+
 type Return struct {
 	Value Object
 }
@@ -112,9 +337,142 @@ func (r *Return) Type() ObjectType { return RETURN }
 func (r *Return) Inspect() string  { return r.Value.Inspect() }
 func (r *Return) IsTruthy() bool   { return r.Value.IsTruthy() }
 
+// TailCall is a call a function ends with. Rather than making it,
+// the function hands it back to its caller, who makes it in its
+// place, so that tail recursion doesn't grow the stack
+type TailCall struct {
+	Fun  *Func
+	Args []Object
+
+	// where the call is made, to tell in a traceback
+	Site Position
+}
+
+func (t *TailCall) Type() ObjectType { return TAIL_CALL }
+func (t *TailCall) Inspect() string  { return string(t.Type()) }
+func (t *TailCall) IsTruthy() bool   { return true }
+
+// Kinds of runtime errors raised by the evaluator and the intrinsics
+const (
+	RUNTIME_ERROR    = "RuntimeError"
+	NAME_ERROR       = "NameError"
+	TYPE_ERROR       = "TypeError"
+	INDEX_ERROR      = "IndexError"
+	ARGUMENT_ERROR   = "ArgumentError"
+	ZERO_DIVISION    = "ZeroDivisionError"
+	IMPORT_ERROR     = "ImportError"
+	STACK_OVERFLOW   = "StackOverflow"
+	PERMISSION_ERROR = "PermissionError"
+	THROWN_EXCEPTION = "Exception"
+
+	// a script that goes over its budget is stopped, no matter what
+	STEP_LIMIT       = "StepLimitExceeded"
+	ALLOCATION_LIMIT = "AllocationLimitExceeded"
+	TIME_LIMIT       = "TimeLimitExceeded"
+	CANCELLED        = "Cancelled"
+)
+
+// Error is an exception in flight. Just like Return, it bubbles up
+// through blocks and function calls, until it's either caught
+// by a try/catch or it reaches the top
+type Error struct {
+	Kind    string
+	Message string
+
+	// the value given to `throw`, nil for runtime errors
+	Value Object
+
+	// the innermost call the error went through on its way up,
+	// nil if it happened outside of any function
+	Stack *Frame
+}
+
+func (e *Error) Type() ObjectType { return ERROR }
+func (e *Error) Inspect() string  { return fmt.Sprintf("%s(%s: %s)", e.Type(), e.Kind, e.Message) }
+func (e *Error) IsTruthy() bool   { return false }
+
+// Catchable tells whether a catch block gets to handle the error
+func (e *Error) Catchable() bool {
+	switch e.Kind {
+	case STEP_LIMIT, ALLOCATION_LIMIT, TIME_LIMIT, CANCELLED:
+		return false
+	}
+
+	return true
+}
+
+func NewError(kind string, format string, args ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// CheckAllocation fails if making a what of size units goes over
+// the budget of maxAllocation, unless the budget is 0
+func CheckAllocation(what ObjectType, size int64, units string, maxAllocation int) *Error {
+	if maxAllocation > 0 && size > int64(maxAllocation) {
+		return NewError(ALLOCATION_LIMIT, "script tried to make a %s of %d %s, over its budget of %d",
+			what, size, units, maxAllocation)
+	}
+
+	return nil
+}
+
+// Throw wraps a value thrown by the user, from within the
+// function of the caller frame
+func Throw(caller *Frame, value Object) *Error {
+	msg := InspectFrom(caller, value)
+	if str, ok := value.(*String); ok {
+		msg = string(str.Value)
+	}
+
+	return &Error{Kind: THROWN_EXCEPTION, Message: msg, Value: value}
+}
+
+// Caught is what a catch block gets to see: the thrown value itself,
+// or a { type, message } dict describing a runtime error
+func (e *Error) Caught() Object {
+	if e.Value != nil {
+		return e.Value
+	}
+
+	return &Dict{Pairs: map[string]Object{
+		"type":    &String{Value: []rune(e.Kind)},
+		"message": &String{Value: []rune(e.Message)},
+	}}
+}
+
 type Func struct {
 	Params []*ast.Identifier
 	Body   *ast.BlockStatement
+
+	// the scope the function was created in, which is
+	// what the function body sees besides its own params
+	Scope *Scope
+
+	// the interpreter that created the function
+	Caller Caller
+
+	// what functions made by the bytecode VM have instead of a Scope:
+	// their compiled body and the variables they close over
+	Closure any
+
+	// the name it was declared with, "" if it's anonymous
+	Name string
+
+	// what the function sees as self, nil if it's not bound
+	Self Object
+
+	// the dict a method was found in, where super starts looking
+	Home *Dict
+
+	IsGenerator bool
+}
+
+// Bind returns a copy of the function with self set to obj
+func (f *Func) Bind(obj Object) *Func {
+	bound := *f
+	bound.Self = obj
+
+	return &bound
 }
 
 func (f *Func) Type() ObjectType { return FUNC }
@@ -136,7 +494,10 @@ func (f *Func) Inspect() string {
 	return b.String()
 }
 
-type IntrinsicFunc func(args ...Object) Object
+// IntrinsicFunc is a function written in Go. caller is the frame of
+// the pingul function calling it, nil if it's called from the top
+// level. The functions it calls back are called on behalf of caller
+type IntrinsicFunc func(caller *Frame, args ...Object) Object
 
 func (i IntrinsicFunc) Type() ObjectType { return INTRINSIC_FUNC }
 func (i IntrinsicFunc) IsTruthy() bool   { return true }
@@ -1,11 +1,38 @@
 package object
 
+import (
+	"sync"
+	"weak"
+)
+
 type Scope struct {
+	// spawned tasks share the scopes they close over
+	mu    sync.RWMutex
 	table map[string]Object
 
+	// the variables the resolver found in the scope, see NewBlockScope
+	names []string
+	slots []Object
+
 	// All scopes are local, except the global scope
 	// which is the outermost scope
 	outter *Scope
+
+	// takes the place of outter in the scope of a generator body,
+	// see HoldOuterWeakly
+	weakOutter weak.Pointer[Scope]
+
+	// set on the scope of a running generator
+	yield func(Object) bool
+
+	// set on the outermost scope of a module
+	file string
+
+	// set on the outermost scope of a file that says "use strict"
+	strict bool
+
+	// set on the scope of a function call
+	frame *Frame
 }
 
 func NewScope() *Scope {
@@ -22,22 +49,222 @@ func NewLocalScope(outter *Scope) *Scope {
 	return s
 }
 
-func (s *Scope) Get(name string) Object {
+// NewBlockScope is a local scope whose variables are kept in slots,
+// one for each of the names, in the order the resolver declared them
+// in. Anything else set in the scope goes into the table, which is
+// only made when it's needed
+func NewBlockScope(outter *Scope, names []string) *Scope {
+	return &Scope{
+		names:  names,
+		slots:  make([]Object, len(names)),
+		outter: outter,
+	}
+}
+
+// HoldOuterWeakly stops the scope from keeping its outer scope alive,
+// which then lives only as long as something else holds onto it. The
+// body of a generator waits for the next value on a goroutine of its
+// own, and whatever that goroutine holds can never be collected
+func (s *Scope) HoldOuterWeakly() {
+	if s.outter != nil {
+		s.weakOutter = weak.Make(s.outter)
+		s.outter = nil
+	}
+}
+
+func (s *Scope) outer() *Scope {
+	if s.outter != nil {
+		return s.outter
+	}
+
+	return s.weakOutter.Value()
+}
+
+// NewModuleScope is the outermost scope of the module
+// read from file. Imports are resolved relative to it
+func NewModuleScope(file string) *Scope {
+	s := NewScope()
+	s.file = file
+
+	return s
+}
+
+// File is the file of the module the scope belongs to,
+// or "" if the code didn't come from a file
+func (s *Scope) File() string {
+	for scope := s; scope != nil; scope = scope.outer() {
+		if scope.file != "" {
+			return scope.file
+		}
+	}
+
+	return ""
+}
+
+// SetStrict marks the code evaluated in this scope, and in
+// every scope nested inside of it, as strict
+func (s *Scope) SetStrict() {
+	s.strict = true
+}
+
+// Strict tells whether the scope is inside strict code
+func (s *Scope) Strict() bool {
+	for scope := s; scope != nil; scope = scope.outer() {
+		if scope.strict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetFrame marks the scope as the one of a function call
+func (s *Scope) SetFrame(frame *Frame) {
+	s.frame = frame
+}
+
+// Frame is the innermost function call the scope is part of,
+// or nil for code that's not inside any function
+func (s *Scope) Frame() *Frame {
+	for scope := s; scope != nil; scope = scope.outer() {
+		if scope.frame != nil {
+			return scope.frame
+		}
+	}
+
+	return nil
+}
+
+// Up is the scope depth scopes out from this one,
+// or nil if there aren't that many
+func (s *Scope) Up(depth int) *Scope {
+	scope := s
+	for ; depth > 0 && scope != nil; depth-- {
+		scope = scope.outer()
+	}
+
+	return scope
+}
+
+// Slot is the value of the variable in the slot,
+// or nil if it hasn't been declared yet
+func (s *Scope) Slot(slot int) Object {
+	if s == nil || slot >= len(s.slots) {
+		return nil
+	}
+
+	s.mu.RLock()
+	obj := s.slots[slot]
+	s.mu.RUnlock()
+
+	return obj
+}
+
+// SetSlot declares the variable in the slot
+func (s *Scope) SetSlot(slot int, obj Object) Object {
+	s.mu.Lock()
+	s.slots[slot] = obj
+	s.mu.Unlock()
+
+	return obj
+}
+
+// Get looks the name up, walking from the local scope outwards.
+// The second return value is false if the name is nowhere to be found
+func (s *Scope) Get(name string) (Object, bool) {
+	s.mu.RLock()
 	obj, ok := s.table[name]
+	if slot := s.slot(name); slot != -1 {
+		obj = s.slots[slot]
+		ok = obj != nil
+	}
+	s.mu.RUnlock()
 
 	if !ok {
-		if s.outter != nil {
-			return s.outter.Get(name)
+		if outer := s.outer(); outer != nil {
+			return outer.Get(name)
 		}
 
-		return s.Set(name, &Nil{})
+		return nil, false
 	}
 
-	return obj
+	return obj, true
 }
 
 // Always set on the local scope
 func (s *Scope) Set(name string, obj Object) Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slot := s.slot(name); slot != -1 {
+		s.slots[slot] = obj
+		return obj
+	}
+
+	if s.table == nil {
+		s.table = make(map[string]Object)
+	}
 	s.table[name] = obj
+
 	return obj
 }
+
+// slot is where the name is kept among the slots, or -1
+func (s *Scope) slot(name string) int {
+	for i, n := range s.names {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Names returns every name visible from this scope, inner scopes first
+func (s *Scope) Names() []string {
+	var names []string
+
+	for scope := s; scope != nil; scope = scope.outer() {
+		scope.mu.RLock()
+		for name := range scope.table {
+			names = append(names, name)
+		}
+		for i, name := range scope.names {
+			if scope.slots[i] != nil {
+				names = append(names, name)
+			}
+		}
+		scope.mu.RUnlock()
+	}
+
+	return names
+}
+
+// SetYield marks the scope as the body of a running generator
+func (s *Scope) SetYield(yield func(Object) bool) {
+	s.yield = yield
+}
+
+// Yield hands a value over to whoever is consuming the closest
+// enclosing generator. It returns false if the consumer
+// is done with the generator, or if there's no generator at all
+func (s *Scope) Yield(obj Object) bool {
+	for scope := s; scope != nil; scope = scope.outer() {
+		if scope.yield != nil {
+			return scope.yield(obj)
+		}
+	}
+
+	return false
+}
+
+// InGenerator tells whether the scope is inside a generator body
+func (s *Scope) InGenerator() bool {
+	for scope := s; scope != nil; scope = scope.outer() {
+		if scope.yield != nil {
+			return true
+		}
+	}
+
+	return false
+}
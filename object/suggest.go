@@ -0,0 +1,64 @@
+package object
+
+// Suggest returns the candidate closest to name, or "" if none of them
+// is close enough to be what the user meant. Used for "did you mean" hints.
+func Suggest(name string, candidates []string) string {
+	target := []rune(name)
+
+	// anything further than a third of the name is probably not a typo,
+	// and a name can't be a typo of something it shares no letters with
+	maxDistance := max(len(target)/3, 1)
+	maxDistance = min(maxDistance, len(target)-1)
+
+	best := ""
+	bestDistance := maxDistance + 1
+
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+
+		distance := editDistance(target, []rune(candidate))
+		if distance > maxDistance {
+			continue
+		}
+
+		// candidates come out of maps, so break ties alphabetically
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// editDistance is the Levenshtein distance, except that swapping two
+// adjacent characters (the most common typo) counts as a single edit
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,313 @@
+package optimize
+
+import (
+	"strconv"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
+)
+
+// Optimizer rewrites a program into one that does the same with less
+// work: constant expressions are computed once and for all, branches
+// that can never run are dropped, and so is whatever follows a return.
+// Anything that would fail is left for the program to fail at
+type Optimizer struct {
+	// Strict has to match the interpreter that runs the program,
+	// since strict code doesn't coerce ints and bools. Files
+	// that say "use strict" are strict regardless
+	Strict bool
+
+	// whether the program being optimized is strict
+	strict bool
+}
+
+func New() *Optimizer {
+	return &Optimizer{}
+}
+
+// strings and lists longer than this are not worth
+// keeping around as constants, e.g. "ab" * 1000000
+const maxRepetition = 256
+
+// Optimize rewrites the program in place and returns it. Resolve
+// the program once it's optimized, since expressions can end up
+// out of the blocks they were in
+func (o *Optimizer) Optimize(program *ast.Program) *ast.Program {
+	o.strict = o.Strict || eval.IsStrict(program)
+
+	program.Statements = o.statements(program.Statements)
+	return program
+}
+
+// statements optimizes the statements one by one,
+// leaving out whatever follows a return
+func (o *Optimizer) statements(statements []ast.Statement) []ast.Statement {
+	for i, stmt := range statements {
+		statements[i] = o.statement(stmt)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			return statements[:i+1]
+		}
+	}
+
+	return statements
+}
+
+func (o *Optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+
+		// an if whose branch is known, but takes more than an
+		// expression, turns into the block of the branch
+		if node, ok := stmt.Expression.(*ast.IfExpression); ok && node.Alternative == nil {
+			if cond, ok := o.condition(node.Condition); ok && cond {
+				return node.Consequence
+			}
+		}
+
+	case *ast.VarStatement:
+		stmt.Value = o.expression(stmt.Value)
+
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = o.expression(stmt.ReturnValue)
+
+	case *ast.BlockStatement:
+		o.block(stmt)
+	}
+
+	return stmt
+}
+
+func (o *Optimizer) block(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = o.statements(block.Statements)
+	}
+}
+
+func (o *Optimizer) expression(expr ast.Expression) ast.Expression {
+	switch node := expr.(type) {
+	case *ast.PrefixExpression:
+		node.Right = o.expression(node.Right)
+
+		if right, ok := constant(node.Right); ok {
+			if folded, ok := literal(eval.Prefix(node.Operator, right), node.Token); ok {
+				return folded
+			}
+		}
+
+	case *ast.InfixExpression:
+		node.Left = o.expression(node.Left)
+		node.Right = o.expression(node.Right)
+
+		if node.Operator == "and" || node.Operator == "or" {
+			return o.logical(node)
+		}
+
+		left, ok := constant(node.Left)
+		if !ok {
+			return node
+		}
+		right, ok := constant(node.Right)
+		if !ok || tooLong(node.Operator, left, right) {
+			return node
+		}
+
+		if folded, ok := literal(eval.Infix(nil, node.Operator, left, right, o.strict), node.Token); ok {
+			return folded
+		}
+
+	case *ast.IfExpression:
+		return o.ifExpression(node)
+
+	case *ast.List:
+		o.expressions(node.Items)
+
+	case *ast.Tuple:
+		o.expressions(node.Items)
+
+	case *ast.Set:
+		o.expressions(node.Items)
+
+	case *ast.ObjectLiteral:
+		for key, value := range node.Pairs {
+			node.Pairs[key] = o.expression(value)
+		}
+		for _, pair := range node.ComputedPairs {
+			pair.Key = o.expression(pair.Key)
+			pair.Value = o.expression(pair.Value)
+		}
+
+	case *ast.PropertyAccess:
+		node.Object = o.expression(node.Object)
+
+	case *ast.IndexExpression:
+		node.List = o.expression(node.List)
+		node.Index = o.expression(node.Index)
+
+	case *ast.FuncExpression:
+		o.block(node.Body)
+
+	case *ast.CallExpression:
+		o.call(node)
+
+	case *ast.SpawnExpression:
+		o.call(node.Call)
+
+	case *ast.YieldExpression:
+		node.Value = o.expression(node.Value)
+
+	case *ast.ThrowExpression:
+		node.Value = o.expression(node.Value)
+
+	case *ast.TryExpression:
+		o.block(node.Block)
+		o.block(node.Catch)
+		o.block(node.Finally)
+	}
+
+	return expr
+}
+
+func (o *Optimizer) expressions(expressions []ast.Expression) {
+	for i, expr := range expressions {
+		expressions[i] = o.expression(expr)
+	}
+}
+
+func (o *Optimizer) call(node *ast.CallExpression) {
+	o.expressions(node.Arguments)
+	node.Function = o.expression(node.Function)
+}
+
+// and & or are BOOLs, and their right operand only
+// matters if the left one doesn't decide on its own
+func (o *Optimizer) logical(node *ast.InfixExpression) ast.Expression {
+	left, ok := constant(node.Left)
+	if !ok {
+		return node
+	}
+
+	if node.Operator == "and" && !left.IsTruthy() {
+		return boolean(false, node.Token)
+	}
+	if node.Operator == "or" && left.IsTruthy() {
+		return boolean(true, node.Token)
+	}
+
+	if right, ok := constant(node.Right); ok {
+		return boolean(right.IsTruthy(), node.Token)
+	}
+
+	return node
+}
+
+// ifExpression drops the branch that can't run. The one that's left
+// becomes the whole expression if it's a single expression, or stays
+// in an if that's always true, since its declarations need a block
+func (o *Optimizer) ifExpression(node *ast.IfExpression) ast.Expression {
+	node.Condition = o.expression(node.Condition)
+	o.block(node.Consequence)
+	o.block(node.Alternative)
+
+	cond, ok := o.condition(node.Condition)
+	if !ok {
+		return node
+	}
+
+	branch := node.Consequence
+	if !cond {
+		branch = node.Alternative
+	}
+
+	if branch == nil || len(branch.Statements) == 0 {
+		return &ast.Nil{Token: token.Token{Type: token.NIL, Literal: []rune("nil"), Line: node.Token.Line, Column: node.Token.Column}}
+	}
+
+	if len(branch.Statements) == 1 {
+		if stmt, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return stmt.Expression
+		}
+	}
+
+	return &ast.IfExpression{Token: node.Token, Condition: boolean(true, node.Token), Consequence: branch}
+}
+
+// condition tells which way an if goes, if it's known. In strict
+// code, a condition that's not a BOOL is left to fail at runtime
+func (o *Optimizer) condition(expr ast.Expression) (bool, bool) {
+	cond, ok := constant(expr)
+	if !ok || (o.strict && cond.Type() != object.BOOL) {
+		return false, false
+	}
+
+	return cond.IsTruthy(), true
+}
+
+// constant returns the value of a literal
+func constant(expr ast.Expression) (object.Object, bool) {
+	switch node := expr.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(node.Value), true
+	case *ast.String:
+		return &object.String{Value: node.Value}, true
+	case *ast.Boolean:
+		return object.NewBoolean(node.Value), true
+	case *ast.Nil:
+		return object.Null, true
+	}
+
+	return nil, false
+}
+
+// literal turns a value back into a literal, placed where the
+// expression it replaces was. Errors and values that have no
+// literal, like lists, are not turned into anything
+func literal(obj object.Object, at token.Token) (ast.Expression, bool) {
+	tok := token.Token{Line: at.Line, Column: at.Column}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, []rune(strconv.FormatInt(obj.Value, 10))
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, true
+	case *object.String:
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.String{Token: tok, Value: obj.Value}, true
+	case *object.Boolean:
+		return boolean(obj.Value, at), true
+	}
+
+	return nil, false
+}
+
+func boolean(value bool, at token.Token) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: []rune("false"), Line: at.Line, Column: at.Column}
+	if value {
+		tok.Type, tok.Literal = token.TRUE, []rune("true")
+	}
+
+	return &ast.Boolean{Token: tok, Value: value}
+}
+
+// tooLong tells whether the operator would repeat a string
+// into something too long to be worth keeping as a constant
+func tooLong(operator string, left object.Object, right object.Object) bool {
+	if operator != "*" {
+		return false
+	}
+
+	str, times := left, right
+	if left.Type() == object.INT {
+		str, times = right, left
+	}
+
+	s, ok := str.(*object.String)
+	n, isInt := times.(*object.Integer)
+	if !ok || !isInt {
+		return false
+	}
+
+	return n.Value > 0 && int64(len(s.Value)) > maxRepetition/n.Value
+}
@@ -0,0 +1,158 @@
+package optimize_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/optimize"
+	"github.com/aziflaj/pingul/parser"
+)
+
+func TestFolding(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24;", "86400"},
+		{"var x = 2 * (3 + 4);", "var x = 14;"},
+		{"-(2 - 5);", "3"},
+		{`"foo" + "bar";`, "foobar"},
+		{`"ab" * 3;`, "ababab"},
+		{`"a" < "b";`, "true"},
+		{`"b" in "abc";`, "true"},
+		{"not nil;", "true"},
+		{"1 == 1 and 2 > 3;", "false"},
+		{"nil is nil;", "true"},
+		// ints and bools mix, unless the code is strict
+		{"5 + true;", "6"},
+		{"true * 3;", "3"},
+		{`"use strict"; 5 + true;`, "use strict\n(5 + true)"},
+		// errors are left for the program to fail with
+		{"1 / 0;", "(1 / 0)"},
+		{`"a" - 1;`, "(a - 1)"},
+		{"-true;", "(-(true))"},
+		{`"ab" * 1000;`, "(ab * 1000)"},
+		// and & or don't always need the right operand
+		{"false and f();", "false"},
+		{"1 or f();", "true"},
+		{"true and f();", "(true and f())"},
+		{"x + 1 * 2;", "(x + 2)"},
+		{"[1 + 1, 2 * 2];", "[2, 4]"},
+		{"var f = func(n) { n * (60 * 60) };", "var f = func(n) {{(n * 3600)}};"},
+	}
+
+	for _, tc := range testCases {
+		assertOptimized(t, tc.input, tc.expected)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 1 } else { 2 };", "1"},
+		{"if (1 > 2) { 1 } else { 2 };", "2"},
+		{"if (false) { 1 };", "nil"},
+		{"if (nil) { 1 } else { };", "nil"},
+		{"var x = if (0) { 1 } else { var y = 2; y };", "var x = if true {var y = 2;y};"},
+		// a statement can be the block itself
+		{"if (true) { var y = 2; y };", "{var y = 2;y}"},
+		{"if (x) { 1 } else { 2 * 2 };", "if x {1} else {4}"},
+		// strict code fails on conditions that aren't BOOLs
+		{`"use strict"; if (1) { 2 };`, "use strict\nif 1 {2}"},
+		{`"use strict"; if (true) { 2 };`, "use strict\n2"},
+	}
+
+	for _, tc := range testCases {
+		assertOptimized(t, tc.input, tc.expected)
+	}
+}
+
+func TestDeadCode(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var f = func() { return 1; print(2); };", "var f = func() {{return 1;}};"},
+		{"var f = func(x) { if (x) { return 1; x; } else { 2 } };", "var f = func(x) {{if x {return 1;} else {2}}};"},
+		{"return 1; 2;", "return 1;"},
+	}
+
+	for _, tc := range testCases {
+		assertOptimized(t, tc.input, tc.expected)
+	}
+}
+
+func TestStrictOptimizer(t *testing.T) {
+	o := optimize.New()
+	o.Strict = true
+
+	program := o.Optimize(parse(t, "5 + true; if (1) { 2 };"))
+	if got := dump(program); got != "(5 + true)\nif 1 {2}" {
+		t.Fatalf("Wrong program. Got=%q", got)
+	}
+}
+
+// the optimized program has to mean the same as the original one
+func TestSameResults(t *testing.T) {
+	inputs := []string{
+		"var fib = func(n) { if (n <= 1) { return n; } fib(n - 1) + fib(n - 2) }; fib(10 + 5);",
+		"var f = func() { return 60 * 60; 1 / 0 }; f();",
+		"var x = if (false) { 1 } else { var y = 2 * 3; y + 1 }; x;",
+		"if (true) { var y = 2; y * 2 };",
+		"var y = 1; if (true) { var y = 2 }; y;",
+		"1 / 0;",
+		`try { "a" - 1 } catch (e) { e.type + ": " + e.message };`,
+		"5 + true * 2;",
+		`"use strict"; 5 + true;`,
+		`"use strict"; if (1) { 2 };`,
+		"[1 + 1, (2, 3 * 3), #{4 - 4}];",
+		"var d = { a: 1 + 1 }; d.a * 10;",
+		"var g = func() { yield 1 + 1; yield 2 * 2; }; collect(g());",
+		"false and x;",
+		"true and nil;",
+		`"ab" * 2 + "c";`,
+	}
+
+	for _, input := range inputs {
+		expected := eval.Eval(object.NewScope(), parse(t, input))
+		got := eval.Eval(object.NewScope(), optimize.New().Optimize(parse(t, input)))
+
+		if got.Inspect() != expected.Inspect() {
+			t.Errorf("Different results for %q. Optimized=%s, Original=%s", input, got.Inspect(), expected.Inspect())
+		}
+	}
+}
+
+func assertOptimized(t *testing.T, input string, expected string) {
+	t.Helper()
+
+	program := optimize.New().Optimize(parse(t, input))
+	if got := dump(program); got != expected {
+		t.Fatalf("Wrong program for %q. Got=%q, Expected=%q", input, got, expected)
+	}
+}
+
+func dump(program *ast.Program) string {
+	var statements []string
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt.String())
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	program, errors := parser.ParseFromString(input)
+	if len(errors) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, errors)
+	}
+
+	return program
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/aziflaj/pingul/ast"
 	"github.com/aziflaj/pingul/lexer"
+	"github.com/aziflaj/pingul/token"
 )
 
 // Parser wraps the generated yacc parser and provides a compatible API
@@ -34,8 +37,6 @@ func (p *Parser) Errors() []string {
 
 // ParseProgram parses the input and returns an AST program
 func (p *Parser) ParseProgram() *ast.Program {
-	parseErrors = []string{} // Reset global errors
-
 	yaccLexer := &YaccLexer{
 		impl:    p.lexer,
 		program: nil,
@@ -46,7 +47,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	// Deduplicate consecutive identical errors
 	uniqueErrors := []string{}
 	var lastError string
-	for _, err := range parseErrors {
+	for _, err := range yaccLexer.errors {
 		if err != lastError {
 			uniqueErrors = append(uniqueErrors, err)
 			lastError = err
@@ -69,3 +70,119 @@ func ParseFromString(input string) (*ast.Program, []string) {
 	program := parser.ParseProgram()
 	return program, parser.Errors()
 }
+
+// position is where errors at the token are, the
+// way the resolver and the checker put it too
+func position(at token.Token) string {
+	return fmt.Sprintf("%d:%d: ", at.Line, at.Column)
+}
+
+// containsYield tells whether a function body yields, which makes the
+// function a generator. Nested functions are generators on their own,
+// so their bodies are not taken into account.
+func containsYield(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.YieldExpression:
+		return true
+	case *ast.SpawnExpression:
+		return node.Call != nil && containsYield(node.Call)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			if containsYield(stmt) {
+				return true
+			}
+		}
+	case *ast.ExpressionStatement:
+		return containsYield(node.Expression)
+	case *ast.VarStatement:
+		return containsYield(node.Value)
+	case *ast.ReturnStatement:
+		return containsYield(node.ReturnValue)
+	case *ast.List:
+		return anyContainsYield(node.Items)
+	case *ast.Tuple:
+		return anyContainsYield(node.Items)
+	case *ast.Set:
+		return anyContainsYield(node.Items)
+	case *ast.ObjectLiteral:
+		for _, value := range node.Pairs {
+			if containsYield(value) {
+				return true
+			}
+		}
+		for _, pair := range node.ComputedPairs {
+			if containsYield(pair.Key) || containsYield(pair.Value) {
+				return true
+			}
+		}
+	case *ast.PropertyAccess:
+		return containsYield(node.Object)
+	case *ast.IndexExpression:
+		return containsYield(node.List) || containsYield(node.Index)
+	case *ast.PrefixExpression:
+		return containsYield(node.Right)
+	case *ast.InfixExpression:
+		return containsYield(node.Left) || containsYield(node.Right)
+	case *ast.IfExpression:
+		return containsYield(node.Condition) ||
+			containsYield(node.Consequence) ||
+			(node.Alternative != nil && containsYield(node.Alternative))
+	case *ast.CallExpression:
+		if containsYield(node.Function) {
+			return true
+		}
+		return anyContainsYield(node.Arguments)
+	case *ast.ThrowExpression:
+		return containsYield(node.Value)
+	case *ast.TryExpression:
+		return containsYield(node.Block) ||
+			(node.Catch != nil && containsYield(node.Catch)) ||
+			(node.Finally != nil && containsYield(node.Finally))
+	}
+
+	return false
+}
+
+// nameFunc names the function being declared, if expr is one,
+// so that it can be told apart when it shows up in errors
+func nameFunc(expr ast.Expression, name string) ast.Expression {
+	if fun, ok := expr.(*ast.FuncExpression); ok {
+		fun.Name = name
+	}
+
+	return expr
+}
+
+// markTailCalls marks the calls in tail position of a function body:
+// the calls it ends with, including the ones at the end of if/else
+// branches, and the ones being returned. Whatever is inside a try
+// is left alone, since catch and finally still have to run after
+func markTailCalls(node ast.Node, tail bool) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for i, stmt := range node.Statements {
+			markTailCalls(stmt, tail && i == len(node.Statements)-1)
+		}
+	case *ast.ExpressionStatement:
+		markTailCalls(node.Expression, tail)
+	case *ast.ReturnStatement:
+		markTailCalls(node.ReturnValue, true)
+	case *ast.IfExpression:
+		markTailCalls(node.Consequence, tail)
+		if node.Alternative != nil {
+			markTailCalls(node.Alternative, tail)
+		}
+	case *ast.CallExpression:
+		node.IsTail = tail
+	}
+}
+
+func anyContainsYield(nodes []ast.Expression) bool {
+	for _, node := range nodes {
+		if containsYield(node) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -274,6 +274,131 @@ func TestParseList(t *testing.T) {
 	}
 }
 
+func TestParseTuple(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []any
+		str      string
+	}{
+		{"()", []any{}, "()"},
+		{"(1,)", []any{1}, "(1,)"},
+		{"(1, 2)", []any{1, 2}, "(1, 2)"},
+		{"(1, 2, 3)", []any{1, 2, 3}, "(1, 2, 3)"},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(tc.input)
+		p := parser.New(lxr)
+		program := p.ParseProgram()
+
+		assertProgram(t, program)
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 1)
+
+		exprStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+				program.Statements[0])
+		}
+
+		tuple, ok := exprStmt.Expression.(*ast.Tuple)
+		if !ok {
+			t.Fatalf("expression is not *ast.Tuple. Got=%T", exprStmt.Expression)
+		}
+
+		if len(tuple.Items) != len(tc.expected) {
+			t.Errorf("tuple.Items does not have %d elements. Got=%d",
+				len(tc.expected), len(tuple.Items))
+		}
+
+		for i, item := range tuple.Items {
+			if !testLiteralExpression(t, item, tc.expected[i]) {
+				return
+			}
+		}
+
+		if tuple.String() != tc.str {
+			t.Errorf("tuple.String() is not %q. Got=%q", tc.str, tuple.String())
+		}
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []any
+	}{
+		{"#{}", []any{}},
+		{"#{1}", []any{1}},
+		{"#{1, 2, 3}", []any{1, 2, 3}},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(tc.input)
+		p := parser.New(lxr)
+		program := p.ParseProgram()
+
+		assertProgram(t, program)
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 1)
+
+		exprStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+				program.Statements[0])
+		}
+
+		set, ok := exprStmt.Expression.(*ast.Set)
+		if !ok {
+			t.Fatalf("expression is not *ast.Set. Got=%T", exprStmt.Expression)
+		}
+
+		if len(set.Items) != len(tc.expected) {
+			t.Errorf("set.Items does not have %d elements. Got=%d",
+				len(tc.expected), len(set.Items))
+		}
+
+		for i, item := range set.Items {
+			if !testLiteralExpression(t, item, tc.expected[i]) {
+				return
+			}
+		}
+	}
+}
+
+func TestParseComputedKeys(t *testing.T) {
+	input := `{ name: 1, [(1, 2)]: 2, [3]: 3 }`
+
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+
+	assertProgram(t, program)
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 1)
+
+	exprStmt := program.Statements[0].(*ast.ExpressionStatement)
+	obj, ok := exprStmt.Expression.(*ast.ObjectLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.ObjectLiteral. Got=%T", exprStmt.Expression)
+	}
+
+	if len(obj.Pairs) != 1 {
+		t.Errorf("obj.Pairs does not have 1 pair. Got=%d", len(obj.Pairs))
+	}
+
+	if len(obj.ComputedPairs) != 2 {
+		t.Fatalf("obj.ComputedPairs does not have 2 pairs. Got=%d", len(obj.ComputedPairs))
+	}
+
+	if _, ok := obj.ComputedPairs[0].Key.(*ast.Tuple); !ok {
+		t.Errorf("first computed key is not *ast.Tuple. Got=%T", obj.ComputedPairs[0].Key)
+	}
+
+	testLiteralExpression(t, obj.ComputedPairs[1].Key, 3)
+	testLiteralExpression(t, obj.ComputedPairs[1].Value, 3)
+}
+
 func TestParseIndexExpressions(t *testing.T) {
 	input := "myList[1]"
 
@@ -365,6 +490,8 @@ func TestParseInfixExpressions(t *testing.T) {
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
 		{"true == true", true, "==", true},
+		{"a is b", "a", "is", "b"},
+		{"a in b", "a", "in", "b"},
 	}
 
 	for _, tc := range testCases {
@@ -497,6 +624,215 @@ func TestIfExpressions(t *testing.T) {
 	}
 }
 
+func TestThrowExpressions(t *testing.T) {
+	input := `throw x + 1;`
+
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+	assertProgram(t, program)
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+			program.Statements[0])
+	}
+
+	throwExpr, ok := stmt.Expression.(*ast.ThrowExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.ThrowExpression. Got=%T", stmt.Expression)
+	}
+
+	// throw binds looser than any operator
+	testInfixExpression(t, throwExpr.Value, "x", "+", 1)
+}
+
+func TestTryExpressions(t *testing.T) {
+	testCases := []struct {
+		input      string
+		catchParam string
+		hasCatch   bool
+		hasFinally bool
+		expected   string
+	}{
+		{"try { x } catch (e) { e }", "e", true, false, "try {x} catch (e) {e}"},
+		{"try { x } catch { y }", "", true, false, "try {x} catch {y}"},
+		{"try { x } finally { y }", "", false, true, "try {x} finally {y}"},
+		{"try { x } catch (err) { y } finally { z }", "err", true, true, "try {x} catch (err) {y} finally {z}"},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(tc.input)
+		p := parser.New(lxr)
+		program := p.ParseProgram()
+		assertProgram(t, program)
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 1)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+				program.Statements[0])
+		}
+
+		tryExpr, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.TryExpression. Got=%T", stmt.Expression)
+		}
+
+		if tc.catchParam == "" && tryExpr.CatchParam != nil {
+			t.Errorf("Expected no catch param. Got=%s", tryExpr.CatchParam)
+		}
+
+		if tc.catchParam != "" && !testIdentifier(t, tryExpr.CatchParam, tc.catchParam) {
+			return
+		}
+
+		if (tryExpr.Catch != nil) != tc.hasCatch {
+			t.Errorf("Expected catch block: %t", tc.hasCatch)
+		}
+
+		if (tryExpr.Finally != nil) != tc.hasFinally {
+			t.Errorf("Expected finally block: %t", tc.hasFinally)
+		}
+
+		if tryExpr.String() != tc.expected {
+			t.Errorf("Expected %q. Got=%q", tc.expected, tryExpr.String())
+		}
+	}
+}
+
+func TestGeneratorFuncs(t *testing.T) {
+	testCases := []struct {
+		input       string
+		isGenerator bool
+	}{
+		{"func() { 1 }", false},
+		{"func() { yield 1 }", true},
+		{"func(x) { if (x) { yield x } }", true},
+		{"func(x) { try { yield x } catch (e) { 0 } }", true},
+		{"func(x) { var y = yield x; }", true},
+		// yielding from a nested function makes that one a generator, not this one
+		{"func() { func() { yield 1 } }", false},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(tc.input)
+		p := parser.New(lxr)
+		program := p.ParseProgram()
+		assertProgram(t, program)
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 1)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+				program.Statements[0])
+		}
+
+		funcExpr, ok := stmt.Expression.(*ast.FuncExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.FuncExpression. Got=%T", stmt.Expression)
+		}
+
+		if funcExpr.IsGenerator != tc.isGenerator {
+			t.Errorf("Expected IsGenerator=%t for %q", tc.isGenerator, tc.input)
+		}
+	}
+}
+
+func TestSpawnExpressions(t *testing.T) {
+	input := `spawn worker(1, 2);`
+
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+	assertProgram(t, program)
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. Got=%T",
+			program.Statements[0])
+	}
+
+	spawnExpr, ok := stmt.Expression.(*ast.SpawnExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.SpawnExpression. Got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, spawnExpr.Call.Function, "worker") {
+		return
+	}
+
+	if len(spawnExpr.Call.Arguments) != 2 {
+		t.Errorf("Call.Arguments does not have 2 elements. Got=%d", len(spawnExpr.Call.Arguments))
+	}
+
+	// only function calls can be spawned
+	lxr = lexer.New(`spawn 5;`)
+	p = parser.New(lxr)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Errorf("Expected an error for spawning a non-call")
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	input := `import "lib/list.pl" as list;
+from "math.pl" import square, cube;
+export var answer = 42;`
+
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+	assertProgram(t, program)
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 3)
+
+	importStmt, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImportStatement. Got=%T",
+			program.Statements[0])
+	}
+
+	if importStmt.Path != "lib/list.pl" {
+		t.Errorf("importStmt.Path is not %q. Got=%q", "lib/list.pl", importStmt.Path)
+	}
+
+	if importStmt.Alias == nil || importStmt.Alias.String() != "list" {
+		t.Errorf("importStmt.Alias is not 'list'. Got=%v", importStmt.Alias)
+	}
+
+	fromStmt, ok := program.Statements[1].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not *ast.ImportStatement. Got=%T",
+			program.Statements[1])
+	}
+
+	if fromStmt.Alias != nil {
+		t.Errorf("fromStmt.Alias should be nil. Got=%v", fromStmt.Alias)
+	}
+
+	if fromStmt.String() != `from "math.pl" import square, cube;` {
+		t.Errorf("fromStmt.String() is wrong. Got=%q", fromStmt.String())
+	}
+
+	varStmt, ok := program.Statements[2].(*ast.VarStatement)
+	if !ok {
+		t.Fatalf("program.Statements[2] is not *ast.VarStatement. Got=%T",
+			program.Statements[2])
+	}
+
+	if !varStmt.Exported {
+		t.Errorf("varStmt.Exported should be true")
+	}
+}
+
 func TestFuncExpressions(t *testing.T) {
 	input := `func(x, y) { x + y; }`
 
@@ -593,6 +929,217 @@ func TestFuncParams(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotations(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var age: int = 28;", "var age: int = 28;"},
+		{"export var name: string = \"x\";", "export var name: string = x;"},
+		{"var f: func = func(n: int, rest): int { n }", "var f: func = func(n: int, rest): int {{n}};"},
+		{"var g = func(x: nil, y: func): any {}", "var g = func(x: nil, y: func): any {{}};"},
+		{"var h = func(x) {}", "var h = func(x) {{}};"},
+	}
+
+	for _, tc := range testCases {
+		lxr := lexer.New(tc.input)
+		p := parser.New(lxr)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 1)
+
+		if program.String() != tc.expected {
+			t.Errorf("Wrong program for %q. Got=%q, Expected=%q", tc.input, program.String(), tc.expected)
+		}
+	}
+
+	program, _ := parser.ParseFromString("var f = func(n: int, rest): bool { n }")
+	funcExpr := program.Statements[0].(*ast.VarStatement).Value.(*ast.FuncExpression)
+
+	if funcExpr.Params[0].Type == nil || funcExpr.Params[0].Type.Name != "int" {
+		t.Errorf("Expected the first param to be annotated as int. Got=%v", funcExpr.Params[0].Type)
+	}
+	if funcExpr.Params[1].Type != nil {
+		t.Errorf("Expected the second param not to be annotated. Got=%v", funcExpr.Params[1].Type)
+	}
+	if funcExpr.ReturnType == nil || funcExpr.ReturnType.Name != "bool" {
+		t.Errorf("Expected the return type to be bool. Got=%v", funcExpr.ReturnType)
+	}
+}
+
+func TestEnumStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Color { Red }", "enum Color { Red }"},
+		{"enum Color { Red, Green, Blue, }", ""},
+		{"enum Payment { Pending, Paid(amount), Refunded(amount: int, reason) };", "enum Payment { Pending, Paid(amount), Refunded(amount: int, reason) }"},
+		{"export enum Unit { Unit() }", "export enum Unit { Unit }"},
+	}
+
+	for _, tc := range testCases {
+		program, errors := parser.ParseFromString(tc.input)
+
+		if tc.expected == "" {
+			if len(errors) == 0 {
+				t.Errorf("Expected parse errors for %q", tc.input)
+			}
+			continue
+		}
+
+		if len(errors) != 0 {
+			t.Fatalf("Parser errors for %q: %v", tc.input, errors)
+		}
+		assertProgramLength(t, program, 1)
+
+		if program.String() != tc.expected {
+			t.Errorf("Wrong program for %q. Got=%q, Expected=%q", tc.input, program.String(), tc.expected)
+		}
+	}
+
+	program, _ := parser.ParseFromString("enum Payment { Pending, Paid(amount) }")
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.EnumStatement. Got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.String() != "Payment" || len(stmt.Variants) != 2 {
+		t.Fatalf("Wrong enum. Got=%s", stmt)
+	}
+	if len(stmt.Variants[0].Fields) != 0 || len(stmt.Variants[1].Fields) != 1 {
+		t.Errorf("Wrong fields. Got=%v and %v", stmt.Variants[0].Fields, stmt.Variants[1].Fields)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []bool // whether each call, in the order they're found, is a tail call
+	}{
+		{"func() { f() }", []bool{true}},
+		{"func() { f(); g() }", []bool{false, true}},
+		{"func() { f(g()) }", []bool{true, false}},
+		{"func() { 1 + f() }", []bool{false}},
+		{"func() { if (c) { f() } else { g() } }", []bool{true, true}},
+		{"func() { if (c) { return f(); } g(); h() }", []bool{true, false, true}},
+		{"func() { var x = f(); x }", []bool{false}},
+		{"func() { try { f() } catch (e) { g() } }", []bool{false, false}},
+		{"func() { try { return f(); } finally { g() } }", []bool{false, false}},
+		{"func() { yield f() }", []bool{false}},
+		{"func() { func() { f() } }", []bool{true}},
+		{"f()", []bool{false}},
+	}
+
+	for _, tc := range testCases {
+		program, errors := parser.ParseFromString(tc.input)
+		if len(errors) != 0 {
+			t.Fatalf("Parser errors for %q: %v", tc.input, errors)
+		}
+
+		calls := findCalls(program)
+		if len(calls) != len(tc.expected) {
+			t.Fatalf("Wrong number of calls in %q. Got=%d, Expected=%d", tc.input, len(calls), len(tc.expected))
+		}
+
+		for i, call := range calls {
+			if call.IsTail != tc.expected[i] {
+				t.Errorf("Wrong IsTail for %s in %q. Got=%t, Expected=%t", call, tc.input, call.IsTail, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestFuncNames(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"var f = func() { 1 };", "f"},
+		{"export var f = func() { 1 };", "f"},
+		{"var obj = { area: func() { 1 } };", "area"},
+		{"var obj = { a: 1, area: func() { 1 } };", "area"},
+		{"apply(func() { 1 });", ""},
+		{"var obj = { [\"area\"]: func() { 1 } };", ""},
+	}
+
+	for _, tc := range testCases {
+		program, errors := parser.ParseFromString(tc.input)
+		if len(errors) != 0 {
+			t.Fatalf("Parser errors for %q: %v", tc.input, errors)
+		}
+
+		var fun *ast.FuncExpression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.VarStatement:
+			fun, _ = stmt.Value.(*ast.FuncExpression)
+			if obj, ok := stmt.Value.(*ast.ObjectLiteral); ok {
+				fun, _ = obj.Pairs["area"].(*ast.FuncExpression)
+				if len(obj.ComputedPairs) != 0 {
+					fun = obj.ComputedPairs[0].Value.(*ast.FuncExpression)
+				}
+			}
+		case *ast.ExpressionStatement:
+			fun = stmt.Expression.(*ast.CallExpression).Arguments[0].(*ast.FuncExpression)
+		}
+
+		if fun == nil {
+			t.Fatalf("No function found in %q", tc.input)
+		}
+		if fun.Name != tc.expected {
+			t.Errorf("Wrong name for %q. Got=%q, Expected=%q", tc.input, fun.Name, tc.expected)
+		}
+	}
+}
+
+// findCalls collects the calls of the statements the tests use, outermost first
+func findCalls(node ast.Node) []*ast.CallExpression {
+	var calls []*ast.CallExpression
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			calls = append(calls, findCalls(stmt)...)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			calls = append(calls, findCalls(stmt)...)
+		}
+	case *ast.ExpressionStatement:
+		calls = findCalls(node.Expression)
+	case *ast.ReturnStatement:
+		calls = findCalls(node.ReturnValue)
+	case *ast.VarStatement:
+		calls = findCalls(node.Value)
+	case *ast.YieldExpression:
+		calls = findCalls(node.Value)
+	case *ast.InfixExpression:
+		calls = append(findCalls(node.Left), findCalls(node.Right)...)
+	case *ast.FuncExpression:
+		calls = findCalls(node.Body)
+	case *ast.IfExpression:
+		calls = findCalls(node.Consequence)
+		if node.Alternative != nil {
+			calls = append(calls, findCalls(node.Alternative)...)
+		}
+	case *ast.TryExpression:
+		calls = findCalls(node.Block)
+		if node.Catch != nil {
+			calls = append(calls, findCalls(node.Catch)...)
+		}
+		if node.Finally != nil {
+			calls = append(calls, findCalls(node.Finally)...)
+		}
+	case *ast.CallExpression:
+		calls = []*ast.CallExpression{node}
+		for _, arg := range node.Arguments {
+			calls = append(calls, findCalls(arg)...)
+		}
+	}
+
+	return calls
+}
+
 func TestCallExpressions(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 
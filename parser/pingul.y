@@ -9,8 +9,6 @@ import (
 	"github.com/aziflaj/pingul/token"
 )
 
-var parseErrors []string
-
 %}
 
 %union {
@@ -21,7 +19,11 @@ var parseErrors []string
 	expression       ast.Expression
 	expressions      []ast.Expression
 	identifiers      []*ast.Identifier
-	objPairs         map[string]ast.Expression
+	objectLiteral    *ast.ObjectLiteral
+	tryExpression    *ast.TryExpression
+	typeAnnotation   *ast.TypeAnnotation
+	variant          *ast.EnumVariant
+	variants         []*ast.EnumVariant
 	token            token.Token
 	literal          []rune
 	intVal           int64
@@ -31,9 +33,11 @@ var parseErrors []string
 %token <token>  IDENTIFIER INT STRING
 %token <token>  PLUS MINUS MULTIPLY DIVIDE MODULUS
 %token <token>  EQUAL NOT_EQUAL GREATER_THAN LESS_THAN GREATER_THAN_OR_EQUAL LESS_THAN_OR_EQUAL
-%token <token>  ASSIGNMENT COMMA SEMICOLON COLON DOT
+%token <token>  ASSIGNMENT COMMA SEMICOLON COLON DOT HASH
 %token <token>  LPAREN RPAREN LBRACKET RBRACKET LBRACE RBRACE
 %token <token>  VAR FUNC RETURN IF ELSE NIL TRUE FALSE AND OR NOT
+%token <token>  THROW TRY CATCH FINALLY YIELD SPAWN
+%token <token>  IMPORT AS FROM EXPORT IS IN ENUM
 
 %type <program>         program
 %type <statements>      statements
@@ -44,17 +48,23 @@ var parseErrors []string
 %type <expressions>     expressionList
 %type <expressions>     arguments
 %type <identifiers>     parameters
-%type <objPairs>        objectPairs
-%type <objPairs>        objectPairsList
+%type <identifiers>     importNames
+%type <objectLiteral>   objectPairs
+%type <objectLiteral>   objectPairsList
+%type <tryExpression>   tryCatch
+%type <typeAnnotation>  optType
+%type <variant>         variant
+%type <variants>        variants
 
 /* Operator precedence and associativity */
+%right THROW YIELD
 %left OR
 %left AND
-%left EQUAL NOT_EQUAL
-%left GREATER_THAN LESS_THAN GREATER_THAN_OR_EQUAL LESS_THAN_OR_EQUAL
+%left EQUAL NOT_EQUAL IS
+%left GREATER_THAN LESS_THAN GREATER_THAN_OR_EQUAL LESS_THAN_OR_EQUAL IN
 %left PLUS MINUS
 %left MULTIPLY DIVIDE MODULUS
-%right UNARY_MINUS UNARY_NOT
+%right UNARY_MINUS UNARY_NOT SPAWN
 %left DOT
 %left LPAREN RPAREN LBRACKET RBRACKET
 
@@ -93,15 +103,71 @@ statements
 	;
 
 statement
-	: VAR IDENTIFIER ASSIGNMENT expression optSemicolon
+	: VAR IDENTIFIER optType ASSIGNMENT expression optSemicolon
 	{
 		$$ = &ast.VarStatement{
 			Token: $1,
 			Name: &ast.Identifier{
 				Token: $2,
 				Value: $2.Literal,
+				Type:  $3,
+			},
+			Value: nameFunc($5, string($2.Literal)),
+		}
+	}
+	| EXPORT VAR IDENTIFIER optType ASSIGNMENT expression optSemicolon
+	{
+		$$ = &ast.VarStatement{
+			Token: $2,
+			Name: &ast.Identifier{
+				Token: $3,
+				Value: $3.Literal,
+				Type:  $4,
+			},
+			Value:    nameFunc($6, string($3.Literal)),
+			Exported: true,
+		}
+	}
+	| ENUM IDENTIFIER LBRACE variants RBRACE optSemicolon
+	{
+		$$ = &ast.EnumStatement{
+			Token: $1,
+			Name: &ast.Identifier{
+				Token: $2,
+				Value: $2.Literal,
+			},
+			Variants: $4,
+		}
+	}
+	| EXPORT ENUM IDENTIFIER LBRACE variants RBRACE optSemicolon
+	{
+		$$ = &ast.EnumStatement{
+			Token: $2,
+			Name: &ast.Identifier{
+				Token: $3,
+				Value: $3.Literal,
 			},
-			Value: $4,
+			Variants: $5,
+			Exported: true,
+		}
+	}
+	| IMPORT STRING AS IDENTIFIER optSemicolon
+	{
+		$$ = &ast.ImportStatement{
+			Token: $1,
+			Path:  string($2.Literal),
+			Alias: &ast.Identifier{
+				Token: $4,
+				Value: $4.Literal,
+			},
+		}
+	}
+	| FROM STRING IMPORT importNames optSemicolon
+	{
+		$$ = &ast.ImportStatement{
+			Token: $1,
+			Path:  string($2.Literal),
+			Names: $4,
 		}
 	}
 	| RETURN expression optSemicolon
@@ -267,6 +333,24 @@ expression
 			Right:    $3,
 		}
 	}
+	| expression IN expression
+	{
+		$$ = &ast.InfixExpression{
+			Token:    $2,
+			Left:     $1,
+			Operator: string($2.Literal),
+			Right:    $3,
+		}
+	}
+	| expression IS expression
+	{
+		$$ = &ast.InfixExpression{
+			Token:    $2,
+			Left:     $1,
+			Operator: string($2.Literal),
+			Right:    $3,
+		}
+	}
 	| expression AND expression
 	{
 		$$ = &ast.InfixExpression{
@@ -325,6 +409,32 @@ expression
 			Arguments: $3,
 		}
 	}
+	| THROW expression
+	{
+		$$ = &ast.ThrowExpression{
+			Token: $1,
+			Value: $2,
+		}
+	}
+	| YIELD expression
+	{
+		$$ = &ast.YieldExpression{
+			Token: $1,
+			Value: $2,
+		}
+	}
+	| SPAWN expression
+	{
+		call, ok := $2.(*ast.CallExpression)
+		if !ok {
+			yylex.Error("spawn expects a function call")
+		}
+
+		$$ = &ast.SpawnExpression{
+			Token: $1,
+			Call:  call,
+		}
+	}
 	;
 
 primary
@@ -384,10 +494,8 @@ primary
 	}
 	| LBRACE objectPairs RBRACE
 	{
-		$$ = &ast.ObjectLiteral{
-			Token: $1,
-			Pairs: $2,
-		}
+		$2.Token = $1
+		$$ = $2
 	}
 	| LBRACE RBRACE
 	{
@@ -396,10 +504,45 @@ primary
 			Pairs: make(map[string]ast.Expression),
 		}
 	}
+	| HASH LBRACE expressionList RBRACE
+	{
+		$$ = &ast.Set{
+			Token: $1,
+			Items: $3,
+		}
+	}
+	| HASH LBRACE RBRACE
+	{
+		$$ = &ast.Set{
+			Token: $1,
+			Items: []ast.Expression{},
+		}
+	}
 	| LPAREN expression RPAREN
 	{
 		$$ = $2
 	}
+	| LPAREN RPAREN
+	{
+		$$ = &ast.Tuple{
+			Token: $1,
+			Items: []ast.Expression{},
+		}
+	}
+	| LPAREN expression COMMA RPAREN
+	{
+		$$ = &ast.Tuple{
+			Token: $1,
+			Items: []ast.Expression{$2},
+		}
+	}
+	| LPAREN expression COMMA expressionList RPAREN
+	{
+		$$ = &ast.Tuple{
+			Token: $1,
+			Items: append([]ast.Expression{$2}, $4...),
+		}
+	}
 	| IF LPAREN expression RPAREN block
 	{
 		$$ = &ast.IfExpression{
@@ -417,12 +560,59 @@ primary
 			Alternative: $7,
 		}
 	}
-	| FUNC LPAREN parameters RPAREN block
+	| FUNC LPAREN parameters RPAREN optType block
 	{
+		isGenerator := containsYield($6)
+		if !isGenerator {
+			markTailCalls($6, true)
+		}
+
 		$$ = &ast.FuncExpression{
-			Token:  $1,
-			Params: $3,
-			Body:   $5,
+			Token:       $1,
+			Params:      $3,
+			Body:        $6,
+			ReturnType:  $5,
+			IsGenerator: isGenerator,
+		}
+	}
+	| tryCatch
+	{
+		$$ = $1
+	}
+	| tryCatch FINALLY block
+	{
+		$1.Finally = $3
+		$$ = $1
+	}
+	| TRY block FINALLY block
+	{
+		$$ = &ast.TryExpression{
+			Token:   $1,
+			Block:   $2,
+			Finally: $4,
+		}
+	}
+	;
+
+tryCatch
+	: TRY block CATCH LPAREN IDENTIFIER RPAREN block
+	{
+		$$ = &ast.TryExpression{
+			Token: $1,
+			Block: $2,
+			CatchParam: &ast.Identifier{
+				Token: $5,
+				Value: $5.Literal,
+			},
+			Catch: $7,
+		}
+	}
+	| TRY block CATCH block
+	{
+		$$ = &ast.TryExpression{
+			Token: $1,
+			Block: $2,
+			Catch: $4,
 		}
 	}
 	;
@@ -454,20 +644,22 @@ arguments
 	;
 
 parameters
-	: IDENTIFIER
+	: IDENTIFIER optType
 	{
 		$$ = []*ast.Identifier{
 			{
 				Token: $1,
 				Value: $1.Literal,
+				Type:  $2,
 			},
 		}
 	}
-	| parameters COMMA IDENTIFIER
+	| parameters COMMA IDENTIFIER optType
 	{
 		$$ = append($1, &ast.Identifier{
 			Token: $3,
 			Value: $3.Literal,
+			Type:  $4,
 		})
 	}
 	| /* empty */
@@ -476,6 +668,79 @@ parameters
 	}
 	;
 
+variants
+	: variant
+	{
+		$$ = []*ast.EnumVariant{$1}
+	}
+	| variants COMMA variant
+	{
+		$$ = append($1, $3)
+	}
+	;
+
+variant
+	: IDENTIFIER
+	{
+		$$ = &ast.EnumVariant{
+			Name: &ast.Identifier{
+				Token: $1,
+				Value: $1.Literal,
+			},
+			Fields: []*ast.Identifier{},
+		}
+	}
+	| IDENTIFIER LPAREN parameters RPAREN
+	{
+		$$ = &ast.EnumVariant{
+			Name: &ast.Identifier{
+				Token: $1,
+				Value: $1.Literal,
+			},
+			Fields: $3,
+		}
+	}
+	;
+
+/* nil and func are keywords, but they're types too */
+optType
+	: COLON IDENTIFIER
+	{
+		$$ = &ast.TypeAnnotation{Token: $2, Name: string($2.Literal)}
+	}
+	| COLON NIL
+	{
+		$$ = &ast.TypeAnnotation{Token: $2, Name: string($2.Literal)}
+	}
+	| COLON FUNC
+	{
+		$$ = &ast.TypeAnnotation{Token: $2, Name: string($2.Literal)}
+	}
+	| /* empty */
+	{
+		$$ = nil
+	}
+	;
+
+importNames
+	: IDENTIFIER
+	{
+		$$ = []*ast.Identifier{
+			{
+				Token: $1,
+				Value: $1.Literal,
+			},
+		}
+	}
+	| importNames COMMA IDENTIFIER
+	{
+		$$ = append($1, &ast.Identifier{
+			Token: $3,
+			Value: $3.Literal,
+		})
+	}
+	;
+
 objectPairs
 	: objectPairsList
 	{
@@ -486,12 +751,22 @@ objectPairs
 objectPairsList
 	: IDENTIFIER COLON expression
 	{
-		$$ = make(map[string]ast.Expression)
-		$$[string($1.Literal)] = $3
+		$$ = &ast.ObjectLiteral{Pairs: make(map[string]ast.Expression)}
+		$$.Pairs[string($1.Literal)] = nameFunc($3, string($1.Literal))
+	}
+	| LBRACKET expression RBRACKET COLON expression
+	{
+		$$ = &ast.ObjectLiteral{Pairs: make(map[string]ast.Expression)}
+		$$.ComputedPairs = append($$.ComputedPairs, &ast.ComputedPair{Key: $2, Value: $5})
 	}
 	| objectPairsList COMMA IDENTIFIER COLON expression
 	{
-		$1[string($3.Literal)] = $5
+		$1.Pairs[string($3.Literal)] = nameFunc($5, string($3.Literal))
+		$$ = $1
+	}
+	| objectPairsList COMMA LBRACKET expression RBRACKET COLON expression
+	{
+		$1.ComputedPairs = append($1.ComputedPairs, &ast.ComputedPair{Key: $4, Value: $7})
 		$$ = $1
 	}
 	;
@@ -501,14 +776,22 @@ objectPairsList
 type YaccLexer struct {
 	impl    *lexer.LexerImpl
 	program *ast.Program
+
+	// the last token handed to the parser, where it ran into an error
+	last token.Token
+
+	// kept here rather than in a global, since modules
+	// imported by different tasks get parsed at the same time
+	errors []string
 }
 
 func (l *YaccLexer) Error(s string) {
-	parseErrors = append(parseErrors, s)
+	l.errors = append(l.errors, position(l.last)+s)
 }
 
 func (l *YaccLexer) Lex(lval *yySymType) int {
 	tkn := l.impl.NextToken()
+	l.last = tkn
 	
 	if tkn.Type == token.EOF {
 		return 0
@@ -555,6 +838,8 @@ func (l *YaccLexer) Lex(lval *yySymType) int {
 		return COLON
 	case token.DOT:
 		return DOT
+	case token.HASH:
+		return HASH
 	case token.LPAREN:
 		return LPAREN
 	case token.RPAREN:
@@ -589,6 +874,32 @@ func (l *YaccLexer) Lex(lval *yySymType) int {
 		return OR
 	case token.NOT:
 		return NOT
+	case token.THROW:
+		return THROW
+	case token.TRY:
+		return TRY
+	case token.CATCH:
+		return CATCH
+	case token.FINALLY:
+		return FINALLY
+	case token.YIELD:
+		return YIELD
+	case token.SPAWN:
+		return SPAWN
+	case token.IMPORT:
+		return IMPORT
+	case token.AS:
+		return AS
+	case token.FROM:
+		return FROM
+	case token.EXPORT:
+		return EXPORT
+	case token.IS:
+		return IS
+	case token.IN:
+		return IN
+	case token.ENUM:
+		return ENUM
 	}
 	
 	return int(tkn.Type)
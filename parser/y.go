@@ -15,9 +15,7 @@ import (
 	"github.com/aziflaj/pingul/token"
 )
 
-var parseErrors []string
-
-//line pingul.y:16
+//line pingul.y:14
 type yySymType struct {
 	yys            int
 	program        *ast.Program
@@ -27,7 +25,11 @@ type yySymType struct {
 	expression     ast.Expression
 	expressions    []ast.Expression
 	identifiers    []*ast.Identifier
-	objPairs       map[string]ast.Expression
+	objectLiteral  *ast.ObjectLiteral
+	tryExpression  *ast.TryExpression
+	typeAnnotation *ast.TypeAnnotation
+	variant        *ast.EnumVariant
+	variants       []*ast.EnumVariant
 	token          token.Token
 	literal        []rune
 	intVal         int64
@@ -52,25 +54,39 @@ const COMMA = 57361
 const SEMICOLON = 57362
 const COLON = 57363
 const DOT = 57364
-const LPAREN = 57365
-const RPAREN = 57366
-const LBRACKET = 57367
-const RBRACKET = 57368
-const LBRACE = 57369
-const RBRACE = 57370
-const VAR = 57371
-const FUNC = 57372
-const RETURN = 57373
-const IF = 57374
-const ELSE = 57375
-const NIL = 57376
-const TRUE = 57377
-const FALSE = 57378
-const AND = 57379
-const OR = 57380
-const NOT = 57381
-const UNARY_MINUS = 57382
-const UNARY_NOT = 57383
+const HASH = 57365
+const LPAREN = 57366
+const RPAREN = 57367
+const LBRACKET = 57368
+const RBRACKET = 57369
+const LBRACE = 57370
+const RBRACE = 57371
+const VAR = 57372
+const FUNC = 57373
+const RETURN = 57374
+const IF = 57375
+const ELSE = 57376
+const NIL = 57377
+const TRUE = 57378
+const FALSE = 57379
+const AND = 57380
+const OR = 57381
+const NOT = 57382
+const THROW = 57383
+const TRY = 57384
+const CATCH = 57385
+const FINALLY = 57386
+const YIELD = 57387
+const SPAWN = 57388
+const IMPORT = 57389
+const AS = 57390
+const FROM = 57391
+const EXPORT = 57392
+const IS = 57393
+const IN = 57394
+const ENUM = 57395
+const UNARY_MINUS = 57396
+const UNARY_NOT = 57397
 
 var yyToknames = [...]string{
 	"$end",
@@ -95,6 +111,7 @@ var yyToknames = [...]string{
 	"SEMICOLON",
 	"COLON",
 	"DOT",
+	"HASH",
 	"LPAREN",
 	"RPAREN",
 	"LBRACKET",
@@ -112,6 +129,19 @@ var yyToknames = [...]string{
 	"AND",
 	"OR",
 	"NOT",
+	"THROW",
+	"TRY",
+	"CATCH",
+	"FINALLY",
+	"YIELD",
+	"SPAWN",
+	"IMPORT",
+	"AS",
+	"FROM",
+	"EXPORT",
+	"IS",
+	"IN",
+	"ENUM",
 	"UNARY_MINUS",
 	"UNARY_NOT",
 }
@@ -122,19 +152,27 @@ const yyEofCode = 1
 const yyErrCode = 2
 const yyInitialStackSize = 16
 
-//line pingul.y:499
+//line pingul.y:774
 
 type YaccLexer struct {
 	impl    *lexer.LexerImpl
 	program *ast.Program
+
+	// the last token handed to the parser, where it ran into an error
+	last token.Token
+
+	// kept here rather than in a global, since modules
+	// imported by different tasks get parsed at the same time
+	errors []string
 }
 
 func (l *YaccLexer) Error(s string) {
-	parseErrors = append(parseErrors, s)
+	l.errors = append(l.errors, position(l.last)+s)
 }
 
 func (l *YaccLexer) Lex(lval *yySymType) int {
 	tkn := l.impl.NextToken()
+	l.last = tkn
 
 	if tkn.Type == token.EOF {
 		return 0
@@ -181,6 +219,8 @@ func (l *YaccLexer) Lex(lval *yySymType) int {
 		return COLON
 	case token.DOT:
 		return DOT
+	case token.HASH:
+		return HASH
 	case token.LPAREN:
 		return LPAREN
 	case token.RPAREN:
@@ -215,6 +255,32 @@ func (l *YaccLexer) Lex(lval *yySymType) int {
 		return OR
 	case token.NOT:
 		return NOT
+	case token.THROW:
+		return THROW
+	case token.TRY:
+		return TRY
+	case token.CATCH:
+		return CATCH
+	case token.FINALLY:
+		return FINALLY
+	case token.YIELD:
+		return YIELD
+	case token.SPAWN:
+		return SPAWN
+	case token.IMPORT:
+		return IMPORT
+	case token.AS:
+		return AS
+	case token.FROM:
+		return FROM
+	case token.EXPORT:
+		return EXPORT
+	case token.IS:
+		return IS
+	case token.IN:
+		return IN
+	case token.ENUM:
+		return ENUM
 	}
 
 	return int(tkn.Type)
@@ -235,116 +301,186 @@ var yyExca = [...]int8{
 
 const yyPrivate = 57344
 
-const yyLast = 416
-
-var yyAct = [...]int8{
-	6, 3, 2, 96, 22, 101, 24, 76, 97, 54,
-	43, 44, 40, 41, 75, 39, 53, 51, 47, 25,
-	52, 74, 95, 78, 77, 55, 99, 57, 58, 59,
-	60, 61, 62, 63, 64, 65, 66, 67, 68, 69,
-	70, 49, 73, 92, 56, 86, 88, 82, 91, 71,
-	85, 23, 50, 48, 80, 81, 83, 26, 27, 28,
-	29, 30, 31, 32, 33, 34, 35, 36, 28, 29,
-	30, 72, 40, 41, 45, 39, 87, 8, 1, 89,
-	0, 40, 41, 0, 39, 0, 7, 94, 11, 12,
-	13, 0, 9, 0, 0, 98, 100, 0, 0, 0,
-	102, 0, 0, 93, 22, 104, 0, 19, 0, 17,
-	0, 18, 105, 4, 21, 5, 20, 0, 16, 14,
-	15, 0, 7, 10, 11, 12, 13, 0, 9, 0,
+const yyLast = 787
+
+var yyAct = [...]uint8{
+	10, 79, 81, 119, 132, 133, 3, 2, 65, 32,
+	39, 34, 86, 87, 60, 61, 62, 63, 64, 78,
+	40, 123, 122, 185, 110, 67, 161, 161, 74, 109,
+	80, 127, 131, 109, 35, 162, 177, 160, 188, 146,
+	85, 108, 89, 90, 91, 92, 93, 94, 95, 96,
+	97, 98, 99, 100, 101, 102, 103, 104, 129, 107,
+	88, 73, 128, 142, 41, 42, 43, 44, 45, 77,
+	151, 154, 76, 113, 67, 80, 191, 118, 71, 57,
+	121, 58, 114, 56, 192, 143, 130, 82, 124, 41,
+	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
+	72, 117, 169, 69, 57, 109, 58, 116, 56, 167,
+	141, 170, 112, 144, 59, 43, 44, 45, 67, 111,
+	54, 55, 158, 152, 153, 155, 148, 157, 57, 126,
+	58, 32, 56, 53, 52, 57, 159, 58, 38, 56,
+	151, 166, 140, 37, 168, 181, 150, 120, 139, 165,
+	59, 171, 134, 172, 174, 173, 163, 164, 137, 176,
+	135, 105, 84, 83, 36, 33, 180, 179, 182, 30,
+	184, 70, 68, 136, 186, 106, 187, 12, 175, 1,
+	11, 178, 18, 19, 20, 0, 13, 193, 0, 0,
+	194, 0, 0, 195, 0, 0, 0, 189, 190, 0,
+	0, 26, 27, 0, 24, 0, 25, 156, 4, 29,
+	9, 28, 0, 23, 21, 22, 0, 0, 14, 15,
+	31, 0, 0, 16, 17, 7, 0, 8, 5, 0,
+	11, 6, 18, 19, 20, 0, 13, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 26, 27, 0, 24, 0, 25, 125, 4, 29,
+	9, 28, 0, 23, 21, 22, 0, 0, 14, 15,
+	31, 0, 0, 16, 17, 7, 0, 8, 5, 0,
+	11, 6, 18, 19, 20, 0, 13, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 26, 27, 0, 24, 0, 25, 0, 4, 29,
+	9, 28, 0, 23, 21, 22, 0, 0, 14, 15,
+	31, 0, 0, 16, 17, 7, 0, 8, 5, 0,
+	0, 6, 41, 42, 43, 44, 45, 46, 47, 48,
+	49, 50, 51, 0, 0, 59, 0, 57, 0, 58,
+	0, 56, 41, 42, 43, 44, 45, 46, 47, 48,
+	49, 50, 51, 54, 55, 0, 0, 57, 0, 58,
+	0, 56, 183, 0, 0, 0, 53, 52, 0, 0,
+	0, 0, 0, 54, 55, 41, 42, 43, 44, 45,
+	46, 47, 48, 49, 50, 51, 53, 52, 0, 0,
+	57, 0, 58, 149, 56, 41, 42, 43, 44, 45,
+	46, 47, 48, 49, 50, 51, 54, 55, 0, 0,
+	57, 0, 58, 0, 56, 145, 0, 0, 0, 53,
+	52, 0, 0, 0, 0, 0, 54, 55, 41, 42,
+	43, 44, 45, 46, 47, 48, 49, 50, 51, 53,
+	52, 0, 0, 57, 0, 58, 0, 56, 138, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 54,
+	55, 41, 42, 43, 44, 45, 46, 47, 48, 49,
+	50, 51, 53, 52, 0, 0, 57, 0, 58, 0,
+	56, 41, 42, 43, 44, 45, 46, 47, 48, 49,
+	50, 51, 54, 55, 0, 0, 57, 0, 58, 0,
+	56, 0, 0, 0, 0, 53, 52, 0, 0, 0,
+	0, 0, 54, 41, 42, 43, 44, 45, 46, 47,
+	48, 49, 50, 51, 0, 53, 52, 0, 57, 0,
+	58, 0, 56, 0, 0, 0, 0, 0, 0, 0,
+	18, 19, 20, 0, 13, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 53, 52, 26,
+	27, 147, 24, 0, 25, 0, 0, 29, 0, 28,
+	0, 23, 21, 22, 0, 0, 14, 15, 31, 0,
+	0, 16, 17, 41, 42, 43, 44, 45, 0, 0,
+	48, 49, 50, 51, 0, 0, 0, 0, 57, 0,
+	58, 0, 56, 0, 0, 18, 19, 20, 0, 13,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 19, 0, 17, 0, 18, 103, 4,
-	21, 5, 20, 0, 16, 14, 15, 0, 0, 10,
-	26, 27, 28, 29, 30, 31, 32, 33, 34, 35,
-	36, 0, 0, 42, 0, 40, 41, 0, 39, 26,
-	27, 28, 29, 30, 31, 32, 33, 34, 35, 36,
-	37, 38, 0, 0, 40, 41, 90, 39, 26, 27,
-	28, 29, 30, 31, 32, 33, 34, 35, 36, 37,
-	38, 0, 0, 40, 41, 0, 39, 84, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 37, 38,
-	26, 27, 28, 29, 30, 31, 32, 33, 34, 35,
-	36, 0, 0, 0, 0, 40, 41, 79, 39, 7,
-	0, 11, 12, 13, 0, 9, 0, 0, 0, 0,
-	37, 38, 0, 0, 0, 0, 0, 0, 0, 0,
-	19, 0, 17, 0, 18, 0, 4, 21, 5, 20,
-	0, 16, 14, 15, 0, 0, 10, 26, 27, 28,
-	29, 30, 31, 32, 33, 34, 35, 36, 0, 0,
-	0, 0, 40, 41, 0, 39, 11, 12, 13, 0,
-	9, 0, 0, 0, 0, 0, 0, 37, 38, 0,
-	0, 0, 0, 0, 0, 19, 0, 17, 46, 18,
-	0, 0, 21, 0, 20, 0, 16, 14, 15, 0,
-	0, 10, 11, 12, 13, 0, 9, 0, 0, 0,
-	0, 26, 27, 28, 29, 30, 0, 0, 0, 0,
-	0, 19, 0, 17, 0, 18, 40, 41, 21, 39,
-	20, 0, 16, 14, 15, 0, 0, 10, 26, 27,
-	28, 29, 30, 31, 32, 33, 34, 35, 36, 0,
-	0, 0, 0, 40, 41, 0, 39, 26, 27, 28,
-	29, 30, 0, 0, 33, 34, 35, 36, 37, 0,
-	0, 0, 40, 41, 0, 39,
+	0, 0, 0, 0, 26, 27, 0, 24, 52, 25,
+	115, 0, 29, 0, 28, 0, 23, 21, 22, 0,
+	0, 14, 15, 31, 0, 0, 16, 17, 18, 19,
+	20, 0, 13, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 26, 27, 75,
+	24, 0, 25, 0, 0, 29, 0, 28, 0, 23,
+	21, 22, 0, 0, 14, 15, 31, 0, 0, 16,
+	17, 18, 19, 20, 0, 13, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	26, 27, 0, 24, 66, 25, 0, 0, 29, 0,
+	28, 0, 23, 21, 22, 0, 0, 14, 15, 31,
+	0, 0, 16, 17, 18, 19, 20, 0, 13, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 26, 27, 0, 24, 0, 25, 0,
+	0, 29, 0, 28, 0, 23, 21, 22, 0, 0,
+	14, 15, 31, 0, 0, 16, 17,
 }
 
 var yyPact = [...]int16{
-	247, -1000, 247, -1000, 47, 338, 153, -1000, -1000, 338,
-	338, -1000, -1000, -1000, -1000, -1000, -1000, 302, 13, 338,
-	-7, -14, -1000, 7, 153, -1000, 338, 338, 338, 338,
-	338, 338, 338, 338, 338, 338, 338, 338, 338, 338,
-	45, 338, -1000, -10, -10, -5, -1000, 280, -21, -1000,
-	5, 2, 223, 338, 43, 338, -1000, 59, 59, -10,
-	-10, -10, 390, 390, 344, 344, 344, 344, 50, 371,
-	191, -1000, 26, 280, -1000, 338, -1000, 42, 338, -1000,
-	172, 24, -1000, 153, -1000, -1000, 338, 280, 1, 280,
-	-19, -19, 22, -1000, 280, 338, -28, 120, -1000, -1000,
-	280, -19, 84, -1000, -1000, -1000,
+	278, -1000, 278, -1000, 161, -19, 160, 137, 132, 740,
+	325, -1000, -1000, 740, 740, 740, 740, 740, -1000, -1000,
+	-1000, -1000, -1000, -1000, 697, 74, 33, 654, 48, 45,
+	-25, 2, -1000, 66, 159, 158, 12, -36, -34, 325,
+	-1000, 740, 740, 740, 740, 740, 740, 740, 740, 740,
+	740, 740, 740, 740, 740, 740, 740, 157, 740, -1000,
+	113, 113, 464, 464, 113, 14, -1000, 464, -5, -1000,
+	100, 91, 740, 611, 82, -1000, 740, 143, 2, -22,
+	228, 111, 27, 66, 4, 148, 156, 154, -1000, 106,
+	106, 113, 113, 113, 586, 586, 57, 57, 57, 57,
+	57, 586, 516, 484, 431, -1000, 123, 464, -1000, 740,
+	-1000, 59, 740, 398, 10, -1000, -1000, 546, 378, 121,
+	66, -1000, 2, 47, 178, -1000, 740, -1000, -1000, -1000,
+	104, 148, 8, -1000, 11, 94, 130, -1000, -1000, -1000,
+	740, 464, 88, 740, 464, 81, -1000, -1000, 86, 2,
+	66, 151, -1000, -1000, 150, -1000, -1000, 325, 740, 7,
+	94, 148, 143, -1000, -1000, 141, 464, 740, 345, 740,
+	-1000, -11, 2, 66, 13, -1000, 325, 94, -1000, -1000,
+	51, -1000, 464, 63, 464, 2, -1000, -1000, 2, -1000,
+	-1000, -1000, 740, -1000, -1000, 464,
 }
 
-var yyPgo = [...]int8{
-	0, 78, 2, 1, 3, 0, 77, 74, 71, 55,
-	53, 52, 19,
+var yyPgo = [...]uint8{
+	0, 179, 7, 6, 1, 0, 177, 8, 175, 3,
+	173, 172, 171, 169, 2, 5, 4, 20,
 }
 
 var yyR1 = [...]int8{
-	0, 1, 1, 2, 2, 3, 3, 3, 3, 12,
-	12, 4, 4, 5, 5, 5, 5, 5, 5, 5,
+	0, 1, 1, 2, 2, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 17, 17, 4, 4, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
 	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
 	5, 5, 6, 6, 6, 6, 6, 6, 6, 6,
-	6, 6, 6, 6, 6, 6, 7, 7, 8, 8,
-	8, 9, 9, 9, 10, 11, 11,
+	6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+	6, 6, 6, 6, 13, 13, 7, 7, 8, 8,
+	8, 9, 9, 9, 16, 16, 15, 15, 14, 14,
+	14, 14, 10, 10, 11, 12, 12, 12, 12,
 }
 
 var yyR2 = [...]int8{
-	0, 1, 0, 1, 2, 5, 3, 2, 1, 1,
-	0, 3, 2, 1, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 2, 2, 4,
-	3, 4, 1, 1, 1, 1, 1, 1, 3, 2,
-	3, 2, 3, 5, 7, 5, 1, 3, 1, 3,
-	0, 1, 3, 0, 1, 3, 5,
+	0, 1, 0, 1, 2, 6, 7, 6, 7, 5,
+	5, 3, 2, 1, 1, 0, 3, 2, 1, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 2, 2, 4, 3, 4, 2,
+	2, 2, 1, 1, 1, 1, 1, 1, 3, 2,
+	3, 2, 4, 3, 3, 2, 4, 5, 5, 7,
+	6, 1, 3, 4, 7, 4, 1, 3, 1, 3,
+	0, 2, 4, 0, 1, 3, 1, 4, 2, 2,
+	2, 0, 1, 3, 1, 3, 5, 5, 7,
 }
 
 var yyChk = [...]int16{
-	-1000, -1, -2, -3, 29, 31, -5, 2, -6, 8,
-	39, 4, 5, 6, 35, 36, 34, 25, 27, 23,
-	32, 30, -3, 4, -5, -12, 7, 8, 9, 10,
-	11, 12, 13, 14, 15, 16, 17, 37, 38, 25,
-	22, 23, 20, -5, -5, -7, 26, -5, -10, 28,
-	-11, 4, -5, 23, 23, 18, -12, -5, -5, -5,
+	-1000, -1, -2, -3, 30, 50, 53, 47, 49, 32,
+	-5, 2, -6, 8, 40, 41, 45, 46, 4, 5,
+	6, 36, 37, 35, 26, 28, 23, 24, 33, 31,
+	-13, 42, -3, 4, 30, 53, 4, 6, 6, -5,
+	-17, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 17, 52, 51, 38, 39, 26, 22, 24, 20,
+	-5, -5, -5, -5, -5, -7, 27, -5, -11, 29,
+	-12, 4, 26, 28, -5, 25, 24, 24, 44, -4,
+	28, -14, 21, 4, 4, 28, 48, 47, -17, -5,
 	-5, -5, -5, -5, -5, -5, -5, -5, -5, -5,
-	-5, 4, -8, -5, 26, 19, 28, 19, 21, 24,
-	-5, -9, 4, -5, 26, 24, 19, -5, 4, -5,
-	24, 24, 19, -12, -5, 21, -4, 27, -4, 4,
-	-5, 33, -2, 28, -4, 28,
+	-5, -5, -5, -5, -5, 4, -8, -5, 27, 19,
+	29, 19, 21, -5, -7, 29, 25, 19, -5, -9,
+	4, -4, 44, 43, -2, 29, 18, 4, 35, 31,
+	-14, 28, -16, -15, 4, 4, -10, 4, 27, 25,
+	19, -5, 4, 26, -5, 27, 29, 25, -7, 25,
+	25, 19, -14, -4, 24, -4, 29, -5, 18, -16,
+	29, 19, 24, -17, -17, 19, -5, 21, -5, 21,
+	25, -4, -14, 4, 4, -17, -5, 29, -17, -15,
+	-9, 4, -5, 27, -5, 34, -4, -14, 25, -17,
+	-17, 25, 21, -4, -4, -5,
 }
 
 var yyDef = [...]int8{
-	-2, -2, -2, 3, 0, 0, 10, 8, 13, 0,
-	0, 32, 33, 34, 35, 36, 37, 0, 0, 0,
-	0, 0, 4, 0, 10, 7, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 50, 9, 27, 28, 0, 39, 46, 0, 41,
-	54, 0, 0, 0, 53, 0, 6, 14, 15, 16,
-	17, 18, 19, 20, 21, 22, 23, 24, 25, 26,
-	0, 30, 0, 48, 38, 0, 40, 0, 0, 42,
-	0, 0, 51, 10, 29, 31, 0, 47, 0, 55,
-	0, 0, 0, 5, 49, 0, 43, 0, 45, 52,
-	56, 0, 0, 12, 44, 11,
+	-2, -2, -2, 3, 0, 0, 0, 0, 0, 0,
+	15, 13, 18, 0, 0, 0, 0, 0, 42, 43,
+	44, 45, 46, 47, 0, 0, 0, 0, 0, 0,
+	61, 0, 4, 81, 0, 0, 0, 0, 0, 15,
+	12, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 70, 14,
+	34, 35, 39, 40, 41, 0, 49, 66, 0, 51,
+	84, 0, 0, 0, 0, 55, 0, 73, 0, 0,
+	0, 0, 0, 81, 0, 0, 0, 0, 11, 19,
+	20, 21, 22, 23, 24, 25, 26, 27, 28, 29,
+	30, 31, 32, 33, 0, 37, 0, 68, 48, 0,
+	50, 0, 0, 0, 0, 53, 54, 0, 0, 0,
+	81, 62, 0, 0, 0, 17, 0, 78, 79, 80,
+	0, 0, 0, 74, 76, 15, 15, 82, 36, 38,
+	0, 67, 0, 0, 85, 0, 52, 56, 0, 0,
+	81, 0, 71, 63, 0, 65, 16, 15, 0, 0,
+	15, 0, 73, 9, 10, 0, 69, 0, 0, 0,
+	57, 58, 0, 81, 0, 5, 15, 15, 7, 75,
+	0, 83, 87, 0, 86, 0, 60, 72, 0, 6,
+	8, 77, 0, 59, 64, 88,
 }
 
 var yyTok1 = [...]int8{
@@ -356,6 +492,8 @@ var yyTok2 = [...]int8{
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
 	32, 33, 34, 35, 36, 37, 38, 39, 40, 41,
+	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
+	52, 53, 54, 55,
 }
 
 var yyTok3 = [...]int8{
@@ -701,21 +839,21 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:65
+//line pingul.y:75
 		{
 			yyVAL.program = &ast.Program{Statements: yyDollar[1].statements}
 			yylex.(*YaccLexer).program = yyVAL.program
 		}
 	case 2:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line pingul.y:70
+//line pingul.y:80
 		{
 			yyVAL.program = &ast.Program{Statements: []ast.Statement{}}
 			yylex.(*YaccLexer).program = yyVAL.program
 		}
 	case 3:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:78
+//line pingul.y:88
 		{
 			if yyDollar[1].statement != nil {
 				yyVAL.statements = []ast.Statement{yyDollar[1].statement}
@@ -725,7 +863,7 @@ yydefault:
 		}
 	case 4:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:86
+//line pingul.y:96
 		{
 			if yyDollar[2].statement != nil {
 				yyVAL.statements = append(yyDollar[1].statements, yyDollar[2].statement)
@@ -734,30 +872,96 @@ yydefault:
 			}
 		}
 	case 5:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line pingul.y:97
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line pingul.y:107
 		{
 			yyVAL.statement = &ast.VarStatement{
 				Token: yyDollar[1].token,
 				Name: &ast.Identifier{
 					Token: yyDollar[2].token,
 					Value: yyDollar[2].token.Literal,
+					Type:  yyDollar[3].typeAnnotation,
 				},
-				Value: yyDollar[4].expression,
+				Value: nameFunc(yyDollar[5].expression, string(yyDollar[2].token.Literal)),
 			}
 		}
 	case 6:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line pingul.y:119
+		{
+			yyVAL.statement = &ast.VarStatement{
+				Token: yyDollar[2].token,
+				Name: &ast.Identifier{
+					Token: yyDollar[3].token,
+					Value: yyDollar[3].token.Literal,
+					Type:  yyDollar[4].typeAnnotation,
+				},
+				Value:    nameFunc(yyDollar[6].expression, string(yyDollar[3].token.Literal)),
+				Exported: true,
+			}
+		}
+	case 7:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line pingul.y:132
+		{
+			yyVAL.statement = &ast.EnumStatement{
+				Token: yyDollar[1].token,
+				Name: &ast.Identifier{
+					Token: yyDollar[2].token,
+					Value: yyDollar[2].token.Literal,
+				},
+				Variants: yyDollar[4].variants,
+			}
+		}
+	case 8:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line pingul.y:143
+		{
+			yyVAL.statement = &ast.EnumStatement{
+				Token: yyDollar[2].token,
+				Name: &ast.Identifier{
+					Token: yyDollar[3].token,
+					Value: yyDollar[3].token.Literal,
+				},
+				Variants: yyDollar[5].variants,
+				Exported: true,
+			}
+		}
+	case 9:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line pingul.y:155
+		{
+			yyVAL.statement = &ast.ImportStatement{
+				Token: yyDollar[1].token,
+				Path:  string(yyDollar[2].token.Literal),
+				Alias: &ast.Identifier{
+					Token: yyDollar[4].token,
+					Value: yyDollar[4].token.Literal,
+				},
+			}
+		}
+	case 10:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line pingul.y:166
+		{
+			yyVAL.statement = &ast.ImportStatement{
+				Token: yyDollar[1].token,
+				Path:  string(yyDollar[2].token.Literal),
+				Names: yyDollar[4].identifiers,
+			}
+		}
+	case 11:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:108
+//line pingul.y:174
 		{
 			yyVAL.statement = &ast.ReturnStatement{
 				Token:       yyDollar[1].token,
 				ReturnValue: yyDollar[2].expression,
 			}
 		}
-	case 7:
+	case 12:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:115
+//line pingul.y:181
 		{
 			stmt := &ast.ExpressionStatement{Expression: yyDollar[1].expression}
 			if expr, ok := yyDollar[1].expression.(*ast.Identifier); ok {
@@ -783,34 +987,34 @@ yydefault:
 			}
 			yyVAL.statement = stmt
 		}
-	case 8:
+	case 13:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:141
+//line pingul.y:207
 		{
 			// Let yacc's default error handling record the error
 			yyVAL.statement = nil
 		}
-	case 11:
+	case 16:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:154
+//line pingul.y:220
 		{
 			yyVAL.blockStatement = &ast.BlockStatement{
 				Token:      yyDollar[1].token,
 				Statements: yyDollar[2].statements,
 			}
 		}
-	case 12:
+	case 17:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:161
+//line pingul.y:227
 		{
 			yyVAL.blockStatement = &ast.BlockStatement{
 				Token:      yyDollar[1].token,
 				Statements: []ast.Statement{},
 			}
 		}
-	case 14:
+	case 19:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:172
+//line pingul.y:238
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -819,9 +1023,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 15:
+	case 20:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:181
+//line pingul.y:247
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -830,9 +1034,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 16:
+	case 21:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:190
+//line pingul.y:256
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -841,9 +1045,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 17:
+	case 22:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:199
+//line pingul.y:265
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -852,9 +1056,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 18:
+	case 23:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:208
+//line pingul.y:274
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -863,9 +1067,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 19:
+	case 24:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:217
+//line pingul.y:283
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -874,9 +1078,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 20:
+	case 25:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:226
+//line pingul.y:292
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -885,9 +1089,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 21:
+	case 26:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:235
+//line pingul.y:301
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -896,9 +1100,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 22:
+	case 27:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:244
+//line pingul.y:310
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -907,9 +1111,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 23:
+	case 28:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:253
+//line pingul.y:319
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -918,9 +1122,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 24:
+	case 29:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:262
+//line pingul.y:328
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -929,9 +1133,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 25:
+	case 30:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:271
+//line pingul.y:337
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -940,9 +1144,9 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 26:
+	case 31:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:280
+//line pingul.y:346
 		{
 			yyVAL.expression = &ast.InfixExpression{
 				Token:    yyDollar[2].token,
@@ -951,9 +1155,31 @@ yydefault:
 				Right:    yyDollar[3].expression,
 			}
 		}
-	case 27:
+	case 32:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:355
+		{
+			yyVAL.expression = &ast.InfixExpression{
+				Token:    yyDollar[2].token,
+				Left:     yyDollar[1].expression,
+				Operator: string(yyDollar[2].token.Literal),
+				Right:    yyDollar[3].expression,
+			}
+		}
+	case 33:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:364
+		{
+			yyVAL.expression = &ast.InfixExpression{
+				Token:    yyDollar[2].token,
+				Left:     yyDollar[1].expression,
+				Operator: string(yyDollar[2].token.Literal),
+				Right:    yyDollar[3].expression,
+			}
+		}
+	case 34:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:289
+//line pingul.y:373
 		{
 			yyVAL.expression = &ast.PrefixExpression{
 				Token:    yyDollar[1].token,
@@ -961,9 +1187,9 @@ yydefault:
 				Right:    yyDollar[2].expression,
 			}
 		}
-	case 28:
+	case 35:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:297
+//line pingul.y:381
 		{
 			yyVAL.expression = &ast.PrefixExpression{
 				Token:    yyDollar[1].token,
@@ -971,9 +1197,9 @@ yydefault:
 				Right:    yyDollar[2].expression,
 			}
 		}
-	case 29:
+	case 36:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line pingul.y:305
+//line pingul.y:389
 		{
 			yyVAL.expression = &ast.IndexExpression{
 				Token: yyDollar[2].token,
@@ -981,9 +1207,9 @@ yydefault:
 				Index: yyDollar[3].expression,
 			}
 		}
-	case 30:
+	case 37:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:313
+//line pingul.y:397
 		{
 			yyVAL.expression = &ast.PropertyAccess{
 				Token:    yyDollar[2].token,
@@ -991,9 +1217,9 @@ yydefault:
 				Property: string(yyDollar[3].token.Literal),
 			}
 		}
-	case 31:
+	case 38:
 		yyDollar = yyS[yypt-4 : yypt+1]
-//line pingul.y:321
+//line pingul.y:405
 		{
 			yyVAL.expression = &ast.CallExpression{
 				Token:     yyDollar[2].token,
@@ -1001,18 +1227,50 @@ yydefault:
 				Arguments: yyDollar[3].expressions,
 			}
 		}
-	case 32:
+	case 39:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:413
+		{
+			yyVAL.expression = &ast.ThrowExpression{
+				Token: yyDollar[1].token,
+				Value: yyDollar[2].expression,
+			}
+		}
+	case 40:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:420
+		{
+			yyVAL.expression = &ast.YieldExpression{
+				Token: yyDollar[1].token,
+				Value: yyDollar[2].expression,
+			}
+		}
+	case 41:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:427
+		{
+			call, ok := yyDollar[2].expression.(*ast.CallExpression)
+			if !ok {
+				yylex.Error("spawn expects a function call")
+			}
+
+			yyVAL.expression = &ast.SpawnExpression{
+				Token: yyDollar[1].token,
+				Call:  call,
+			}
+		}
+	case 42:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:332
+//line pingul.y:442
 		{
 			yyVAL.expression = &ast.Identifier{
 				Token: yyDollar[1].token,
 				Value: yyDollar[1].token.Literal,
 			}
 		}
-	case 33:
+	case 43:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:339
+//line pingul.y:449
 		{
 			val, _ := strconv.ParseInt(string(yyDollar[1].token.Literal), 0, 64)
 			yyVAL.expression = &ast.IntegerLiteral{
@@ -1020,84 +1278,127 @@ yydefault:
 				Value: val,
 			}
 		}
-	case 34:
+	case 44:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:347
+//line pingul.y:457
 		{
 			yyVAL.expression = &ast.String{
 				Token: yyDollar[1].token,
 				Value: yyDollar[1].token.Literal,
 			}
 		}
-	case 35:
+	case 45:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:354
+//line pingul.y:464
 		{
 			yyVAL.expression = &ast.Boolean{
 				Token: yyDollar[1].token,
 				Value: true,
 			}
 		}
-	case 36:
+	case 46:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:361
+//line pingul.y:471
 		{
 			yyVAL.expression = &ast.Boolean{
 				Token: yyDollar[1].token,
 				Value: false,
 			}
 		}
-	case 37:
+	case 47:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:368
+//line pingul.y:478
 		{
 			yyVAL.expression = &ast.Nil{Token: yyDollar[1].token}
 		}
-	case 38:
+	case 48:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:372
+//line pingul.y:482
 		{
 			yyVAL.expression = &ast.List{
 				Token: yyDollar[1].token,
 				Items: yyDollar[2].expressions,
 			}
 		}
-	case 39:
+	case 49:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:379
+//line pingul.y:489
 		{
 			yyVAL.expression = &ast.List{
 				Token: yyDollar[1].token,
 				Items: []ast.Expression{},
 			}
 		}
-	case 40:
+	case 50:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:386
+//line pingul.y:496
 		{
-			yyVAL.expression = &ast.ObjectLiteral{
-				Token: yyDollar[1].token,
-				Pairs: yyDollar[2].objPairs,
-			}
+			yyDollar[2].objectLiteral.Token = yyDollar[1].token
+			yyVAL.expression = yyDollar[2].objectLiteral
 		}
-	case 41:
+	case 51:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line pingul.y:393
+//line pingul.y:501
 		{
 			yyVAL.expression = &ast.ObjectLiteral{
 				Token: yyDollar[1].token,
 				Pairs: make(map[string]ast.Expression),
 			}
 		}
-	case 42:
+	case 52:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:508
+		{
+			yyVAL.expression = &ast.Set{
+				Token: yyDollar[1].token,
+				Items: yyDollar[3].expressions,
+			}
+		}
+	case 53:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:400
+//line pingul.y:515
+		{
+			yyVAL.expression = &ast.Set{
+				Token: yyDollar[1].token,
+				Items: []ast.Expression{},
+			}
+		}
+	case 54:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:522
 		{
 			yyVAL.expression = yyDollar[2].expression
 		}
-	case 43:
+	case 55:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:526
+		{
+			yyVAL.expression = &ast.Tuple{
+				Token: yyDollar[1].token,
+				Items: []ast.Expression{},
+			}
+		}
+	case 56:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:533
+		{
+			yyVAL.expression = &ast.Tuple{
+				Token: yyDollar[1].token,
+				Items: []ast.Expression{yyDollar[2].expression},
+			}
+		}
+	case 57:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line pingul.y:404
+//line pingul.y:540
+		{
+			yyVAL.expression = &ast.Tuple{
+				Token: yyDollar[1].token,
+				Items: append([]ast.Expression{yyDollar[2].expression}, yyDollar[4].expressions...),
+			}
+		}
+	case 58:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line pingul.y:547
 		{
 			yyVAL.expression = &ast.IfExpression{
 				Token:       yyDollar[1].token,
@@ -1105,9 +1406,9 @@ yydefault:
 				Consequence: yyDollar[5].blockStatement,
 			}
 		}
-	case 44:
+	case 59:
 		yyDollar = yyS[yypt-7 : yypt+1]
-//line pingul.y:412
+//line pingul.y:555
 		{
 			yyVAL.expression = &ast.IfExpression{
 				Token:       yyDollar[1].token,
@@ -1116,91 +1417,241 @@ yydefault:
 				Alternative: yyDollar[7].blockStatement,
 			}
 		}
-	case 45:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line pingul.y:421
+	case 60:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line pingul.y:564
 		{
+			isGenerator := containsYield(yyDollar[6].blockStatement)
+			if !isGenerator {
+				markTailCalls(yyDollar[6].blockStatement, true)
+			}
+
 			yyVAL.expression = &ast.FuncExpression{
-				Token:  yyDollar[1].token,
-				Params: yyDollar[3].identifiers,
-				Body:   yyDollar[5].blockStatement,
+				Token:       yyDollar[1].token,
+				Params:      yyDollar[3].identifiers,
+				Body:        yyDollar[6].blockStatement,
+				ReturnType:  yyDollar[5].typeAnnotation,
+				IsGenerator: isGenerator,
 			}
 		}
-	case 46:
+	case 61:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line pingul.y:579
+		{
+			yyVAL.expression = yyDollar[1].tryExpression
+		}
+	case 62:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:583
+		{
+			yyDollar[1].tryExpression.Finally = yyDollar[3].blockStatement
+			yyVAL.expression = yyDollar[1].tryExpression
+		}
+	case 63:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:588
+		{
+			yyVAL.expression = &ast.TryExpression{
+				Token:   yyDollar[1].token,
+				Block:   yyDollar[2].blockStatement,
+				Finally: yyDollar[4].blockStatement,
+			}
+		}
+	case 64:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line pingul.y:599
+		{
+			yyVAL.tryExpression = &ast.TryExpression{
+				Token: yyDollar[1].token,
+				Block: yyDollar[2].blockStatement,
+				CatchParam: &ast.Identifier{
+					Token: yyDollar[5].token,
+					Value: yyDollar[5].token.Literal,
+				},
+				Catch: yyDollar[7].blockStatement,
+			}
+		}
+	case 65:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:611
+		{
+			yyVAL.tryExpression = &ast.TryExpression{
+				Token: yyDollar[1].token,
+				Block: yyDollar[2].blockStatement,
+				Catch: yyDollar[4].blockStatement,
+			}
+		}
+	case 66:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:432
+//line pingul.y:622
 		{
 			yyVAL.expressions = []ast.Expression{yyDollar[1].expression}
 		}
-	case 47:
+	case 67:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:436
+//line pingul.y:626
 		{
 			yyVAL.expressions = append(yyDollar[1].expressions, yyDollar[3].expression)
 		}
-	case 48:
+	case 68:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:443
+//line pingul.y:633
 		{
 			yyVAL.expressions = []ast.Expression{yyDollar[1].expression}
 		}
-	case 49:
+	case 69:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:447
+//line pingul.y:637
 		{
 			yyVAL.expressions = append(yyDollar[1].expressions, yyDollar[3].expression)
 		}
-	case 50:
+	case 70:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line pingul.y:451
+//line pingul.y:641
 		{
 			yyVAL.expressions = []ast.Expression{}
 		}
-	case 51:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:458
+	case 71:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:648
 		{
 			yyVAL.identifiers = []*ast.Identifier{
 				{
 					Token: yyDollar[1].token,
 					Value: yyDollar[1].token.Literal,
+					Type:  yyDollar[2].typeAnnotation,
 				},
 			}
 		}
-	case 52:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:467
+	case 72:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:658
 		{
 			yyVAL.identifiers = append(yyDollar[1].identifiers, &ast.Identifier{
 				Token: yyDollar[3].token,
 				Value: yyDollar[3].token.Literal,
+				Type:  yyDollar[4].typeAnnotation,
 			})
 		}
-	case 53:
+	case 73:
 		yyDollar = yyS[yypt-0 : yypt+1]
-//line pingul.y:474
+//line pingul.y:666
 		{
 			yyVAL.identifiers = []*ast.Identifier{}
 		}
-	case 54:
+	case 74:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line pingul.y:481
+//line pingul.y:673
 		{
-			yyVAL.objPairs = yyDollar[1].objPairs
+			yyVAL.variants = []*ast.EnumVariant{yyDollar[1].variant}
 		}
-	case 55:
+	case 75:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line pingul.y:488
+//line pingul.y:677
 		{
-			yyVAL.objPairs = make(map[string]ast.Expression)
-			yyVAL.objPairs[string(yyDollar[1].token.Literal)] = yyDollar[3].expression
+			yyVAL.variants = append(yyDollar[1].variants, yyDollar[3].variant)
 		}
-	case 56:
+	case 76:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line pingul.y:684
+		{
+			yyVAL.variant = &ast.EnumVariant{
+				Name: &ast.Identifier{
+					Token: yyDollar[1].token,
+					Value: yyDollar[1].token.Literal,
+				},
+				Fields: []*ast.Identifier{},
+			}
+		}
+	case 77:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line pingul.y:694
+		{
+			yyVAL.variant = &ast.EnumVariant{
+				Name: &ast.Identifier{
+					Token: yyDollar[1].token,
+					Value: yyDollar[1].token.Literal,
+				},
+				Fields: yyDollar[3].identifiers,
+			}
+		}
+	case 78:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:708
+		{
+			yyVAL.typeAnnotation = &ast.TypeAnnotation{Token: yyDollar[2].token, Name: string(yyDollar[2].token.Literal)}
+		}
+	case 79:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:712
+		{
+			yyVAL.typeAnnotation = &ast.TypeAnnotation{Token: yyDollar[2].token, Name: string(yyDollar[2].token.Literal)}
+		}
+	case 80:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line pingul.y:716
+		{
+			yyVAL.typeAnnotation = &ast.TypeAnnotation{Token: yyDollar[2].token, Name: string(yyDollar[2].token.Literal)}
+		}
+	case 81:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line pingul.y:720
+		{
+			yyVAL.typeAnnotation = nil
+		}
+	case 82:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line pingul.y:727
+		{
+			yyVAL.identifiers = []*ast.Identifier{
+				{
+					Token: yyDollar[1].token,
+					Value: yyDollar[1].token.Literal,
+				},
+			}
+		}
+	case 83:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:736
+		{
+			yyVAL.identifiers = append(yyDollar[1].identifiers, &ast.Identifier{
+				Token: yyDollar[3].token,
+				Value: yyDollar[3].token.Literal,
+			})
+		}
+	case 84:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line pingul.y:746
+		{
+			yyVAL.objectLiteral = yyDollar[1].objectLiteral
+		}
+	case 85:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line pingul.y:753
+		{
+			yyVAL.objectLiteral = &ast.ObjectLiteral{Pairs: make(map[string]ast.Expression)}
+			yyVAL.objectLiteral.Pairs[string(yyDollar[1].token.Literal)] = nameFunc(yyDollar[3].expression, string(yyDollar[1].token.Literal))
+		}
+	case 86:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line pingul.y:758
+		{
+			yyVAL.objectLiteral = &ast.ObjectLiteral{Pairs: make(map[string]ast.Expression)}
+			yyVAL.objectLiteral.ComputedPairs = append(yyVAL.objectLiteral.ComputedPairs, &ast.ComputedPair{Key: yyDollar[2].expression, Value: yyDollar[5].expression})
+		}
+	case 87:
 		yyDollar = yyS[yypt-5 : yypt+1]
-//line pingul.y:493
+//line pingul.y:763
+		{
+			yyDollar[1].objectLiteral.Pairs[string(yyDollar[3].token.Literal)] = nameFunc(yyDollar[5].expression, string(yyDollar[3].token.Literal))
+			yyVAL.objectLiteral = yyDollar[1].objectLiteral
+		}
+	case 88:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line pingul.y:768
 		{
-			yyDollar[1].objPairs[string(yyDollar[3].token.Literal)] = yyDollar[5].expression
-			yyVAL.objPairs = yyDollar[1].objPairs
+			yyDollar[1].objectLiteral.ComputedPairs = append(yyDollar[1].objectLiteral.ComputedPairs, &ast.ComputedPair{Key: yyDollar[4].expression, Value: yyDollar[7].expression})
+			yyVAL.objectLiteral = yyDollar[1].objectLiteral
 		}
 	}
 	goto yystack /* stack new state and value */
@@ -9,6 +9,7 @@ import (
 	"github.com/aziflaj/pingul/lexer"
 	"github.com/aziflaj/pingul/object"
 	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
 )
 
 const PROMPT = "(pingul)>> "
@@ -17,10 +18,19 @@ func Start(in io.Reader, out io.Writer) {
 	fmt.Printf("Welcome to the PinguL REPL!\n")
 	fmt.Printf("Type 'exit' to quit the REPL.\n")
 
+	caps, err := object.Everything(".")
+	if err != nil {
+		fmt.Fprintf(out, "Error opening the current directory: %s\n", err)
+		return
+	}
+	// print shows up in the REPL, wherever that is
+	caps.Stdout = out
+
+	interpreter := eval.NewWithCapabilities(caps)
 	globalScope := object.NewScope()
 
 	for {
-		fmt.Fprintf(out, PROMPT)
+		fmt.Fprint(out, PROMPT)
 
 		scanner := bufio.NewScanner(in)
 		if !scanner.Scan() {
@@ -49,10 +59,24 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		result := eval.Eval(globalScope, program)
+		r := resolver.New()
+		r.Declare(globalScope.Names()...)
+		if errors := r.Resolve(program); len(errors) != 0 {
+			printParserErrors(out, errors)
+			continue
+		}
+		for _, msg := range r.Warnings() {
+			fmt.Fprintf(out, "\twarning: %s\n", msg)
+		}
+
+		result := interpreter.Eval(globalScope, program)
 
-		fmt.Fprintf(out, result.Inspect())
-		fmt.Fprintf(out, "\n\n")
+		fmt.Fprint(out, result.Inspect())
+		fmt.Fprint(out, "\n")
+		if err, ok := result.(*object.Error); ok {
+			fmt.Fprint(out, err.Traceback())
+		}
+		fmt.Fprint(out, "\n")
 	}
 }
 
@@ -0,0 +1,384 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/token"
+)
+
+// Resolver walks the AST before it's evaluated and reports
+// the names that are obviously undefined, i.e. never declared
+// in any of the scopes visible from where they are used.
+// Along the way it finds out where every variable is going to
+// live, so the evaluator doesn't have to look names up, see
+// ast.Binding
+type Resolver struct {
+	// innermost scope is the last one
+	scopes   []*scope
+	errors   []string
+	warnings []string
+
+	// how many functions deep the resolver is
+	function int
+}
+
+type scope struct {
+	// the slot of every name declared in the scope, in the
+	// order they're declared in, which is the order of names
+	slots map[string]int
+	names []string
+
+	// the names whose declaration has been reached,
+	// the others can only be used by functions
+	declared map[string]bool
+
+	// how many functions deep the scope is
+	function int
+}
+
+func New() *Resolver {
+	r := &Resolver{
+		errors:   []string{},
+		warnings: []string{},
+	}
+	r.beginScope()
+
+	return r
+}
+
+// Declare marks names as already defined in the global scope,
+// e.g. the variables a REPL session has already created
+func (r *Resolver) Declare(names ...string) {
+	for _, name := range names {
+		r.scopes[0].declare(name)
+		r.scopes[0].declared[name] = true
+	}
+}
+
+// Errors returns the diagnostics found by Resolve
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+// Warnings returns what Resolve found suspicious, but not wrong
+func (r *Resolver) Warnings() []string {
+	return r.warnings
+}
+
+// Resolve checks the whole program, returning the diagnostics
+func (r *Resolver) Resolve(program *ast.Program) []string {
+	r.declareAll(program.Statements)
+
+	for _, stmt := range program.Statements {
+		r.resolve(stmt)
+	}
+
+	return r.errors
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		r.beginScope()
+		r.declareAll(node.Statements)
+		r.resolveStatements(node.Statements)
+		node.Slots = r.endScope()
+
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+
+	case *ast.VarStatement:
+		if node.Exported && len(r.scopes) > 1 {
+			r.errorf(node.Name.Token, "cannot export '%s' from inside a block, only top-level names can be exported", node.Name)
+		}
+		r.resolve(node.Value)
+		r.define(node.Name)
+
+	case *ast.EnumStatement:
+		if node.Exported && len(r.scopes) > 1 {
+			r.errorf(node.Name.Token, "cannot export '%s' from inside a block, only top-level names can be exported", node.Name)
+		}
+		r.checkVariants(node)
+		r.define(node.Name)
+
+	case *ast.ImportStatement:
+		if node.Alias != nil {
+			r.define(node.Alias)
+		}
+		for _, name := range node.Names {
+			r.define(name)
+		}
+
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+
+	case *ast.Identifier:
+		r.resolveIdentifier(node)
+
+	case *ast.List:
+		r.resolveExpressions(node.Items)
+
+	case *ast.Tuple:
+		r.resolveExpressions(node.Items)
+
+	case *ast.Set:
+		r.resolveExpressions(node.Items)
+
+	case *ast.ObjectLiteral:
+		for _, value := range node.Pairs {
+			r.resolve(value)
+		}
+		for _, pair := range node.ComputedPairs {
+			r.resolve(pair.Key)
+			r.resolve(pair.Value)
+		}
+
+	case *ast.PropertyAccess:
+		r.resolve(node.Object)
+
+	case *ast.IndexExpression:
+		r.resolve(node.List)
+		r.resolve(node.Index)
+
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+
+	case *ast.FuncExpression:
+		// params and the function body share the same scope,
+		// where self and super are there when called as a method
+		r.function++
+		r.beginScope()
+		r.declare("self", "super")
+		r.defined("self", "super")
+		for _, param := range node.Params {
+			r.declare(param.String())
+			r.define(param)
+		}
+		r.declareAll(node.Body.Statements)
+		r.resolveStatements(node.Body.Statements)
+		node.Body.Slots = r.endScope()
+		r.function--
+
+	case *ast.ThrowExpression:
+		r.resolve(node.Value)
+
+	case *ast.YieldExpression:
+		r.resolve(node.Value)
+
+	case *ast.SpawnExpression:
+		if node.Call != nil {
+			r.resolve(node.Call)
+		}
+
+	case *ast.TryExpression:
+		r.resolve(node.Block)
+
+		if node.Catch != nil {
+			// the caught value is only visible inside the catch block
+			r.beginScope()
+			if node.CatchParam != nil {
+				r.declare(node.CatchParam.String())
+				r.define(node.CatchParam)
+			}
+			r.declareAll(node.Catch.Statements)
+			r.resolveStatements(node.Catch.Statements)
+			node.Catch.Slots = r.endScope()
+		}
+
+		if node.Finally != nil {
+			r.resolve(node.Finally)
+		}
+
+	case *ast.CallExpression:
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	}
+}
+
+func (r *Resolver) resolveStatements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		r.resolve(stmt)
+	}
+}
+
+func (r *Resolver) resolveExpressions(expressions []ast.Expression) {
+	for _, expr := range expressions {
+		r.resolve(expr)
+	}
+}
+
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier) {
+	name := ident.String()
+
+	if _, ok := object.IntrinsicFuncs[name]; ok {
+		ident.Binding = ast.Binding{Kind: ast.Intrinsic}
+		return
+	}
+
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		scope := r.scopes[i]
+		slot, ok := scope.slots[name]
+		if !ok {
+			continue
+		}
+
+		// functions can use what's declared after them, as long as
+		// they're called later, but the rest of the code runs in order
+		if !scope.declared[name] && scope.function == r.function {
+			r.warnf(ident.Token, "variable '%s' is used before it's declared", name)
+		}
+
+		depth := len(r.scopes) - 1 - i
+		if i == 0 {
+			ident.Binding = ast.Binding{Kind: ast.Global, Depth: depth}
+		} else {
+			ident.Binding = ast.Binding{Kind: ast.Local, Depth: depth, Slot: slot}
+		}
+		return
+	}
+
+	if suggestion := object.Suggest(name, r.visibleNames()); suggestion != "" {
+		r.errorf(ident.Token, "undefined variable '%s' (did you mean '%s'?)", name, suggestion)
+		return
+	}
+
+	r.errorf(ident.Token, "undefined variable '%s'", name)
+}
+
+// functions can refer to names declared after them, e.g. for mutual
+// recursion, so every declaration of a scope is visible in all of it
+func (r *Resolver) declareAll(statements []ast.Statement) {
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.VarStatement:
+			r.declare(stmt.Name.String())
+
+		case *ast.EnumStatement:
+			r.declare(stmt.Name.String())
+
+		case *ast.ImportStatement:
+			if stmt.Alias != nil {
+				r.declare(stmt.Alias.String())
+			}
+			for _, name := range stmt.Names {
+				r.declare(name.String())
+			}
+		}
+	}
+}
+
+// checkVariants reports variants and fields declared twice
+func (r *Resolver) checkVariants(node *ast.EnumStatement) {
+	variants := make(map[string]bool)
+
+	for _, variant := range node.Variants {
+		if variants[variant.Name.String()] {
+			r.errorf(variant.Name.Token, "enum %s declares variant '%s' twice", node.Name, variant.Name)
+		}
+		variants[variant.Name.String()] = true
+
+		fields := make(map[string]bool)
+		for _, field := range variant.Fields {
+			if fields[field.String()] {
+				r.errorf(field.Token, "variant %s.%s declares field '%s' twice", node.Name, variant.Name, field)
+			}
+			fields[field.String()] = true
+		}
+	}
+}
+
+func (r *Resolver) declare(names ...string) {
+	for _, name := range names {
+		r.scopes[len(r.scopes)-1].declare(name)
+	}
+}
+
+// define is where the declaration of the name is reached. Its binding
+// is where the evaluator puts the value, in the innermost scope
+func (r *Resolver) define(ident *ast.Identifier) {
+	name := ident.String()
+
+	if _, ok := object.IntrinsicFuncs[name]; ok {
+		r.warnf(ident.Token, "variable '%s' can't be used, '%s' is the name of an intrinsic function", name, name)
+	}
+
+	r.defined(name)
+
+	scope := r.scopes[len(r.scopes)-1]
+	if len(r.scopes) == 1 {
+		ident.Binding = ast.Binding{Kind: ast.Global}
+	} else {
+		ident.Binding = ast.Binding{Kind: ast.Local, Slot: scope.slots[name]}
+	}
+}
+
+func (r *Resolver) defined(names ...string) {
+	for _, name := range names {
+		r.scopes[len(r.scopes)-1].declared[name] = true
+	}
+}
+
+func (s *scope) declare(name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(s.names)
+		s.names = append(s.names, name)
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, &scope{
+		slots:    make(map[string]int),
+		declared: make(map[string]bool),
+		function: r.function,
+	})
+}
+
+// endScope returns the names declared in the scope, in slot order
+func (r *Resolver) endScope() []string {
+	scope := r.scopes[len(r.scopes)-1]
+	r.scopes = r.scopes[:len(r.scopes)-1]
+
+	return scope.names
+}
+
+func (r *Resolver) visibleNames() []string {
+	var names []string
+
+	for _, scope := range r.scopes {
+		names = append(names, scope.names...)
+	}
+
+	for name := range object.IntrinsicFuncs {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// diagnostics start with the line and column of the token they're about
+func (r *Resolver) errorf(at token.Token, format string, args ...any) {
+	r.errors = append(r.errors, position(at)+fmt.Sprintf(format, args...))
+}
+
+func (r *Resolver) warnf(at token.Token, format string, args ...any) {
+	r.warnings = append(r.warnings, position(at)+fmt.Sprintf(format, args...))
+}
+
+func position(at token.Token) string {
+	return fmt.Sprintf("%d:%d: ", at.Line, at.Column)
+}
@@ -0,0 +1,200 @@
+package resolver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/lexer"
+	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/resolver"
+)
+
+func TestResolveDefinedNames(t *testing.T) {
+	testCases := []string{
+		"var x = 1; x + 1;",
+		"len([1, 2, 3]);",
+		"var f = func(a, b) { a + b }; f(1, 2);",
+		// recursion
+		"var fib = func(n) { if (n <= 1) { n } else { fib(n - 1) + fib(n - 2) } };",
+		// functions can refer to names declared after them
+		"var isEven = func(n) { if (n == 0) { true } else { isOdd(n - 1) } }; var isOdd = func(n) { not isEven(n) };",
+		// closures over the params of the enclosing function
+		"var adder = func(x) { func(y) { x + y } };",
+		"var x = 1; if (x) { var y = x; y; }",
+		"var obj = { key: 1 }; obj.key; obj.missing;",
+		"try { throw 1 } catch (e) { var msg = e; msg } finally { 2 }",
+		`import "lib.pl" as lib; lib.thing;`,
+		`from "lib.pl" import first, second; first(second);`,
+		"export var x = 1; x;",
+		"var obj = { x: 1, getX: func() { self.x } };",
+		"enum Color { Red, Rgb(r, g, b) }; Color.Rgb(1, 2, 3);",
+		"var paint = func() { Color.Red }; enum Color { Red };",
+	}
+
+	for _, input := range testCases {
+		errors := resolve(t, input)
+		if len(errors) != 0 {
+			t.Fatalf("Expected no errors for %q. Got=%v", input, errors)
+		}
+	}
+}
+
+func TestResolveUndefinedNames(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"foo;", []string{"1:1: undefined variable 'foo'"}},
+		{"if (true) { export var x = 1; }", []string{"1:24: cannot export 'x' from inside a block, only top-level names can be exported"}},
+		{"if (true) { export enum E { A } }", []string{"1:25: cannot export 'E' from inside a block, only top-level names can be exported"}},
+		{"enum E { A, B(x, x), A }", []string{"1:18: variant E.B declares field 'x' twice", "1:22: enum E declares variant 'A' twice"}},
+		{"var count = 1; cuont;", []string{"1:16: undefined variable 'cuont' (did you mean 'count'?)"}},
+		{"prnt(1);", []string{"1:1: undefined variable 'prnt' (did you mean 'print'?)"}},
+		{"var f = func(total) { totl }; f(1);", []string{"1:23: undefined variable 'totl' (did you mean 'total'?)"}},
+		// block declarations don't leak
+		{"if (true) { var inner = 1; } inner;", []string{"1:30: undefined variable 'inner'"}},
+		// params don't leak either
+		{"var f = func(param) { param }; param;", []string{"1:32: undefined variable 'param'"}},
+		// the caught value is only visible inside the catch block
+		{"try { 1 } catch (e) { e }; e;", []string{"1:28: undefined variable 'e'"}},
+		{"try { throw a } finally { b }", []string{"1:13: undefined variable 'a'", "1:27: undefined variable 'b'"}},
+		{"[a, { key: b }, c[d], e.f, -g, h + i];", []string{
+			"1:2: undefined variable 'a'",
+			"1:12: undefined variable 'b'",
+			"1:17: undefined variable 'c'",
+			"1:19: undefined variable 'd'",
+			"1:23: undefined variable 'e'",
+			"1:29: undefined variable 'g'",
+			"1:32: undefined variable 'h'",
+			"1:36: undefined variable 'i'",
+		}},
+	}
+
+	for _, tc := range testCases {
+		errors := resolve(t, tc.input)
+
+		if len(errors) != len(tc.expected) {
+			t.Fatalf("Wrong number of errors for %q. Got=%v, Expected=%v", tc.input, errors, tc.expected)
+		}
+
+		for i, msg := range errors {
+			if msg != tc.expected[i] {
+				t.Fatalf("Wrong error for %q. Got=%q, Expected=%q", tc.input, msg, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestResolveDeclaredNames(t *testing.T) {
+	r := resolver.New()
+	r.Declare("previous")
+
+	lxr := lexer.New("previous + 1;")
+	program := parser.New(lxr).ParseProgram()
+
+	if errors := r.Resolve(program); len(errors) != 0 {
+		t.Fatalf("Expected no errors. Got=%v", errors)
+	}
+}
+
+func TestResolveWarnings(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"var x = 1; x;", []string{}},
+		{"x; var x = 1;", []string{"1:1: variable 'x' is used before it's declared"}},
+		{"var x = x;", []string{"1:9: variable 'x' is used before it's declared"}},
+		// the x of the block isn't there yet, so this is the outer one
+		{"var x = 1; if (true) { var y = x; var x = 2; }", []string{"1:32: variable 'x' is used before it's declared"}},
+		// functions only run when they're called
+		{"var f = func() { g() }; var g = func() { 1 };", []string{}},
+		{"var len = 1;", []string{"1:5: variable 'len' can't be used, 'len' is the name of an intrinsic function"}},
+		{"var f = func(print) { 1 };", []string{"1:14: variable 'print' can't be used, 'print' is the name of an intrinsic function"}},
+		{"try { 1 } catch (next) { 2 };", []string{"1:18: variable 'next' can't be used, 'next' is the name of an intrinsic function"}},
+	}
+
+	for _, tc := range testCases {
+		r := resolver.New()
+		if errors := r.Resolve(parse(t, tc.input)); len(errors) != 0 {
+			t.Fatalf("Expected no errors for %q. Got=%v", tc.input, errors)
+		}
+
+		warnings := r.Warnings()
+		if len(warnings) != len(tc.expected) {
+			t.Fatalf("Wrong number of warnings for %q. Got=%v, Expected=%v", tc.input, warnings, tc.expected)
+		}
+
+		for i, msg := range warnings {
+			if msg != tc.expected[i] {
+				t.Fatalf("Wrong warning for %q. Got=%q, Expected=%q", tc.input, msg, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	program := parse(t, `
+var x = 1;
+var f = func(a, b) { if (a) { var c = b; c + x } else { len(a) } };`)
+	resolver.New().Resolve(program)
+
+	f := program.Statements[1].(*ast.VarStatement).Value.(*ast.FuncExpression)
+	if got := strings.Join(f.Body.Slots, ", "); got != "self, super, a, b" {
+		t.Fatalf("Wrong slots for the body of f. Got=%s", got)
+	}
+
+	ifExpr := f.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	block := ifExpr.Consequence
+	if got := strings.Join(block.Slots, ", "); got != "c" {
+		t.Fatalf("Wrong slots for the if block. Got=%s", got)
+	}
+
+	decl := block.Statements[0].(*ast.VarStatement)
+	sum := block.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	call := ifExpr.Alternative.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	testCases := []struct {
+		name     string
+		ident    *ast.Identifier
+		expected ast.Binding
+	}{
+		{"a", ifExpr.Condition.(*ast.Identifier), ast.Binding{Kind: ast.Local, Depth: 0, Slot: 2}},
+		{"b", decl.Value.(*ast.Identifier), ast.Binding{Kind: ast.Local, Depth: 1, Slot: 3}},
+		{"var c", decl.Name, ast.Binding{Kind: ast.Local, Depth: 0, Slot: 0}},
+		{"c", sum.Left.(*ast.Identifier), ast.Binding{Kind: ast.Local, Depth: 0, Slot: 0}},
+		{"x", sum.Right.(*ast.Identifier), ast.Binding{Kind: ast.Global, Depth: 2}},
+		{"len", call.Function.(*ast.Identifier), ast.Binding{Kind: ast.Intrinsic}},
+		{"var x", program.Statements[0].(*ast.VarStatement).Name, ast.Binding{Kind: ast.Global}},
+	}
+
+	for _, tc := range testCases {
+		if tc.ident.Binding != tc.expected {
+			t.Fatalf("Wrong binding for %s. Got=%+v, Expected=%+v", tc.name, tc.ident.Binding, tc.expected)
+		}
+	}
+}
+
+func resolve(t *testing.T, input string) []string {
+	lxr := lexer.New(input)
+	p := parser.New(lxr)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, p.Errors())
+	}
+
+	return resolver.New().Resolve(program)
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program
+}
@@ -7,6 +7,10 @@ type TokenType uint8
 type Token struct {
 	Type    TokenType
 	Literal []rune
+
+	// where the token starts in the source, counting from 1
+	Line   int
+	Column int
 }
 
 const (
@@ -39,6 +43,7 @@ const (
 	SEMICOLON
 	COLON
 	DOT
+	HASH
 
 	LPAREN
 	RPAREN
@@ -59,20 +64,46 @@ const (
 	NOT
 	IF
 	ELSE
+	THROW
+	TRY
+	CATCH
+	FINALLY
+	YIELD
+	SPAWN
+	IMPORT
+	AS
+	FROM
+	EXPORT
+	IS
+	IN
+	ENUM
 )
 
 var Keywords = map[string]TokenType{
-	"nil":    NIL,
-	"var":    VAR,
-	"func":   FUNC,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"and":    AND,
-	"or":     OR,
-	"not":    NOT,
-	"if":     IF,
-	"else":   ELSE,
+	"nil":     NIL,
+	"var":     VAR,
+	"func":    FUNC,
+	"return":  RETURN,
+	"true":    TRUE,
+	"false":   FALSE,
+	"and":     AND,
+	"or":      OR,
+	"not":     NOT,
+	"if":      IF,
+	"else":    ELSE,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"yield":   YIELD,
+	"spawn":   SPAWN,
+	"import":  IMPORT,
+	"as":      AS,
+	"from":    FROM,
+	"export":  EXPORT,
+	"is":      IS,
+	"in":      IN,
+	"enum":    ENUM,
 }
 
 var Delimiters = map[rune]TokenType{
@@ -86,6 +117,7 @@ var Delimiters = map[rune]TokenType{
 	';': SEMICOLON,
 	':': COLON,
 	'.': DOT,
+	'#': HASH,
 }
 
 var UnaryOperators = map[rune]TokenType{
@@ -184,6 +216,7 @@ func (t Token) String() string {
 		RBRACE:                "}",
 		COLON:                 ":",
 		DOT:                   ".",
+		HASH:                  "#",
 		NIL:                   "nil",
 		VAR:                   "var",
 		FUNC:                  "func",
@@ -195,6 +228,19 @@ func (t Token) String() string {
 		NOT:                   "not",
 		IF:                    "if",
 		ELSE:                  "else",
+		THROW:                 "throw",
+		TRY:                   "try",
+		CATCH:                 "catch",
+		FINALLY:               "finally",
+		YIELD:                 "yield",
+		SPAWN:                 "spawn",
+		IMPORT:                "import",
+		AS:                    "as",
+		FROM:                  "from",
+		EXPORT:                "export",
+		IS:                    "is",
+		IN:                    "in",
+		ENUM:                  "enum",
 	}
 
 	return fmt.Sprintf("Token(%v, '%v')", types[t.Type], string(t.Literal))
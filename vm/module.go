@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"path/filepath"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/compiler"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// importModule returns what the import pushes: the module itself, or
+// the values of the names imported out of it, in the order they were
+// listed. If the import fails, the error is the only thing returned
+func (vm *VM) importModule(importer string, imp compiler.Import) []object.Object {
+	path, err := eval.FindModule(vm.fs, importer, imp.Path, vm.SearchPath)
+	if err != nil {
+		return []object.Object{err}
+	}
+
+	result := vm.modules.Load(vm.fs, importer, path, func(program *ast.Program) object.Object {
+		code, err := compiler.Compile(program)
+		if err != nil {
+			return object.NewError(object.IMPORT_ERROR, "module '%s' can't be compiled: %s",
+				filepath.Base(path), err)
+		}
+		code.File = path
+
+		result, env := vm.runMain(code)
+		if isError(result) {
+			return result
+		}
+
+		// a module is done once it has been imported,
+		// so its values are all there by now
+		scope := object.NewModuleScope(path)
+		for name, slot := range code.Globals {
+			if val := env.slots[slot]; val != nil {
+				scope.Set(name, val)
+			}
+		}
+
+		return &object.Module{Path: path, Scope: scope, Exports: code.Exports}
+	})
+	if isError(result) {
+		return []object.Object{result}
+	}
+	module := result.(*object.Module)
+
+	if imp.Names == nil {
+		return []object.Object{module}
+	}
+
+	values := make([]object.Object, len(imp.Names))
+	for i, name := range imp.Names {
+		val, ok := module.Get(name)
+		if !ok {
+			return []object.Object{object.NewError(object.IMPORT_ERROR,
+				"module '%s' does not export '%s'", imp.Path, name)}
+		}
+		values[i] = val
+	}
+
+	return values
+}
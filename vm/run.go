@@ -0,0 +1,443 @@
+package vm
+
+import (
+	"github.com/aziflaj/pingul/compiler"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// run runs the instructions of the frame from ip up to end, which
+// leave a value on the stack: the value of the function body, of the
+// top level, or of one of the blocks of a try. The value is returned,
+// along with whether a return statement is what it came from. An
+// error that doesn't get caught comes out as the value
+func (vm *VM) run(f *frame, ip int, end int) (object.Object, bool) {
+	base := len(f.stack)
+	ins := f.fn.Instructions
+
+	// leaves the stack the way it was found
+	done := func(result object.Object, returned bool) (object.Object, bool) {
+		clear(f.stack[base:])
+		f.stack = f.stack[:base]
+		return result, returned
+	}
+
+	for ip < end {
+		op := compiler.Opcode(ins[ip])
+		at := ip
+
+		switch op {
+		case compiler.OpConstant:
+			f.push(f.fn.Program.Constants[operand(ins, ip)])
+			ip += 3
+
+		case compiler.OpNil:
+			f.push(object.Null)
+			ip++
+
+		case compiler.OpTrue:
+			f.push(object.True)
+			ip++
+
+		case compiler.OpFalse:
+			f.push(object.False)
+			ip++
+
+		case compiler.OpPop:
+			f.pop()
+			ip++
+
+		case compiler.OpGetLocal:
+			slot := operand(ins, ip)
+			val := f.env.slots[slot]
+			if val == nil {
+				return done(vm.undefined(f, f.fn.SlotNames[slot]), false)
+			}
+			f.push(val)
+			ip += 3
+
+		case compiler.OpGetOuter:
+			e := f.env.up(int(ins[ip+1]))
+			slot := operand(ins, ip+1)
+			// a generator being let go, see env.up
+			if e == nil {
+				return done(object.NewError(object.RUNTIME_ERROR, "the generator was collected"), false)
+			}
+
+			val := e.slots[slot]
+			if val == nil {
+				return done(vm.undefined(f, e.fn.SlotNames[slot]), false)
+			}
+			f.push(val)
+			ip += 4
+
+		case compiler.OpGetName:
+			ref := f.fn.Refs[operand(ins, ip)]
+			val := lookup(f.env, ref.Vars)
+			if val == nil {
+				return done(vm.undefined(f, ref.Name), false)
+			}
+			f.push(val)
+			ip += 3
+
+		case compiler.OpSetLocal:
+			f.env.slots[operand(ins, ip)] = f.top()
+			ip += 3
+
+		case compiler.OpGetIntrinsic:
+			f.push(vm.intrinsics[f.fn.Program.Names[operand(ins, ip)]])
+			ip += 3
+
+		case compiler.OpList:
+			n := operand(ins, ip)
+			f.push(object.NewList(f.popN(n)...))
+			ip += 3
+
+		case compiler.OpTuple:
+			n := operand(ins, ip)
+			f.push(&object.Tuple{Items: f.popN(n)})
+			ip += 3
+
+		case compiler.OpSet:
+			n := operand(ins, ip)
+			set, err := object.NewSet(f.popN(n)...)
+			if err != nil {
+				return done(err, false)
+			}
+			f.push(set)
+			ip += 3
+
+		case compiler.OpDict:
+			pairs := f.popN(2 * operand(ins, ip))
+			dict := &object.Dict{Pairs: make(map[string]object.Object)}
+			for i := 0; i < len(pairs); i += 2 {
+				if err := dict.SetKey(pairs[i], pairs[i+1]); err != nil {
+					return done(err, false)
+				}
+			}
+			f.push(dict)
+			ip += 3
+
+		case compiler.OpIndex:
+			index := f.pop()
+			list := f.pop()
+
+			var result object.Object
+			if hook, ok := eval.Metamethod(list, "__index__"); ok {
+				result = vm.call(f.info, f.site(at), hook, []object.Object{index})
+			} else {
+				result = eval.Index(list, index)
+			}
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip++
+
+		case compiler.OpProperty:
+			result := eval.PropertyAccess(f.pop(), f.fn.Program.Names[operand(ins, ip)])
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip += 3
+
+		case compiler.OpPrefix:
+			operator := compiler.Operators[ins[ip+1]]
+			right := f.pop()
+
+			var result object.Object
+			if hook, ok := eval.PrefixHook(operator, right); ok {
+				result = vm.call(f.info, f.site(at), hook, []object.Object{})
+			} else {
+				result = eval.Prefix(operator, right)
+			}
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip += 2
+
+		case compiler.OpInfix:
+			operator := compiler.Operators[ins[ip+1]]
+			right := f.pop()
+			left := f.pop()
+
+			result := vm.infix(f, at, operator, left, right)
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip += 2
+
+		case compiler.OpTruthy:
+			f.push(object.NewBoolean(f.pop().IsTruthy()))
+			ip++
+
+		case compiler.OpJump:
+			ip = operand(ins, ip)
+
+		case compiler.OpJumpIfNot:
+			if !f.pop().IsTruthy() {
+				ip = operand(ins, ip)
+			} else {
+				ip += 3
+			}
+
+		case compiler.OpBranch:
+			cond := f.pop()
+			if cond.Type() != object.BOOL && vm.strict(f) {
+				return done(object.NewError(object.TYPE_ERROR,
+					"if condition must be a BOOL in strict mode, got %s", cond.Type()), false)
+			}
+
+			if !cond.IsTruthy() {
+				ip = operand(ins, ip)
+			} else {
+				ip += 3
+			}
+
+		case compiler.OpClosure:
+			fn := f.fn.Program.Functions[operand(ins, ip)]
+			f.push(&object.Func{
+				Params:      fn.Node.Params,
+				Body:        fn.Node.Body,
+				Caller:      vm,
+				Name:        fn.Name,
+				IsGenerator: fn.Node.IsGenerator,
+				Closure:     closure{fn: fn, env: f.env},
+			})
+			ip += 3
+
+		case compiler.OpCall:
+			fun := f.pop()
+			args := f.stack[len(f.stack)-int(ins[ip+1]):]
+
+			result := vm.call(f.info, f.site(at), fun, args)
+			f.drop(len(args))
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip += 2
+
+		case compiler.OpTailCall:
+			fun := f.pop()
+			args := f.stack[len(f.stack)-int(ins[ip+1]):]
+
+			// the call takes the place of the one in progress,
+			// as long as it's a call the VM can make in place
+			if fun, ok := fun.(*object.Func); ok && !fun.IsGenerator && f.yield == nil && vm.owns(fun) {
+				var caller *object.Frame
+				if f.info != nil {
+					caller = f.info.Caller
+				}
+
+				next, err := vm.enter(caller, f.site(at), fun, args)
+				if err != nil {
+					return done(err, false)
+				}
+
+				*f = *next
+				ins, ip, end, base = f.fn.Instructions, 0, len(f.fn.Instructions), 0
+				continue
+			}
+
+			result := vm.call(f.info, f.site(at), fun, args)
+			f.drop(len(args))
+			if isError(result) {
+				return done(result, false)
+			}
+			f.push(result)
+			ip += 2
+
+		case compiler.OpReturn:
+			return done(f.pop(), true)
+
+		case compiler.OpSpawn:
+			fun := f.pop()
+			args := f.popN(int(ins[ip+1]))
+			caller, site := f.info, f.site(at)
+
+			f.push(object.Spawn(func() object.Object {
+				return vm.call(caller, site, fun, args)
+			}))
+			ip += 2
+
+		case compiler.OpYield:
+			val := f.pop()
+			if f.yield == nil {
+				return done(object.NewError(object.RUNTIME_ERROR, "yield outside of a generator"), false)
+			}
+
+			// nobody is going to ask for more values,
+			// so return out of the generator body
+			if !f.yield(val) {
+				return done(object.Null, true)
+			}
+			f.push(object.Null)
+			ip++
+
+		case compiler.OpThrow:
+			return done(object.Throw(f.info, f.pop()), false)
+
+		case compiler.OpTry:
+			try := f.fn.Tries[operand(ins, ip)]
+			result, returned := vm.try(f, try)
+			if returned || isError(result) {
+				return done(result, returned)
+			}
+			f.push(result)
+			ip = try.End
+
+		case compiler.OpImport:
+			imp := f.fn.Program.Imports[operand(ins, ip)]
+			values := vm.importModule(f.fn.Program.File, imp)
+			if len(values) == 1 && isError(values[0]) {
+				return done(values[0], false)
+			}
+			for _, val := range values {
+				f.push(val)
+			}
+			ip += 3
+
+		case compiler.OpEnum:
+			f.push(eval.NewEnum(f.fn.Program.Enums[operand(ins, ip)]))
+			ip += 3
+
+		default:
+			return done(object.NewError(object.RUNTIME_ERROR, "unknown opcode %d", op), false)
+		}
+	}
+
+	if len(f.stack) == base {
+		return done(nil, false)
+	}
+	return done(f.pop(), false)
+}
+
+// the value of a try expression is the value of the try block,
+// or the value of the catch block if something was thrown.
+// finally runs no matter what, and only gets the last word
+// if it returns or throws itself
+func (vm *VM) try(f *frame, try compiler.Try) (object.Object, bool) {
+	result, returned := vm.run(f, try.Block.Start, try.Block.End)
+
+	if err, ok := result.(*object.Error); ok && try.Catch != nil && err.Catchable() {
+		if try.CatchSlot != -1 {
+			f.env.slots[try.CatchSlot] = err.Caught()
+		}
+
+		result, returned = vm.run(f, try.Catch.Start, try.Catch.End)
+	}
+
+	if try.Finally != nil {
+		finally, finallyReturned := vm.run(f, try.Finally.Start, try.Finally.End)
+
+		if finallyReturned || isError(finally) {
+			return finally, finallyReturned
+		}
+	}
+
+	return result, returned
+}
+
+func (vm *VM) infix(f *frame, at int, operator string, left object.Object, right object.Object) object.Object {
+	hook, arg, negate, ok := eval.InfixHook(operator, left, right)
+	if !ok {
+		return eval.Infix(f.info, operator, left, right, vm.strict(f))
+	}
+
+	result := vm.call(f.info, f.site(at), hook, []object.Object{arg})
+	if negate && !isError(result) {
+		return object.NewBoolean(!result.IsTruthy())
+	}
+
+	return result
+}
+
+// lookup returns the value of the first of the slots that's set
+func lookup(e *env, vars []compiler.Var) object.Object {
+	for _, v := range vars {
+		if outer := e.up(v.Depth); outer != nil && outer.slots[v.Slot] != nil {
+			return outer.slots[v.Slot]
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) undefined(f *frame, name string) *object.Error {
+	var candidates []string
+	for e := f.env; e != nil; e = e.up(1) {
+		for slot, val := range e.slots {
+			if val != nil {
+				candidates = append(candidates, e.fn.SlotNames[slot])
+			}
+		}
+	}
+	for intrinsic := range vm.intrinsics {
+		candidates = append(candidates, intrinsic)
+	}
+
+	if suggestion := object.Suggest(name, candidates); suggestion != "" {
+		return object.NewError(object.NAME_ERROR, "undefined variable '%s' (did you mean '%s'?)", name, suggestion)
+	}
+
+	return object.NewError(object.NAME_ERROR, "undefined variable '%s'", name)
+}
+
+// owns tells whether the function was made by the VM
+func (vm *VM) owns(fun *object.Func) bool {
+	_, ok := fun.Closure.(closure)
+	return ok && fun.Caller == vm
+}
+
+// strict tells whether the code running in the frame is strict,
+// either because of the VM or because of a pragma
+func (vm *VM) strict(f *frame) bool {
+	return vm.Strict || f.fn.Program.Strict
+}
+
+func (f *frame) push(obj object.Object) {
+	f.stack = append(f.stack, obj)
+}
+
+func (f *frame) pop() object.Object {
+	obj := f.stack[len(f.stack)-1]
+	f.stack[len(f.stack)-1] = nil
+	f.stack = f.stack[:len(f.stack)-1]
+
+	return obj
+}
+
+func (f *frame) top() object.Object {
+	return f.stack[len(f.stack)-1]
+}
+
+// popN pops the n values on top of the stack, in the order they were
+// pushed. They're copied, since whoever popped them might keep them
+func (f *frame) popN(n int) []object.Object {
+	items := make([]object.Object, n)
+	copy(items, f.stack[len(f.stack)-n:])
+	f.drop(n)
+
+	return items
+}
+
+// drop pops the n values on top of the stack
+func (f *frame) drop(n int) {
+	clear(f.stack[len(f.stack)-n:])
+	f.stack = f.stack[:len(f.stack)-n]
+}
+
+// site is where the instruction at ip comes from
+func (f *frame) site(ip int) object.Position {
+	site := f.fn.Sites[ip]
+	return object.Position{File: f.fn.Program.File, Line: site.Line, Column: site.Column}
+}
+
+// operand reads the two byte operand of the instruction at ip
+func operand(ins compiler.Instructions, ip int) int {
+	return int(compiler.ReadUint16(ins[ip+1:]))
+}
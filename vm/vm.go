@@ -0,0 +1,223 @@
+package vm
+
+import (
+	"os"
+	"weak"
+
+	"github.com/aziflaj/pingul/compiler"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+)
+
+// VM runs the bytecode the compiler package makes. It means the
+// same things the evaluator does, only faster: variables live in
+// slots rather than maps, and nothing gets dispatched on the AST
+type VM struct {
+	// where imports are looked up when they can't be
+	// found relative to the file doing the importing
+	SearchPath []string
+
+	// Strict turns off the implicit coercions, see eval.Interpreter
+	Strict bool
+
+	// MaxDepth is how deep pingul calls can nest before they fail
+	// with a StackOverflow, instead of taking the process down
+	MaxDepth int
+
+	modules *eval.ModuleCache
+
+	// where modules are imported from, the FS of the capabilities
+	fs *os.Root
+
+	// the intrinsics, limited to the capabilities it was created with
+	intrinsics object.FuncTable
+}
+
+// New creates a VM with no capabilities,
+// which can run nothing but pure computation
+func New() *VM {
+	return NewWithCapabilities(object.Capabilities{})
+}
+
+// NewWithCapabilities creates a VM whose intrinsics can
+// reach as far outside of it as caps allows them to
+func NewWithCapabilities(caps object.Capabilities) *VM {
+	return &VM{
+		SearchPath: []string{},
+		MaxDepth:   eval.DefaultMaxDepth,
+		modules:    eval.NewModuleCache(),
+		fs:         caps.FS,
+		intrinsics: object.Intrinsics(caps),
+	}
+}
+
+// env holds the slots of a function call. Functions
+// close over the env they were created in
+type env struct {
+	slots []object.Object
+	outer *env
+	fn    *compiler.Function
+
+	// takes the place of outer in the env of a generator body,
+	// see object.Scope.HoldOuterWeakly
+	weakOuter weak.Pointer[env]
+}
+
+// up is the env depth envs out from this one, or nil if it's gone,
+// which only happens to generators nobody can ask for values anymore
+func (e *env) up(depth int) *env {
+	for ; depth > 0 && e != nil; depth-- {
+		if e.outer != nil {
+			e = e.outer
+		} else {
+			e = e.weakOuter.Value()
+		}
+	}
+
+	return e
+}
+
+// closure is what the VM keeps in object.Func.Closure
+type closure struct {
+	fn  *compiler.Function
+	env *env
+}
+
+// frame is a function call in progress, or the top level
+type frame struct {
+	fn    *compiler.Function
+	env   *env
+	stack []object.Object
+
+	// nil for the top level
+	info *object.Frame
+
+	// set on the frame of a running generator
+	yield func(object.Object) bool
+}
+
+// Run runs the program, returning what its last statement
+// evaluates to, or nil if there are no statements at all
+func (vm *VM) Run(code *compiler.Bytecode) object.Object {
+	result, _ := vm.runMain(code)
+	return result
+}
+
+// runMain runs the top level, returning its env along with the
+// result, which is where the values of a module come from
+func (vm *VM) runMain(code *compiler.Bytecode) (object.Object, *env) {
+	main := code.Main
+	buf := make([]object.Object, len(main.SlotNames)+main.MaxStack)
+
+	f := &frame{
+		fn:    main,
+		env:   &env{slots: buf[:len(main.SlotNames)], fn: main},
+		stack: buf[len(main.SlotNames):len(main.SlotNames)],
+	}
+
+	result, _ := vm.run(f, 0, len(main.Instructions))
+	return result, f.env
+}
+
+// Call lets intrinsics such as lazy_map call back into pingul functions,
+// on behalf of the caller frame the intrinsic was itself called from
+func (vm *VM) Call(caller *object.Frame, fun object.Object, args ...object.Object) object.Object {
+	return vm.call(caller, object.Position{}, fun, args)
+}
+
+// call calls fun on behalf of the caller, which is nil when the
+// call comes from the top level. args may be
+// part of the caller's stack, so they're copied if they have to stay
+func (vm *VM) call(caller *object.Frame, site object.Position, fun object.Object, args []object.Object) object.Object {
+	if hook, ok := eval.Metamethod(fun, "__call__"); ok {
+		return vm.call(caller, site, hook, args)
+	}
+
+	switch fun := fun.(type) {
+	case object.IntrinsicFunc:
+		return fun(caller, append([]object.Object{}, args...)...)
+
+	case *object.Func:
+		// made by someone else, who knows how to run it
+		if _, ok := fun.Closure.(closure); !ok || fun.Caller != vm {
+			return fun.Caller.Call(caller, fun, append([]object.Object{}, args...)...)
+		}
+
+		f, err := vm.enter(caller, site, fun, args)
+		if err != nil {
+			return err
+		}
+
+		if fun.IsGenerator {
+			captured := f.env.outer
+			f.env.weakOuter, f.env.outer = weak.Make(captured), nil
+
+			return object.NewGenerator(captured, f.info, func(yield func(object.Object) bool) object.Object {
+				f.yield = yield
+				result, _ := vm.run(f, 0, len(f.fn.Instructions))
+				return withStack(result, f.info)
+			})
+		}
+
+		result, _ := vm.run(f, 0, len(f.fn.Instructions))
+		return withStack(result, f.info)
+	}
+
+	return object.NewError(object.TYPE_ERROR, "%s is not a function", fun.Type())
+}
+
+// enter sets up the frame of a call to fun, which has to be
+// a function the VM made itself. Nothing runs just yet
+func (vm *VM) enter(caller *object.Frame, site object.Position, fun *object.Func, args []object.Object) (*frame, *object.Error) {
+	cl := fun.Closure.(closure)
+
+	info := object.NewFrame(fun.Name, len(args), site, caller)
+	if info.Depth > vm.MaxDepth {
+		return nil, withStack(eval.StackOverflow(vm.MaxDepth, info), info).(*object.Error)
+	}
+
+	if len(args) != len(cl.fn.ParamSlots) {
+		return nil, withStack(object.NewError(object.ARGUMENT_ERROR,
+			"function expects %d argument(s), got %d", len(cl.fn.ParamSlots), len(args)), info).(*object.Error)
+	}
+
+	// the slots and the stack are allocated in one go
+	n := len(cl.fn.SlotNames)
+	buf := make([]object.Object, n+cl.fn.MaxStack)
+	slots := buf[:n]
+
+	if fun.Self != nil {
+		slots[cl.fn.SelfSlot] = fun.Self
+
+		super := &object.Super{Self: fun.Self}
+		if fun.Home != nil {
+			super.Proto = fun.Home.Proto
+		}
+		slots[cl.fn.SuperSlot] = super
+	}
+
+	for i, slot := range cl.fn.ParamSlots {
+		slots[slot] = args[i]
+	}
+
+	return &frame{
+		fn:    cl.fn,
+		env:   &env{slots: slots, outer: cl.env, fn: cl.fn},
+		stack: buf[n:n],
+		info:  info,
+	}, nil
+}
+
+// withStack records the frame an error is leaving on its way up,
+// unless it has already left a frame deeper down the stack
+func withStack(result object.Object, frame *object.Frame) object.Object {
+	if err, ok := result.(*object.Error); ok && err.Stack == nil {
+		err.Stack = frame
+	}
+
+	return result
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR
+}
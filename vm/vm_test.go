@@ -0,0 +1,430 @@
+package vm_test
+
+import (
+	"bytes"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aziflaj/pingul/ast"
+	"github.com/aziflaj/pingul/compiler"
+	"github.com/aziflaj/pingul/eval"
+	"github.com/aziflaj/pingul/object"
+	"github.com/aziflaj/pingul/parser"
+	"github.com/aziflaj/pingul/vm"
+)
+
+// the tests of the evaluator. Every string in them that parses
+// as a program gets run by both the evaluator and the VM, next to
+// the modules written out by the same test function. The budget
+// tests are left out, their programs never end on their own
+var evalTests = []string{
+	"../eval/eval_test.go",
+	"../eval/module_test.go",
+	"../eval/capabilities_test.go",
+}
+
+func TestSameResultsAsEval(t *testing.T) {
+	ran := 0
+
+	for _, file := range evalTests {
+		for _, test := range programsOf(t, file) {
+			dir := writeModules(t, test.modules)
+			root, err := os.OpenRoot(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			caps := object.Capabilities{FS: root}
+
+			for _, input := range test.inputs {
+				program, errors := parser.ParseFromString(input)
+				if len(errors) != 0 {
+					continue
+				}
+				main := filepath.Join(dir, "main.pl")
+
+				expected := eval.NewWithCapabilities(caps).Eval(object.NewModuleScope(main), program)
+				got := vm.NewWithCapabilities(caps).Run(compile(t, program, main))
+
+				if !same(got, expected, 0) {
+					t.Errorf("%s: different results for %q. VM=%s, eval=%s",
+						test.name, input, inspect(got), inspect(expected))
+				}
+				ran++
+			}
+			root.Close()
+		}
+	}
+
+	t.Logf("compared %d programs", ran)
+	if ran < 500 {
+		t.Fatalf("Only %d programs were compared, the test files have a lot more", ran)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.pl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No examples found: %v", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, errors := parser.ParseFromString(string(content))
+		if len(errors) != 0 {
+			t.Fatalf("%s doesn't parse: %v", file, errors)
+		}
+
+		var evalOut, vmOut bytes.Buffer
+		in := eval.NewWithCapabilities(object.Capabilities{Stdout: &evalOut})
+		expected := in.Eval(object.NewModuleScope(file), program)
+		got := vm.NewWithCapabilities(object.Capabilities{Stdout: &vmOut}).Run(compile(t, program, file))
+
+		if !same(got, expected, 0) {
+			t.Errorf("%s: different results. VM=%s, eval=%s", file, inspect(got), inspect(expected))
+		}
+		if vmOut.String() != evalOut.String() {
+			t.Errorf("%s: different output. VM=%q, eval=%q", file, vmOut.String(), evalOut.String())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	input := `
+var count = func(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };
+count(100000, 0);`
+
+	assertInteger(t, run(t, input), 100000)
+}
+
+func TestStackOverflow(t *testing.T) {
+	m := vm.New()
+	m.MaxDepth = 50
+
+	result := runFile(t, m, "", "var f = func(n) { f(n + 1) + 1 }; f(0);")
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Kind != object.STACK_OVERFLOW {
+		t.Fatalf("Expected a StackOverflow. Got=%s", inspect(result))
+	}
+	expected := "maximum call depth of 50 exceeded (innermost calls: f, f, f, f, f, ...)"
+	if err.Message != expected {
+		t.Fatalf("Wrong message. Got=%q, Expected=%q", err.Message, expected)
+	}
+}
+
+func TestRepetitionTooLong(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`var s = "ab"; s * 9223372036854775807;`, "can't repeat a STRING of 2 characters 9223372036854775807 times, the result would be too long"},
+		{`var l = [1, 2]; l * 4611686018427387904;`, "can't repeat a LIST of 2 items 4611686018427387904 times, the result would be too long"},
+		{`var s = "a"; s * 100000000000;`, "can't repeat a STRING of 1 characters 100000000000 times, the result would be too long"},
+	}
+
+	for _, tc := range testCases {
+		result := run(t, tc.input)
+
+		err, ok := result.(*object.Error)
+		if !ok || err.Kind != object.ARGUMENT_ERROR || err.Message != tc.expected {
+			t.Errorf("Expected an ArgumentError for %q. Got=%s", tc.input, inspect(result))
+		}
+	}
+}
+
+func TestImportsNeedTheFileSystem(t *testing.T) {
+	dir := writeModules(t, map[string]string{"math.pl": `export var answer = 42;`})
+
+	result := runFile(t, vm.New(), filepath.Join(dir, "main.pl"), `import "math.pl" as m;`)
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Kind != object.PERMISSION_ERROR || err.Message != "import needs the filesystem capability" {
+		t.Fatalf("Expected a PermissionError. Got=%s", inspect(result))
+	}
+}
+
+func TestTracebacks(t *testing.T) {
+	input := `var inner = func() { throw "boom" };
+var outer = func(x) { inner() + x };
+outer(1);`
+
+	result := run(t, input)
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("Expected an error. Got=%s", inspect(result))
+	}
+
+	expected := "Traceback (most recent call first):\n" +
+		"  in inner (0 args), called at line 2, column 28\n" +
+		"  in outer (1 arg), called at line 3, column 6\n"
+	if err.Traceback() != expected {
+		t.Fatalf("Wrong traceback. Got=%q, Expected=%q", err.Traceback(), expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"var adder = func(x) { func(y) { x + y } }; adder(1)(2);", 3},
+		{"var a = func(x) { func(y) { func(z) { x + y + z } } }; a(1)(2)(3);", 6},
+		// a block can use a name before declaring it for itself
+		{"var x = 1; var f = func() { if (true) { var y = x; var x = 2; y + x } }; f();", 3},
+		// a function sees what's declared after it
+		{"var f = func() { g() }; var g = func() { 42 }; f();", 42},
+		{"var counter = func() { var n = 10; { get: func() { n } } }; counter().get();", 10},
+	}
+
+	for _, tc := range testCases {
+		assertInteger(t, run(t, tc.input), tc.expected)
+	}
+}
+
+// see the test of the same name in eval
+func TestAbandonedGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	result := run(t, `
+var f = func() { var gen = func() { yield 1; yield 2; }; var g = gen(); next(g) };
+var loop = func(n) { if (n > 0) { f(); loop(n - 1) } };
+loop(2000);`)
+	if _, ok := result.(*object.Error); ok {
+		t.Fatalf("Unexpected error: %s", result.Inspect())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() >= before+100 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Abandoned generators are still running. Goroutines=%d", runtime.NumGoroutine())
+		}
+
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type evalTest struct {
+	name    string
+	modules map[string]string
+	inputs  []string
+}
+
+// programsOf returns the strings every test function of the file has,
+// separating the modules it writes out from everything else
+func programsOf(t *testing.T, file string) []evalTest {
+	fset := gotoken.NewFileSet()
+	parsed, err := goparser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests []evalTest
+	for _, decl := range parsed.Decls {
+		fn, ok := decl.(*goast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		test := evalTest{name: fn.Name.Name, modules: map[string]string{}}
+		goast.Inspect(fn.Body, func(node goast.Node) bool {
+			if lit, ok := node.(*goast.CompositeLit); ok && isModuleMap(lit) {
+				for _, elt := range lit.Elts {
+					kv := elt.(*goast.KeyValueExpr)
+					test.modules[unquote(t, kv.Key)] = unquote(t, kv.Value)
+				}
+				return false
+			}
+
+			if lit, ok := node.(*goast.BasicLit); ok && lit.Kind == gotoken.STRING {
+				test.inputs = append(test.inputs, unquote(t, lit))
+			}
+			return true
+		})
+
+		tests = append(tests, test)
+	}
+
+	return tests
+}
+
+func isModuleMap(lit *goast.CompositeLit) bool {
+	mapType, ok := lit.Type.(*goast.MapType)
+	if !ok {
+		return false
+	}
+
+	key, _ := mapType.Key.(*goast.Ident)
+	value, _ := mapType.Value.(*goast.Ident)
+	return key != nil && value != nil && key.Name == "string" && value.Name == "string"
+}
+
+func unquote(t *testing.T, expr goast.Expr) string {
+	lit, ok := expr.(*goast.BasicLit)
+	if !ok {
+		return ""
+	}
+
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// same tells whether the VM came up with the same value as the
+// evaluator. The two never share a function, so functions are
+// told apart by where they come from, and errors by what they say
+func same(got, expected object.Object, depth int) bool {
+	if got == nil || expected == nil {
+		return got == nil && expected == nil
+	}
+	if got.Type() != expected.Type() {
+		return false
+	}
+	// values that contain themselves
+	if depth > 10 {
+		return true
+	}
+
+	switch got := got.(type) {
+	case *object.Error:
+		expected := expected.(*object.Error)
+		return got.Kind == expected.Kind && got.Message == expected.Message
+
+	case *object.Func:
+		return got.Body == expected.(*object.Func).Body
+
+	case *object.List:
+		return sameItems(got.Elements(), expected.(*object.List).Elements(), depth)
+
+	case *object.Tuple:
+		return sameItems(got.Items, expected.(*object.Tuple).Items, depth)
+
+	case *object.Dict:
+		expected := expected.(*object.Dict)
+		if len(got.Pairs) != len(expected.Pairs) {
+			return false
+		}
+		for key, val := range got.Pairs {
+			if !same(val, expected.Pairs[key], depth+1) {
+				return false
+			}
+		}
+		return got.Len() == expected.Len() && (got.Proto == nil) == (expected.Proto == nil)
+	}
+
+	return object.Equal(got, expected) || got.Inspect() == expected.Inspect()
+}
+
+func sameItems(got, expected []object.Object, depth int) bool {
+	if len(got) != len(expected) {
+		return false
+	}
+
+	for i := range got {
+		if !same(got[i], expected[i], depth+1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func run(t *testing.T, input string) object.Object {
+	t.Helper()
+	return runFile(t, vm.New(), "", input)
+}
+
+// runFile runs the input as if it was read from file
+func runFile(t *testing.T, m *vm.VM, file string, input string) object.Object {
+	t.Helper()
+
+	program, errors := parser.ParseFromString(input)
+	if len(errors) != 0 {
+		t.Fatalf("Parser errors for %q: %v", input, errors)
+	}
+
+	return m.Run(compile(t, program, file))
+}
+
+func compile(t *testing.T, program *ast.Program, file string) *compiler.Bytecode {
+	t.Helper()
+
+	code, err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("Compiler error for %q: %s", program, err)
+	}
+	code.File = file
+
+	return code
+}
+
+func assertInteger(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("Expected an INT. Got=%s", inspect(obj))
+	}
+	if result.Value != expected {
+		t.Fatalf("Wrong value. Got=%d, Expected=%d", result.Value, expected)
+	}
+}
+
+const fib = "var fib = func(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20);"
+
+func BenchmarkFibVM(b *testing.B) {
+	program, _ := parser.ParseFromString(fib)
+	code, err := compiler.Compile(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		vm.New().Run(code)
+	}
+}
+
+func BenchmarkFibEval(b *testing.B) {
+	program, _ := parser.ParseFromString(fib)
+
+	for b.Loop() {
+		eval.New().Eval(object.NewScope(), program)
+	}
+}